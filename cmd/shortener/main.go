@@ -1,20 +1,25 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/AlenaMolokova/http/internal/app"
 	"github.com/AlenaMolokova/http/internal/app/config"
+	"github.com/AlenaMolokova/http/internal/app/logging"
 	"github.com/AlenaMolokova/http/internal/app/router"
 	"github.com/sirupsen/logrus"
 )
 
 func main() {
-	logrus.SetFormatter(&logrus.JSONFormatter{})
-	logrus.SetLevel(logrus.InfoLevel)
-
 	cfg := config.NewConfig()
-	logrus.WithField("config", cfg).Info("Configuration loaded")
+	logging.Configure(cfg)
+	logrus.WithField("config", cfg.Redacted()).Info("Configuration loaded")
 
 	appInstance, err := app.NewApp(cfg)
 	if err != nil {
@@ -22,19 +27,99 @@ func main() {
 	}
 	logrus.Info("Application initialized")
 
-	r := router.NewRouter(appInstance.Handler)
+	if cfg.SelfTest {
+		err := appInstance.SelfTest(context.Background())
+		closeApp(appInstance)
+		if err != nil {
+			logrus.WithError(err).Fatal("Self-test failed")
+		}
+		logrus.Info("Self-test passed")
+		return
+	}
 
-	server := &http.Server{
-		Addr:    cfg.ServerAddress,
-		Handler: r.InitRoutes(),
+	if args := flag.Args(); len(args) > 0 {
+		runCommand(appInstance, args)
+		return
 	}
+
+	r := router.NewRouter(appInstance.Handler,
+		router.WithMaxBodyBytes(cfg.MaxBodyBytes, cfg.MaxBatchBodyBytes),
+		router.WithBasePath(cfg.BasePath),
+		router.WithTrustedSubnet(cfg.TrustedSubnet),
+		router.WithRequestTimeout(cfg.RequestTimeout),
+		router.WithSlowRequestThreshold(time.Duration(cfg.SlowRequestMS)*time.Millisecond),
+		router.WithMaxConcurrentRequests(cfg.MaxConcurrent),
+		router.WithAdmin(appInstance.Admin),
+	)
+
+	server := newServer(cfg, r.InitRoutes())
 	logrus.WithFields(logrus.Fields{
 		"address":  cfg.ServerAddress,
 		"base_url": cfg.BaseURL,
 	}).Info("Starting server")
 
-	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		logrus.WithError(err).Fatal("Failed to start server")
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		if err != nil && err != http.ErrServerClosed {
+			closeApp(appInstance)
+			logrus.WithError(err).Fatal("Failed to start server")
+		}
+	case <-ctx.Done():
+		logrus.Info("Shutting down server")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			logrus.WithError(err).Error("Server shutdown failed")
+		}
+		if err := appInstance.Flush(shutdownCtx); err != nil {
+			logrus.WithError(err).Error("Failed to flush buffered writes")
+		}
+	}
+
+	closeApp(appInstance)
+	logrus.Info("Server stopped")
+}
+
+const shutdownTimeout = 10 * time.Second
+
+// newServer builds the HTTP server with the connection timeouts from cfg, so
+// slow clients cannot hold connections open indefinitely.
+func newServer(cfg *config.Config, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              cfg.ServerAddress,
+		Handler:           handler,
+		ReadHeaderTimeout: cfg.ReadTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+}
+
+func runCommand(a *app.App, args []string) {
+	defer closeApp(a)
+
+	switch args[0] {
+	case "shorten":
+		if err := app.ShortenCLI(context.Background(), a.Service, args[1:], os.Stdout); err != nil {
+			closeApp(a)
+			logrus.WithError(err).Fatal("Shorten command failed")
+		}
+	default:
+		closeApp(a)
+		logrus.WithField("command", args[0]).Fatal("Unknown command")
+	}
+}
+
+func closeApp(a *app.App) {
+	if err := a.Close(); err != nil {
+		logrus.WithError(err).Error("Failed to close storage")
 	}
-	logrus.Info("Server is running")
 }
\ No newline at end of file
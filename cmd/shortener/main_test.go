@@ -0,0 +1,32 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/AlenaMolokova/http/internal/app/config"
+)
+
+func TestNewServerAppliesTimeouts(t *testing.T) {
+	cfg := &config.Config{
+		ServerAddress: "localhost:8080",
+		ReadTimeout:   5 * time.Second,
+		WriteTimeout:  20 * time.Second,
+		IdleTimeout:   90 * time.Second,
+	}
+	server := newServer(cfg, http.NotFoundHandler())
+
+	if server.Addr != cfg.ServerAddress {
+		t.Errorf("Expected address %q, got %q", cfg.ServerAddress, server.Addr)
+	}
+	if server.ReadTimeout != 5*time.Second || server.ReadHeaderTimeout != 5*time.Second {
+		t.Errorf("Expected read timeouts of 5s, got %v and %v", server.ReadTimeout, server.ReadHeaderTimeout)
+	}
+	if server.WriteTimeout != 20*time.Second {
+		t.Errorf("Expected write timeout 20s, got %v", server.WriteTimeout)
+	}
+	if server.IdleTimeout != 90*time.Second {
+		t.Errorf("Expected idle timeout 90s, got %v", server.IdleTimeout)
+	}
+}
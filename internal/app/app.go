@@ -1,35 +1,156 @@
 package app
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/AlenaMolokova/http/internal/app/audit"
+	"github.com/AlenaMolokova/http/internal/app/auth"
 	"github.com/AlenaMolokova/http/internal/app/config"
 	"github.com/AlenaMolokova/http/internal/app/generator"
 	"github.com/AlenaMolokova/http/internal/app/handler"
+	"github.com/AlenaMolokova/http/internal/app/models"
+	"github.com/AlenaMolokova/http/internal/app/reachability"
+	"github.com/AlenaMolokova/http/internal/app/safehttp"
 	"github.com/AlenaMolokova/http/internal/app/service"
 	"github.com/AlenaMolokova/http/internal/app/storage"
+	"github.com/AlenaMolokova/http/internal/app/webhook"
+	"github.com/sirupsen/logrus"
 )
 
 type App struct {
 	Handler *handler.URLHandler
+	Service *service.Service
+	// Admin is nil when ADMIN_ENABLED is off.
+	Admin *handler.AdminHandler
+
+	storage *storage.Storage
+	audit   audit.Logger
 }
 
 func NewApp(cfg *config.Config) (*App, error) {
-	urlStorage, err := storage.NewStorage(cfg.DatabaseDSN, cfg.FileStoragePath)
+	if cfg.AuthSecret != "" {
+		auth.SecretKey = []byte(cfg.AuthSecret)
+	}
+
+	if !handler.IsRedirectStatus(cfg.RedirectStatus) {
+		return nil, fmt.Errorf("REDIRECT_STATUS must be one of 301, 302, 307 or 308, got %d", cfg.RedirectStatus)
+	}
+
+	sameSite, err := auth.ParseSameSite(cfg.CookieSameSite)
+	if err != nil {
+		return nil, err
+	}
+	if sameSite == http.SameSiteNoneMode && !cfg.CookieSecure {
+		logrus.Warn("COOKIE_SAMESITE=none without COOKIE_SECURE, browsers will reject the cookies")
+	}
+	auth.Cookie = auth.CookieOptions{
+		Secure:   cfg.CookieSecure,
+		Domain:   cfg.CookieDomain,
+		SameSite: sameSite,
+	}
+	auth.AllowAnonymous = !cfg.RequireAuth
+	auth.AnonymousUserID = cfg.AnonymousUserID
+
+	dialer, err := outboundDialer(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	urlStorage, err := storage.NewStorage(cfg.DatabaseDSN, cfg.FileStoragePath,
+		storage.WithCompactOnLoad(cfg.CompactOnLoad),
+		storage.WithBatchChunkSize(cfg.BatchChunkSize),
+		storage.WithSQLitePath(cfg.SQLitePath),
+		storage.WithStrict(cfg.StrictStorage),
+		storage.WithDBRetry(cfg.DBRetries, cfg.DBRetryBackoff),
+		storage.WithDBBatchChunkSize(cfg.DBBatchChunkSize),
+		storage.WithHitFlushInterval(cfg.HitFlushInterval),
+	)
 	if err != nil {
 		return nil, err
 	}
 
-	urlGenerator := generator.NewGenerator(8)
+	alphabet, err := generator.Alphabet(cfg.ShortIDAlphabet)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.CaseInsensitive && alphabet != strings.ToLower(alphabet) {
+		return nil, fmt.Errorf("CASE_INSENSITIVE_IDS requires a lowercase SHORT_ID_ALPHABET, got %q", cfg.ShortIDAlphabet)
+	}
+	var urlGenerator generator.Generator
+	switch cfg.IDStrategy {
+	case "", "random":
+		urlGenerator = generator.NewGenerator(8, generator.WithAlphabet(alphabet))
+	case "counter":
+		seq, ok := urlStorage.AsURLSaver().(generator.Sequence)
+		if !ok {
+			return nil, fmt.Errorf("ID_STRATEGY=counter is not supported by the %s storage", urlStorage.Kind())
+		}
+		urlGenerator = generator.NewCounterGenerator(seq, alphabet)
+	default:
+		return nil, fmt.Errorf("ID_STRATEGY must be random or counter, got %q", cfg.IDStrategy)
+	}
+
+	urlGetter := urlStorage.AsURLGetter()
+	if cfg.CacheSize > 0 {
+		cachingGetter := service.NewCachingGetter(urlGetter, cfg.CacheSize, cfg.CacheNegativeTTL)
+		cachingGetter.TTL = cfg.CacheTTL
+		if source, ok := urlGetter.(models.RecentURLLister); ok && cfg.CacheWarmup {
+			n, err := cachingGetter.Warm(context.Background(), source)
+			if err != nil {
+				logrus.WithError(err).Warn("Failed to warm up the URL cache")
+			} else {
+				logrus.WithField("entries", n).Info("URL cache warmed up")
+			}
+		}
+		urlGetter = cachingGetter
+	}
 
 	urlService := service.NewService(
 		urlStorage.AsURLSaver(),
 		urlStorage.AsURLBatchSaver(),
-		urlStorage.AsURLGetter(),
+		urlGetter,
 		urlStorage.AsURLFetcher(),
 		urlStorage.AsURLDeleter(),
 		urlStorage.AsPinger(),
 		urlGenerator,
-		cfg.BaseURL,
+		cfg.PublicBaseURL(),
 	)
+	urlService.BaseURLs = cfg.PublicBaseURLs()
+	urlService.RejectSelfLinks = cfg.RejectSelfLinks
+	urlService.MaxTagsPerURL = cfg.MaxTagsPerURL
+	urlService.MaxTagLength = cfg.MaxTagLength
+	urlService.NormalizeURLs = cfg.NormalizeURLs
+	urlService.MaxURLsPerUser = cfg.MaxURLsPerUser
+	urlService.IdempotencyTTL = cfg.IdempotencyTTL
+	urlService.LookupPerUser = cfg.LookupPerUser
+	urlService.DomainBlocklist = cfg.ShortenBlocklist
+	urlService.DomainAllowlist = cfg.ShortenAllowlist
+	if cfg.VerifyReachable {
+		urlService.Reachability = reachability.NewChecker(cfg.ReachableTimeout, dialer)
+	}
+	if cfg.DeleteWebhookURL != "" {
+		urlService.Hooks = webhook.NewDeleteNotifier(cfg.DeleteWebhookURL, dialer.Client(webhook.DefaultTimeout),
+			webhook.WithRetry(cfg.WebhookRetries, 0))
+	}
+
+	var auditLogger audit.Logger = audit.Nop{}
+	if cfg.AuditLogPath != "" {
+		auditLogger, err = audit.NewFileLogger(cfg.AuditLogPath, audit.DefaultBufferSize)
+		if err != nil {
+			urlStorage.Close()
+			return nil, err
+		}
+	}
+	urlService.Audit = auditLogger
+
+	var adminHandler *handler.AdminHandler
+	if cfg.AdminEnabled {
+		adminHandler = handler.NewAdminHandler(service.NewAdminService(urlService, urlService))
+	}
 
 	handler := handler.NewURLHandler(
 		urlService,
@@ -38,10 +159,51 @@ func NewApp(cfg *config.Config) (*App, error) {
 		urlService,
 		urlService,
 		urlService,
-		cfg.BaseURL,
+		cfg.PublicBaseURL(),
+		handler.WithTrustProxyHeaders(cfg.TrustProxyHeaders),
+		handler.WithBaseURLHistory(cfg.BaseURLHistory...),
+		handler.WithBaseURLs(cfg.PublicBaseURLs()...),
+		handler.WithStorageKind(urlStorage.Kind()),
+		handler.WithRedirectStatus(cfg.RedirectStatus),
+		handler.WithPendingWritesThreshold(cfg.PendingWritesMax),
+		handler.WithRequireAuth(cfg.RequireAuth),
+		handler.WithTenants(cfg.TenantsEnabled, cfg.TenantHeader),
+		handler.WithMaxBatchSize(cfg.MaxBatchSize),
+		handler.WithDeletedRedirect(cfg.DeletedRedirect),
+		handler.WithCaseInsensitiveIDs(cfg.CaseInsensitive),
 	)
 
 	return &App{
 		Handler: handler,
+		Service: urlService,
+		Admin:   adminHandler,
+		storage: urlStorage,
+		audit:   auditLogger,
 	}, nil
-}
\ No newline at end of file
+}
+
+// outboundDialer builds the dialer shared by features that make outbound
+// requests. OUTBOUND_BLOCKED_NETWORKS replaces the default blocklist.
+func outboundDialer(cfg *config.Config) (*safehttp.Dialer, error) {
+	if len(cfg.OutboundBlocklist) == 0 {
+		return safehttp.NewDialer(), nil
+	}
+	blocked, err := safehttp.ParseNetworks(cfg.OutboundBlocklist)
+	if err != nil {
+		return nil, err
+	}
+	return safehttp.NewDialer(safehttp.WithBlockedNetworks(blocked...)), nil
+}
+
+// SelfTest runs the startup self-test against the configured storage.
+func (a *App) SelfTest(ctx context.Context) error {
+	return SelfTest(ctx, a.storage.AsURLSaver(), a.storage.AsURLGetter(), a.storage.AsURLDeleter(), a.storage.AsPinger())
+}
+
+func (a *App) Flush(ctx context.Context) error {
+	return a.storage.Flush(ctx)
+}
+
+func (a *App) Close() error {
+	return errors.Join(a.audit.Close(), a.storage.Close())
+}
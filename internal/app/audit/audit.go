@@ -0,0 +1,113 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	ActionShorten = "shorten"
+	ActionDelete  = "delete"
+	ActionRestore = "restore"
+	ActionUpdate  = "update"
+	ActionPurge   = "purge"
+)
+
+const DefaultBufferSize = 1024
+
+type Entry struct {
+	Time    time.Time `json:"time"`
+	Action  string    `json:"action"`
+	UserID  string    `json:"user_id"`
+	ShortID string    `json:"short_id"`
+}
+
+// Logger records mutating operations. Log must not block the caller.
+type Logger interface {
+	Log(entry Entry)
+	Close() error
+}
+
+type Nop struct{}
+
+func (Nop) Log(Entry) {}
+
+func (Nop) Close() error { return nil }
+
+// FileLogger appends entries to a file as JSON lines. Entries are queued on a
+// buffered channel and written by a background goroutine; when the buffer is
+// full new entries are dropped rather than stalling the request.
+type FileLogger struct {
+	file    *os.File
+	entries chan Entry
+	done    chan struct{}
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+func NewFileLogger(path string, bufferSize int) (*FileLogger, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open audit log: %w", err)
+	}
+	if bufferSize <= 0 {
+		bufferSize = DefaultBufferSize
+	}
+
+	l := &FileLogger{
+		file:    file,
+		entries: make(chan Entry, bufferSize),
+		done:    make(chan struct{}),
+	}
+	go l.run()
+	return l, nil
+}
+
+func (l *FileLogger) Log(entry Entry) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.closed {
+		return
+	}
+
+	select {
+	case l.entries <- entry:
+	default:
+		logrus.WithFields(logrus.Fields{
+			"action":   entry.Action,
+			"short_id": entry.ShortID,
+		}).Warn("Audit log buffer is full, entry dropped")
+	}
+}
+
+// Close writes out the queued entries and closes the file.
+func (l *FileLogger) Close() error {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil
+	}
+	l.closed = true
+	close(l.entries)
+	l.mu.Unlock()
+
+	<-l.done
+	return l.file.Close()
+}
+
+func (l *FileLogger) run() {
+	defer close(l.done)
+
+	encoder := json.NewEncoder(l.file)
+	for entry := range l.entries {
+		if err := encoder.Encode(entry); err != nil {
+			logrus.WithError(err).Error("Failed to write audit log entry")
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileLoggerWritesEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	logger, err := NewFileLogger(path, 4)
+	if err != nil {
+		t.Fatalf("NewFileLogger failed: %v", err)
+	}
+
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	logger.Log(Entry{Time: now, Action: ActionShorten, UserID: "user", ShortID: "abc"})
+	logger.Log(Entry{Time: now, Action: ActionDelete, UserID: "user", ShortID: "abc"})
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	logger.Log(Entry{Time: now, Action: ActionUpdate, UserID: "user", ShortID: "abc"})
+
+	entries := readEntries(t, path)
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(entries))
+	}
+	if entries[0].Action != ActionShorten || entries[1].Action != ActionDelete {
+		t.Errorf("Unexpected actions %q, %q", entries[0].Action, entries[1].Action)
+	}
+	if !entries[0].Time.Equal(now) || entries[0].UserID != "user" || entries[0].ShortID != "abc" {
+		t.Errorf("Unexpected entry %+v", entries[0])
+	}
+}
+
+func TestFileLoggerAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	for i := 0; i < 2; i++ {
+		logger, err := NewFileLogger(path, 0)
+		if err != nil {
+			t.Fatalf("NewFileLogger failed: %v", err)
+		}
+		logger.Log(Entry{Action: ActionShorten, ShortID: "abc"})
+		if err := logger.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+	}
+
+	if entries := readEntries(t, path); len(entries) != 2 {
+		t.Errorf("Expected entries from both runs, got %d", len(entries))
+	}
+}
+
+func readEntries(t *testing.T, path string) []Entry {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open audit log: %v", err)
+	}
+	defer file.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("Invalid audit line %q: %v", scanner.Text(), err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
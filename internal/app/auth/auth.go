@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/google/uuid"
 )
@@ -22,11 +23,43 @@ const (
 )
 
 const (
-	UserIDKey ContextKey = "userID"
+	UserIDKey  ContextKey = "userID"
+	NewUserKey ContextKey = "newUser"
 )
 
 var SecretKey = []byte("your-secret-key-change-this-in-production")
 
+type CookieOptions struct {
+	Secure   bool
+	Domain   string
+	SameSite http.SameSite
+}
+
+var Cookie = CookieOptions{SameSite: http.SameSiteLaxMode}
+
+// AllowAnonymous controls whether AuthMiddleware mints a new user for
+// requests without valid credentials. Closed deployments turn it off.
+var AllowAnonymous = true
+
+// AnonymousUserID, when set, replaces the freshly minted user of requests
+// without credentials, so anonymous links share one listing. It is never
+// written to a cookie and does not authenticate its holder.
+var AnonymousUserID string
+
+func ParseSameSite(value string) (http.SameSite, error) {
+	switch strings.ToLower(value) {
+	case "", "lax":
+		return http.SameSiteLaxMode, nil
+	case "strict":
+		return http.SameSiteStrictMode, nil
+	case "none":
+		return http.SameSiteNoneMode, nil
+	case "default":
+		return http.SameSiteDefaultMode, nil
+	}
+	return 0, fmt.Errorf("unknown SameSite mode %q", value)
+}
+
 const (
 	CookieName   = "user_id"
 	CookieMaxAge = 30 * 24 * 60 * 60
@@ -36,6 +69,15 @@ func GenerateUserID() string {
 	return uuid.New().String()
 }
 
+// AnonymousUser returns the user for a request without credentials and
+// whether it was newly minted and should be sent back in a cookie.
+func AnonymousUser() (string, bool) {
+	if AnonymousUserID != "" {
+		return AnonymousUserID, false
+	}
+	return GenerateUserID(), true
+}
+
 func SignData(data string) string {
 	h := hmac.New(sha256.New, SecretKey)
 	h.Write([]byte(data))
@@ -48,6 +90,17 @@ func VerifySignature(data, signature string) bool {
 }
 
 func GetUserIDFromCookie(r *http.Request) (string, error) {
+	userID, err := userIDFromSignedCookie(r)
+	if err == nil {
+		return userID, nil
+	}
+	if token, ok := bearerToken(r); ok {
+		return ParseJWT(token)
+	}
+	return "", err
+}
+
+func userIDFromSignedCookie(r *http.Request) (string, error) {
 	parts := make(map[CookiePartKey]string) 
 	for _, part := range []CookiePartKey{CookiePartID, CookiePartSign} {
 		cookie, err := r.Cookie(fmt.Sprintf("%s_%s", CookieName, part))
@@ -76,7 +129,9 @@ func SetUserIDCookie(w http.ResponseWriter, userID string) {
 		Path:     "/",
 		MaxAge:   CookieMaxAge,
 		HttpOnly: true,
-		SameSite: http.SameSiteLaxMode,
+		SameSite: Cookie.SameSite,
+		Secure:   Cookie.Secure,
+		Domain:   Cookie.Domain,
 	})
 
 	http.SetCookie(w, &http.Cookie{
@@ -85,7 +140,9 @@ func SetUserIDCookie(w http.ResponseWriter, userID string) {
 		Path:     "/",
 		MaxAge:   CookieMaxAge,
 		HttpOnly: true,
-		SameSite: http.SameSiteLaxMode,
+		SameSite: Cookie.SameSite,
+		Secure:   Cookie.Secure,
+		Domain:   Cookie.Domain,
 	})
 
 	http.SetCookie(w, &http.Cookie{
@@ -94,7 +151,9 @@ func SetUserIDCookie(w http.ResponseWriter, userID string) {
 		Path:     "/",
 		MaxAge:   CookieMaxAge,
 		HttpOnly: true,
-		SameSite: http.SameSiteLaxMode,
+		SameSite: Cookie.SameSite,
+		Secure:   Cookie.Secure,
+		Domain:   Cookie.Domain,
 	})
 }
 
@@ -111,13 +170,32 @@ func RequireAuth(next http.HandlerFunc) http.HandlerFunc {
 
 func AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        ctx := r.Context()
         userID, err := GetUserIDFromCookie(r)
+        if err != nil && !AllowAnonymous {
+            next.ServeHTTP(w, r)
+            return
+        }
         if err != nil {
-            userID = GenerateUserID()
-            SetUserIDCookie(w, userID)
+            var minted bool
+            userID, minted = AnonymousUser()
+            if minted {
+                SetUserIDCookie(w, userID)
+            }
+            ctx = context.WithValue(ctx, NewUserKey, true)
         }
 
-        ctx := context.WithValue(r.Context(), UserIDKey, userID)
+        ctx = context.WithValue(ctx, UserIDKey, userID)
         next.ServeHTTP(w, r.WithContext(ctx))
     })
 }
+
+func UserIDFromContext(ctx context.Context) (string, bool) {
+	userID, ok := ctx.Value(UserIDKey).(string)
+	return userID, ok && userID != ""
+}
+
+func IsNewUser(ctx context.Context) bool {
+	isNew, _ := ctx.Value(NewUserKey).(bool)
+	return isNew
+}
@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSetUserIDCookieUsesDefaults(t *testing.T) {
+	rec := httptest.NewRecorder()
+	SetUserIDCookie(rec, "user-1")
+
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 3 {
+		t.Fatalf("expected 3 cookies, got %d", len(cookies))
+	}
+	for _, c := range cookies {
+		if c.Secure {
+			t.Errorf("cookie %s: expected Secure to be unset", c.Name)
+		}
+		if c.Domain != "" {
+			t.Errorf("cookie %s: expected no Domain, got %q", c.Name, c.Domain)
+		}
+		if c.SameSite != http.SameSiteLaxMode {
+			t.Errorf("cookie %s: expected SameSite=Lax, got %v", c.Name, c.SameSite)
+		}
+		if !c.HttpOnly || c.Path != "/" {
+			t.Errorf("cookie %s: expected HttpOnly with path /, got %+v", c.Name, c)
+		}
+	}
+}
+
+func TestSetUserIDCookieReflectsConfiguration(t *testing.T) {
+	saved := Cookie
+	t.Cleanup(func() { Cookie = saved })
+	Cookie = CookieOptions{Secure: true, Domain: "example.com", SameSite: http.SameSiteStrictMode}
+
+	rec := httptest.NewRecorder()
+	SetUserIDCookie(rec, "user-1")
+
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 3 {
+		t.Fatalf("expected 3 cookies, got %d", len(cookies))
+	}
+	for _, c := range cookies {
+		if !c.Secure {
+			t.Errorf("cookie %s: expected Secure", c.Name)
+		}
+		if c.Domain != "example.com" {
+			t.Errorf("cookie %s: expected Domain example.com, got %q", c.Name, c.Domain)
+		}
+		if c.SameSite != http.SameSiteStrictMode {
+			t.Errorf("cookie %s: expected SameSite=Strict, got %v", c.Name, c.SameSite)
+		}
+	}
+}
+
+func TestParseSameSite(t *testing.T) {
+	tests := map[string]http.SameSite{
+		"":       http.SameSiteLaxMode,
+		"lax":    http.SameSiteLaxMode,
+		"Strict": http.SameSiteStrictMode,
+		"none":   http.SameSiteNoneMode,
+	}
+	for in, want := range tests {
+		got, err := ParseSameSite(in)
+		if err != nil {
+			t.Fatalf("ParseSameSite(%q): %v", in, err)
+		}
+		if got != want {
+			t.Errorf("ParseSameSite(%q) = %v, want %v", in, got, want)
+		}
+	}
+	if _, err := ParseSameSite("bogus"); err == nil {
+		t.Error("expected error for unknown mode")
+	}
+}
+
+func TestAuthMiddlewareAnonymousUsers(t *testing.T) {
+	saved := AllowAnonymous
+	t.Cleanup(func() { AllowAnonymous = saved })
+
+	for _, allow := range []bool{true, false} {
+		AllowAnonymous = allow
+
+		var userID string
+		var found bool
+		handler := AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, found = UserIDFromContext(r.Context())
+		}))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		minted := len(rec.Result().Cookies()) > 0
+		if minted != allow || found != allow {
+			t.Errorf("AllowAnonymous=%v: expected minted user %v, got cookies=%v user=%q", allow, allow, minted, userID)
+		}
+	}
+}
+
+func TestAuthMiddlewareSharedAnonymousUser(t *testing.T) {
+	saved := AnonymousUserID
+	t.Cleanup(func() { AnonymousUserID = saved })
+	AnonymousUserID = "public"
+
+	var userID string
+	var isNew bool
+	handler := AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, _ = UserIDFromContext(r.Context())
+		isNew = IsNewUser(r.Context())
+	}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if userID != "public" || !isNew {
+		t.Errorf("Expected the shared anonymous user, got %q (new=%v)", userID, isNew)
+	}
+	if cookies := rec.Result().Cookies(); len(cookies) != 0 {
+		t.Errorf("Expected no cookie for the shared user, got %v", cookies)
+	}
+}
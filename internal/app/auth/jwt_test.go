@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIssueAndParseJWT(t *testing.T) {
+	token, err := IssueJWT("user-1")
+	if err != nil {
+		t.Fatalf("IssueJWT failed: %v", err)
+	}
+
+	userID, err := ParseJWT(token)
+	if err != nil {
+		t.Fatalf("ParseJWT failed: %v", err)
+	}
+	if userID != "user-1" {
+		t.Errorf("Expected user-1, got %s", userID)
+	}
+}
+
+func TestParseJWTRejectsExpiredToken(t *testing.T) {
+	now := time.Now()
+	token, err := issueJWT("user-1", now.Add(-2*time.Hour), now.Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("issueJWT failed: %v", err)
+	}
+
+	if _, err := ParseJWT(token); !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("Expected ErrTokenExpired, got %v", err)
+	}
+}
+
+func TestParseJWTRejectsTamperedToken(t *testing.T) {
+	token, err := IssueJWT("user-1")
+	if err != nil {
+		t.Fatalf("IssueJWT failed: %v", err)
+	}
+	other, err := IssueJWT("user-2")
+	if err != nil {
+		t.Fatalf("IssueJWT failed: %v", err)
+	}
+
+	parts := strings.Split(token, ".")
+	otherParts := strings.Split(other, ".")
+	forged := parts[0] + "." + otherParts[1] + "." + parts[2]
+
+	for _, bad := range []string{forged, token + "x", "not-a-token", ""} {
+		if _, err := ParseJWT(bad); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("Expected ErrInvalidToken for %q, got %v", bad, err)
+		}
+	}
+}
+
+func TestGetUserIDFromCookieFallsBackToBearer(t *testing.T) {
+	token, err := IssueJWT("bearer-user")
+	if err != nil {
+		t.Fatalf("IssueJWT failed: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/user/urls", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	userID, err := GetUserIDFromCookie(req)
+	if err != nil {
+		t.Fatalf("Expected bearer token to authorize the request, got %v", err)
+	}
+	if userID != "bearer-user" {
+		t.Errorf("Expected bearer-user, got %s", userID)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token+"tampered")
+	if _, err := GetUserIDFromCookie(req); err == nil {
+		t.Error("Expected tampered bearer token to be rejected")
+	}
+}
+
+func TestGetUserIDFromCookiePrefersCookie(t *testing.T) {
+	rec := httptest.NewRecorder()
+	SetUserIDCookie(rec, "cookie-user")
+
+	req := httptest.NewRequest(http.MethodGet, "/api/user/urls", nil)
+	for _, c := range rec.Result().Cookies() {
+		req.AddCookie(c)
+	}
+	req.Header.Set("Authorization", "Bearer garbage")
+
+	userID, err := GetUserIDFromCookie(req)
+	if err != nil {
+		t.Fatalf("Expected cookie auth to keep working, got %v", err)
+	}
+	if userID != "cookie-user" {
+		t.Errorf("Expected cookie-user, got %s", userID)
+	}
+}
@@ -0,0 +1,35 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+
+	"github.com/AlenaMolokova/http/internal/app/service"
+)
+
+const CLIUserID = "cli"
+
+func ShortenCLI(ctx context.Context, svc *service.Service, urls []string, out io.Writer) error {
+	if len(urls) == 0 {
+		return fmt.Errorf("usage: shortener shorten <url> [url...]")
+	}
+
+	for _, originalURL := range urls {
+		if _, err := url.ParseRequestURI(originalURL); err != nil {
+			return fmt.Errorf("invalid URL %q: %w", originalURL, err)
+		}
+	}
+
+	for _, originalURL := range urls {
+		result, err := svc.ShortenURL(ctx, originalURL, CLIUserID)
+		if err != nil {
+			return fmt.Errorf("shorten %s: %w", originalURL, err)
+		}
+		if _, err := fmt.Fprintln(out, result.ShortURL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/AlenaMolokova/http/internal/app/generator"
+	"github.com/AlenaMolokova/http/internal/app/service"
+	"github.com/AlenaMolokova/http/internal/app/storage/memory"
+)
+
+func TestShortenCLIPrintsShortURLs(t *testing.T) {
+	store := memory.NewMemoryStorage()
+	svc := service.NewService(store, store, store, store, store, store, generator.NewGenerator(8), "http://localhost:8080")
+
+	var out bytes.Buffer
+	err := ShortenCLI(context.Background(), svc, []string{"https://cli.example.com/a", "https://cli.example.com/b"}, &out)
+	if err != nil {
+		t.Fatalf("ShortenCLI failed: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines, got %q", out.String())
+	}
+	for i, want := range []string{"https://cli.example.com/a", "https://cli.example.com/b"} {
+		shortID := strings.TrimPrefix(lines[i], "http://localhost:8080/")
+		if got, ok := store.Get(context.Background(), shortID); !ok || got != want {
+			t.Errorf("Line %d: expected %s to resolve to %s, got %q", i+1, lines[i], want, got)
+		}
+	}
+
+	urls, err := store.GetURLsByUserID(context.Background(), CLIUserID)
+	if err != nil {
+		t.Fatalf("Failed to list URLs: %v", err)
+	}
+	if len(urls) != 2 {
+		t.Errorf("Expected URLs to be owned by the CLI user, found %d", len(urls))
+	}
+}
+
+func TestShortenCLIRejectsInvalidInput(t *testing.T) {
+	store := memory.NewMemoryStorage()
+	svc := service.NewService(store, store, store, store, store, store, generator.NewGenerator(8), "http://localhost:8080")
+
+	var out bytes.Buffer
+	if err := ShortenCLI(context.Background(), svc, nil, &out); err == nil {
+		t.Error("Expected an error without URLs")
+	}
+	if err := ShortenCLI(context.Background(), svc, []string{"https://ok.example.com", "not a url"}, &out); err == nil {
+		t.Error("Expected an error for an invalid URL")
+	}
+	if out.Len() != 0 {
+		t.Errorf("Expected nothing to be printed, got %q", out.String())
+	}
+}
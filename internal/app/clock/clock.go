@@ -0,0 +1,21 @@
+package clock
+
+import "time"
+
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+var System Clock = systemClock{}
+
+type Func func() time.Time
+
+func (f Func) Now() time.Time {
+	return f()
+}
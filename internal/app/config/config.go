@@ -2,16 +2,85 @@ package config
 
 import (
 	"flag"
+	"fmt"
 	"log"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/AlenaMolokova/http/internal/app/safehttp"
 	"github.com/caarlos0/env/v9"
 )
 
 type Config struct {
-	ServerAddress string `env:"SERVER_ADDRESS" envDefault:"localhost:8080"`
-	BaseURL       string `env:"BASE_URL" envDefault:"http://localhost:8080"`
-	FileStoragePath string `env:"FILE_STORAGE_PATH" envDefault:"urls.json"`
-	DatabaseDSN     string `env:"DATABASE_DSN" envDefault:""`
+	ServerAddress     string        `env:"SERVER_ADDRESS" envDefault:"localhost:8080"`
+	BaseURL           string        `env:"BASE_URL" envDefault:"http://localhost:8080"`
+	FileStoragePath   string        `env:"FILE_STORAGE_PATH" envDefault:"urls.json"`
+	DatabaseDSN       string        `env:"DATABASE_DSN" envDefault:""`
+	CacheSize         int           `env:"CACHE_SIZE" envDefault:"10000"`
+	CacheNegativeTTL  time.Duration `env:"CACHE_NEGATIVE_TTL" envDefault:"5s"`
+	CacheTTL          time.Duration `env:"CACHE_TTL" envDefault:"30s"`
+	CacheWarmup       bool          `env:"CACHE_WARMUP" envDefault:"false"`
+	RejectSelfLinks   bool          `env:"REJECT_SELF_LINKS" envDefault:"false"`
+	TrustProxyHeaders bool          `env:"TRUST_PROXY_HEADERS" envDefault:"false"`
+	CompactOnLoad     bool          `env:"FILE_STORAGE_COMPACT_ON_LOAD" envDefault:"false"`
+	BatchChunkSize    int           `env:"BATCH_LOCK_CHUNK_SIZE" envDefault:"0"`
+	MaxTagsPerURL     int           `env:"MAX_TAGS_PER_URL" envDefault:"10"`
+	MaxTagLength      int           `env:"MAX_TAG_LENGTH" envDefault:"32"`
+	NormalizeURLs     bool          `env:"NORMALIZE_URLS" envDefault:"false"`
+	SQLitePath        string        `env:"SQLITE_PATH" envDefault:""`
+	SelfTest          bool          `env:"SELFTEST" envDefault:"false"`
+	MaxURLsPerUser    int           `env:"MAX_URLS_PER_USER" envDefault:"0"`
+	BaseURLHistory    []string      `env:"BASE_URL_HISTORY" envSeparator:","`
+	BaseURLs          []string      `env:"BASE_URLS" envSeparator:","`
+	ShortenBlocklist  []string      `env:"SHORTEN_BLOCKLIST" envSeparator:","`
+	ShortenAllowlist  []string      `env:"SHORTEN_ALLOWLIST" envSeparator:","`
+	AuthSecret        string        `env:"AUTH_SECRET" envDefault:""`
+	LogLevel          string        `env:"LOG_LEVEL" envDefault:"info"`
+	LogFormat         string        `env:"LOG_FORMAT" envDefault:"json"`
+	IdempotencyTTL    time.Duration `env:"IDEMPOTENCY_TTL" envDefault:"24h"`
+	ShortIDAlphabet   string        `env:"SHORT_ID_ALPHABET" envDefault:"default"`
+	CaseInsensitive   bool          `env:"CASE_INSENSITIVE_IDS" envDefault:"false"`
+	IDStrategy        string        `env:"ID_STRATEGY" envDefault:"random"`
+	LogFile           string        `env:"LOG_FILE" envDefault:""`
+	LogMaxSizeMB      int           `env:"LOG_MAX_SIZE_MB" envDefault:"100"`
+	LogMaxBackups     int           `env:"LOG_MAX_BACKUPS" envDefault:"5"`
+	LookupPerUser     bool          `env:"LOOKUP_PER_USER" envDefault:"false"`
+	MaxBodyBytes      int64         `env:"MAX_BODY_BYTES" envDefault:"1048576"`
+	MaxBatchBodyBytes int64         `env:"MAX_BATCH_BODY_BYTES" envDefault:"10485760"`
+	MaxBatchSize      int           `env:"MAX_BATCH_SIZE" envDefault:"1000"`
+	CookieSecure      bool          `env:"COOKIE_SECURE" envDefault:"false"`
+	CookieDomain      string        `env:"COOKIE_DOMAIN" envDefault:""`
+	CookieSameSite    string        `env:"COOKIE_SAMESITE" envDefault:"lax"`
+	RedirectStatus    int           `env:"REDIRECT_STATUS" envDefault:"307"`
+	BasePath          string        `env:"BASE_PATH" envDefault:""`
+	TrustedSubnet     string        `env:"TRUSTED_SUBNET" envDefault:""`
+	RequestTimeout    time.Duration `env:"REQUEST_TIMEOUT" envDefault:"30s"`
+	ReadTimeout       time.Duration `env:"READ_TIMEOUT" envDefault:"15s"`
+	WriteTimeout      time.Duration `env:"WRITE_TIMEOUT" envDefault:"60s"`
+	IdleTimeout       time.Duration `env:"IDLE_TIMEOUT" envDefault:"120s"`
+	SlowRequestMS     int           `env:"SLOW_REQUEST_MS" envDefault:"0"`
+	MaxConcurrent     int           `env:"MAX_CONCURRENT_REQUESTS" envDefault:"0"`
+	StrictStorage     bool          `env:"STRICT_STORAGE" envDefault:"false"`
+	PendingWritesMax  int           `env:"HEALTH_PENDING_WRITES_MAX" envDefault:"100"`
+	DBRetries         int           `env:"DB_RETRIES" envDefault:"3"`
+	DBRetryBackoff    time.Duration `env:"DB_RETRY_BACKOFF" envDefault:"50ms"`
+	DBBatchChunkSize  int           `env:"DB_BATCH_CHUNK_SIZE" envDefault:"1000"`
+	HitFlushInterval  time.Duration `env:"HIT_FLUSH_INTERVAL" envDefault:"1s"`
+	AdminEnabled      bool          `env:"ADMIN_ENABLED" envDefault:"true"`
+	RequireAuth       bool          `env:"REQUIRE_AUTH" envDefault:"false"`
+	AnonymousUserID   string        `env:"ANONYMOUS_USER_ID" envDefault:""`
+	AuditLogPath      string        `env:"AUDIT_LOG_PATH" envDefault:""`
+	VerifyReachable   bool          `env:"VERIFY_REACHABLE" envDefault:"false"`
+	ReachableTimeout  time.Duration `env:"VERIFY_REACHABLE_TIMEOUT" envDefault:"5s"`
+	OutboundBlocklist []string      `env:"OUTBOUND_BLOCKED_NETWORKS" envSeparator:","`
+	DeleteWebhookURL  string        `env:"DELETE_WEBHOOK_URL" envDefault:""`
+	DeletedRedirect   string        `env:"DELETED_REDIRECT_URL" envDefault:""`
+	WebhookRetries    int           `env:"DELETE_WEBHOOK_RETRIES" envDefault:"3"`
+	TenantsEnabled    bool          `env:"TENANTS_ENABLED" envDefault:"false"`
+	TenantHeader      string        `env:"TENANT_HEADER" envDefault:"X-Tenant"`
 }
 
 func NewConfig() *Config {
@@ -25,6 +94,7 @@ func NewConfig() *Config {
 	baseURL := flag.String("b", cfg.BaseURL, "Base URL for shortened URLs")
 	fileStoragePath := flag.String("f", cfg.FileStoragePath, "Path for URL storage file")
 	databaseDSN := flag.String("d", cfg.DatabaseDSN, "Database connection string")
+	selfTest := flag.Bool("selftest", cfg.SelfTest, "Run the startup self-test and exit")
 
 	flag.Parse()
 
@@ -32,6 +102,112 @@ func NewConfig() *Config {
 	cfg.BaseURL = *baseURL
 	cfg.FileStoragePath = *fileStoragePath
 	cfg.DatabaseDSN = *databaseDSN
+	cfg.SelfTest = *selfTest
+
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Некорректная конфигурация: %v", err)
+	}
 
 	return cfg
 }
+
+func (c *Config) Validate() error {
+	c.BaseURL = strings.TrimRight(c.BaseURL, "/")
+	if err := validateBaseURL(c.BaseURL); err != nil {
+		return fmt.Errorf("invalid base URL %q: %w", c.BaseURL, err)
+	}
+
+	host, port, err := net.SplitHostPort(c.ServerAddress)
+	if err != nil {
+		return fmt.Errorf("invalid server address %q: %w", c.ServerAddress, err)
+	}
+	if strings.ContainsAny(host, " /") {
+		return fmt.Errorf("invalid server address %q: bad host", c.ServerAddress)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+		return fmt.Errorf("invalid server address %q: bad port %q", c.ServerAddress, port)
+	}
+
+	c.BasePath = strings.Trim(c.BasePath, "/")
+	if c.BasePath != "" {
+		c.BasePath = "/" + c.BasePath
+		if strings.ContainsAny(c.BasePath, "?#{} ") {
+			return fmt.Errorf("invalid base path %q", c.BasePath)
+		}
+	}
+
+	if c.TrustedSubnet != "" {
+		if _, _, err := net.ParseCIDR(c.TrustedSubnet); err != nil {
+			return fmt.Errorf("invalid trusted subnet %q: %w", c.TrustedSubnet, err)
+		}
+	}
+
+	if _, err := safehttp.ParseNetworks(c.OutboundBlocklist); err != nil {
+		return err
+	}
+
+	if c.DeleteWebhookURL != "" {
+		if u, err := url.Parse(c.DeleteWebhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid delete webhook URL %q", c.DeleteWebhookURL)
+		}
+	}
+
+	if c.DeletedRedirect != "" {
+		if u, err := url.Parse(c.DeletedRedirect); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid deleted redirect URL %q", c.DeletedRedirect)
+		}
+	}
+
+	for i, base := range c.BaseURLHistory {
+		c.BaseURLHistory[i] = strings.TrimRight(base, "/")
+		if err := validateBaseURL(c.BaseURLHistory[i]); err != nil {
+			return fmt.Errorf("invalid base URL history entry %q: %w", base, err)
+		}
+	}
+
+	for i, base := range c.BaseURLs {
+		c.BaseURLs[i] = strings.TrimRight(strings.TrimSpace(base), "/")
+		if err := validateBaseURL(c.BaseURLs[i]); err != nil {
+			return fmt.Errorf("invalid base URLs entry %q: %w", base, err)
+		}
+	}
+	return nil
+}
+
+func (c *Config) PublicBaseURL() string {
+	return c.withBasePath(c.BaseURL)
+}
+
+// PublicBaseURLs returns the additional per-domain base URLs with the base
+// path applied, in the same form as PublicBaseURL.
+func (c *Config) PublicBaseURLs() []string {
+	bases := make([]string, 0, len(c.BaseURLs))
+	for _, base := range c.BaseURLs {
+		bases = append(bases, c.withBasePath(base))
+	}
+	return bases
+}
+
+func (c *Config) withBasePath(base string) string {
+	if c.BasePath == "" || strings.HasSuffix(base, c.BasePath) {
+		return base
+	}
+	return base + c.BasePath
+}
+
+func validateBaseURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("scheme must be http or https")
+	}
+	if u.Host == "" {
+		return fmt.Errorf("host is missing")
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return fmt.Errorf("query and fragment are not allowed")
+	}
+	return nil
+}
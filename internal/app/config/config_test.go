@@ -0,0 +1,117 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateAcceptsValidConfig(t *testing.T) {
+	cfg := &Config{
+		ServerAddress:  "localhost:8080",
+		BaseURL:        "https://sho.rt/",
+		BaseURLHistory: []string{"http://old.example.com/"},
+		BaseURLs:       []string{" https://ex.am/ "},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if cfg.BaseURL != "https://sho.rt" {
+		t.Errorf("Expected trailing slash to be trimmed, got %q", cfg.BaseURL)
+	}
+	if cfg.BaseURLHistory[0] != "http://old.example.com" {
+		t.Errorf("Expected history trailing slash to be trimmed, got %q", cfg.BaseURLHistory[0])
+	}
+	if cfg.BaseURLs[0] != "https://ex.am" {
+		t.Errorf("Expected base URLs to be trimmed, got %q", cfg.BaseURLs[0])
+	}
+
+	cfg = &Config{ServerAddress: ":8080", BaseURL: "http://localhost:8080/prefix"}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected an empty host and a path prefix to be accepted, got %v", err)
+	}
+}
+
+func TestValidateRejectsInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{"base URL without scheme", Config{ServerAddress: "localhost:8080", BaseURL: "localhost:8080"}},
+		{"base URL with other scheme", Config{ServerAddress: "localhost:8080", BaseURL: "ftp://example.com"}},
+		{"base URL without host", Config{ServerAddress: "localhost:8080", BaseURL: "http://"}},
+		{"base URL with query", Config{ServerAddress: "localhost:8080", BaseURL: "http://example.com?x=1"}},
+		{"empty base URL", Config{ServerAddress: "localhost:8080", BaseURL: ""}},
+		{"server address without port", Config{ServerAddress: "localhost", BaseURL: "http://localhost:8080"}},
+		{"server address with bad port", Config{ServerAddress: "localhost:http", BaseURL: "http://localhost:8080"}},
+		{"server address port out of range", Config{ServerAddress: "localhost:70000", BaseURL: "http://localhost:8080"}},
+		{"server address as URL", Config{ServerAddress: "http://localhost:8080", BaseURL: "http://localhost:8080"}},
+		{"bad trusted subnet", Config{ServerAddress: "localhost:8080", BaseURL: "http://localhost:8080", TrustedSubnet: "10.0.0.1"}},
+		{"bad history entry", Config{ServerAddress: "localhost:8080", BaseURL: "http://localhost:8080", BaseURLHistory: []string{"old.example.com"}}},
+		{"bad base URLs entry", Config{ServerAddress: "localhost:8080", BaseURL: "http://localhost:8080", BaseURLs: []string{"sh.rt"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			if err := cfg.Validate(); err == nil {
+				t.Errorf("Expected an error for %+v", tt.cfg)
+			}
+		})
+	}
+}
+
+func TestBasePath(t *testing.T) {
+	tests := []struct {
+		basePath string
+		baseURL  string
+		wantPath string
+		wantURL  string
+	}{
+		{"", "http://localhost:8080", "", "http://localhost:8080"},
+		{"shortener/", "http://localhost:8080", "/shortener", "http://localhost:8080/shortener"},
+		{"/shortener", "https://example.com/shortener/", "/shortener", "https://example.com/shortener"},
+	}
+	for _, tt := range tests {
+		cfg := &Config{ServerAddress: ":8080", BaseURL: tt.baseURL, BasePath: tt.basePath}
+		if err := cfg.Validate(); err != nil {
+			t.Fatalf("Validate(%q) failed: %v", tt.basePath, err)
+		}
+		if cfg.BasePath != tt.wantPath {
+			t.Errorf("BasePath %q: expected %q, got %q", tt.basePath, tt.wantPath, cfg.BasePath)
+		}
+		if got := cfg.PublicBaseURL(); got != tt.wantURL {
+			t.Errorf("PublicBaseURL for %q + %q: expected %q, got %q", tt.baseURL, tt.basePath, tt.wantURL, got)
+		}
+	}
+}
+
+func TestRedactedMasksSecrets(t *testing.T) {
+	tests := []struct {
+		name string
+		dsn  string
+		want string
+	}{
+		{"url", "postgres://app:s3cret@db:5432/urls?sslmode=disable", "postgres://app:REDACTED@db:5432/urls?sslmode=disable"},
+		{"url query", "postgres://db/urls?user=app&password=s3cret", "postgres://db/urls?password=REDACTED&user=app"},
+		{"key value", "host=db user=app password=s3cret dbname=urls", "host=db user=app password=REDACTED dbname=urls"},
+		{"quoted", "host=db password='s3 cret' dbname=urls", "host=db password=REDACTED dbname=urls"},
+		{"no password", "postgres://db/urls", "postgres://db/urls"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{DatabaseDSN: tt.dsn, AuthSecret: "hmac-s3cret"}
+			r := cfg.Redacted()
+			if r.DatabaseDSN != tt.want {
+				t.Errorf("Expected DSN %q, got %q", tt.want, r.DatabaseDSN)
+			}
+			if r.AuthSecret != "REDACTED" {
+				t.Errorf("Expected the auth secret to be masked, got %q", r.AuthSecret)
+			}
+			if s := cfg.String(); strings.Contains(s, "s3cret") || strings.Contains(s, "s3 cret") {
+				t.Errorf("String leaks a secret: %s", s)
+			}
+			if cfg.DatabaseDSN != tt.dsn {
+				t.Error("Redacted must not modify the original config")
+			}
+		})
+	}
+}
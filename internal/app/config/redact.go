@@ -0,0 +1,53 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+)
+
+const redacted = "REDACTED"
+
+// dsnPassword matches the password of a key=value connection string, quoted
+// or not.
+var dsnPassword = regexp.MustCompile(`(?i)(\bpassword\s*=\s*)('(?:[^'\\]|\\.)*'|\S+)`)
+
+// Redacted returns a copy of c that is safe to log: secrets are replaced and
+// passwords are masked in connection strings and URLs.
+func (c *Config) Redacted() Config {
+	r := *c
+	r.DatabaseDSN = redactDSN(c.DatabaseDSN)
+	r.DeleteWebhookURL = redactURL(c.DeleteWebhookURL)
+	if r.AuthSecret != "" {
+		r.AuthSecret = redacted
+	}
+	return r
+}
+
+func (c *Config) String() string {
+	return fmt.Sprintf("%+v", c.Redacted())
+}
+
+// redactDSN masks the password of a PostgreSQL DSN in either URL or
+// key=value form.
+func redactDSN(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.Scheme != "" {
+		return redactURL(dsn)
+	}
+	return dsnPassword.ReplaceAllString(dsn, "${1}"+redacted)
+}
+
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return redacted
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), redacted)
+	}
+	if q := u.Query(); q.Has("password") {
+		q.Set("password", redacted)
+		u.RawQuery = q.Encode()
+	}
+	return u.String()
+}
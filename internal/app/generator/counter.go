@@ -0,0 +1,80 @@
+package generator
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Sequence hands out increasing counter values starting at 1. Persistent
+// implementations must never return a value twice, even across restarts;
+// gaps are allowed.
+type Sequence interface {
+	NextID(ctx context.Context) (uint64, error)
+}
+
+// ContextGenerator is implemented by generators that depend on storage and
+// can therefore fail or be cancelled.
+type ContextGenerator interface {
+	GenerateContext(ctx context.Context) (string, error)
+}
+
+// CounterGenerator encodes the values of a Sequence in an alphabet, so IDs
+// are unique without collision checks and stay short while the count is low.
+type CounterGenerator struct {
+	seq     Sequence
+	letters string
+}
+
+// NewCounterGenerator encodes seq in alphabet; an empty alphabet uses
+// AlphabetDefault, which makes the IDs base62.
+func NewCounterGenerator(seq Sequence, alphabet string) *CounterGenerator {
+	if alphabet == "" {
+		alphabet = AlphabetDefault
+	}
+	return &CounterGenerator{seq: seq, letters: alphabet}
+}
+
+func (g *CounterGenerator) GenerateContext(ctx context.Context) (string, error) {
+	n, err := g.seq.NextID(ctx)
+	if err != nil {
+		return "", err
+	}
+	return g.encode(n), nil
+}
+
+// Generate returns an empty ID when the sequence fails.
+func (g *CounterGenerator) Generate() string {
+	id, err := g.GenerateContext(context.Background())
+	if err != nil {
+		logrus.WithError(err).Error("Failed to advance the short ID sequence")
+		return ""
+	}
+	return id
+}
+
+func (g *CounterGenerator) encode(n uint64) string {
+	base := uint64(len(g.letters))
+	var buf [64]byte
+	i := len(buf)
+	for {
+		i--
+		buf[i] = g.letters[n%base]
+		n /= base
+		if n == 0 {
+			break
+		}
+	}
+	return string(buf[i:])
+}
+
+// MemorySequence is an in-process Sequence for storages that do not survive
+// a restart anyway.
+type MemorySequence struct {
+	n atomic.Uint64
+}
+
+func (s *MemorySequence) NextID(ctx context.Context) (uint64, error) {
+	return s.n.Add(1), nil
+}
@@ -1,10 +1,23 @@
 package generator
 
 import (
+	"fmt"
 	"math/rand"
 	"time"
 )
 
+const (
+	AlphabetDefault     = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	AlphabetUnambiguous = "abcdefghijkmnpqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	AlphabetLowercase   = "abcdefghijklmnopqrstuvwxyz0123456789"
+)
+
+var presets = map[string]string{
+	"default":     AlphabetDefault,
+	"unambiguous": AlphabetUnambiguous,
+	"lowercase":   AlphabetLowercase,
+}
+
 type Generator interface {
 	Generate() string
 }
@@ -15,12 +28,37 @@ type SimpleGenerator struct {
 	rnd     *rand.Rand
 }
 
-func NewGenerator(length int) Generator {
-	return &SimpleGenerator{
-		letters: "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789",
+type Option func(*SimpleGenerator)
+
+func WithAlphabet(alphabet string) Option {
+	return func(g *SimpleGenerator) {
+		if alphabet != "" {
+			g.letters = alphabet
+		}
+	}
+}
+
+func Alphabet(name string) (string, error) {
+	if name == "" {
+		return AlphabetDefault, nil
+	}
+	alphabet, ok := presets[name]
+	if !ok {
+		return "", fmt.Errorf("unknown short ID alphabet %q", name)
+	}
+	return alphabet, nil
+}
+
+func NewGenerator(length int, opts ...Option) Generator {
+	g := &SimpleGenerator{
+		letters: AlphabetDefault,
 		length:  length,
 		rnd:     rand.New(rand.NewSource(time.Now().UnixNano())),
 	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
 }
 
 func (g *SimpleGenerator) Generate() string {
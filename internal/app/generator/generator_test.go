@@ -0,0 +1,96 @@
+package generator
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateUsesOnlyPresetCharacters(t *testing.T) {
+	for _, name := range []string{"default", "unambiguous", "lowercase"} {
+		t.Run(name, func(t *testing.T) {
+			alphabet, err := Alphabet(name)
+			if err != nil {
+				t.Fatalf("Alphabet(%q) failed: %v", name, err)
+			}
+			g := NewGenerator(8, WithAlphabet(alphabet))
+
+			for i := 0; i < 1000; i++ {
+				id := g.Generate()
+				if len(id) != 8 {
+					t.Fatalf("Expected length 8, got %q", id)
+				}
+				for _, c := range id {
+					if !strings.ContainsRune(alphabet, c) {
+						t.Fatalf("ID %q contains %q outside the %s alphabet", id, c, name)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestUnambiguousAlphabetExcludesConfusables(t *testing.T) {
+	for _, c := range "0O1lI" {
+		if strings.ContainsRune(AlphabetUnambiguous, c) {
+			t.Errorf("Unambiguous alphabet contains %q", c)
+		}
+	}
+}
+
+func TestGenerateCoversOddSizedAlphabet(t *testing.T) {
+	const alphabet = "abcdefg"
+	g := NewGenerator(1, WithAlphabet(alphabet))
+
+	counts := make(map[string]int)
+	const draws = 70000
+	for i := 0; i < draws; i++ {
+		counts[g.Generate()]++
+	}
+
+	expected := draws / len(alphabet)
+	for _, c := range alphabet {
+		n := counts[string(c)]
+		if n < expected*9/10 || n > expected*11/10 {
+			t.Errorf("Character %q drawn %d times, expected about %d", c, n, expected)
+		}
+	}
+}
+
+func TestAlphabetRejectsUnknownPreset(t *testing.T) {
+	if _, err := Alphabet("emoji"); err == nil {
+		t.Error("Expected an error for an unknown preset")
+	}
+}
+
+func TestCounterGeneratorYieldsIncreasingUniqueIDs(t *testing.T) {
+	g := NewCounterGenerator(&MemorySequence{}, AlphabetDefault)
+
+	decode := func(id string) uint64 {
+		var n uint64
+		for _, c := range id {
+			n = n*uint64(len(AlphabetDefault)) + uint64(strings.IndexRune(AlphabetDefault, c))
+		}
+		return n
+	}
+
+	seen := make(map[string]bool)
+	var prev uint64
+	for i := 0; i < 5000; i++ {
+		id := g.Generate()
+		if seen[id] {
+			t.Fatalf("Duplicate ID %q", id)
+		}
+		seen[id] = true
+		if n := decode(id); n <= prev {
+			t.Fatalf("ID %q (%d) does not follow %d", id, n, prev)
+		} else {
+			prev = n
+		}
+	}
+	if first := NewCounterGenerator(&MemorySequence{}, "").Generate(); first != "b" {
+		t.Errorf("Expected the first base62 ID to be %q, got %q", "b", first)
+	}
+	if len(g.Generate()) > 3 {
+		t.Error("Expected early counter IDs to stay short")
+	}
+}
@@ -0,0 +1,16 @@
+package generator
+
+// reservedIDs are first path segments taken by other routes. A short ID
+// equal to one of them could never be reached.
+var reservedIDs = map[string]bool{
+	"api":     true,
+	"ping":    true,
+	"healthz": true,
+	"livez":   true,
+}
+
+// Reserved reports whether id collides with a route and must be neither
+// generated nor claimed as an alias.
+func Reserved(id string) bool {
+	return reservedIDs[id]
+}
@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/AlenaMolokova/http/internal/app/middleware"
+	"github.com/AlenaMolokova/http/internal/app/models"
+	"github.com/AlenaMolokova/http/internal/app/respond"
+	"github.com/sirupsen/logrus"
+)
+
+type AdminHandler struct {
+	admin  models.Admin
+	backup *BackupHandler
+}
+
+func NewAdminHandler(admin models.Admin) *AdminHandler {
+	return &AdminHandler{admin: admin, backup: NewBackupHandler(admin)}
+}
+
+func (h *AdminHandler) HandleStats(w http.ResponseWriter, r *http.Request) {
+	logrus.Info("Handling admin stats request")
+
+	stats, err := h.admin.Stats(r.Context())
+	if err != nil {
+		logrus.WithError(err).Error("Failed to collect stats")
+		writeStorageError(w, err, "Failed to collect stats")
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, stats)
+}
+
+func (h *AdminHandler) HandleExport(w http.ResponseWriter, r *http.Request) {
+	h.backup.HandleExport(w, r)
+}
+
+func (h *AdminHandler) HandleImport(w http.ResponseWriter, r *http.Request) {
+	h.backup.HandleImport(w, r)
+}
+
+func (h *AdminHandler) HandleRestore(w http.ResponseWriter, r *http.Request) {
+	logrus.Info("Handling admin restore request")
+	if r.Body == nil {
+		writeJSONError(w, http.StatusBadRequest, "empty_body", "Empty request body")
+		return
+	}
+	defer r.Body.Close()
+
+	var req models.AdminRestoreRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		if middleware.IsBodyTooLarge(err) {
+			writeJSONError(w, http.StatusRequestEntityTooLarge, "body_too_large", "Request body too large")
+			return
+		}
+		logrus.WithError(err).Error("Invalid JSON format")
+		writeJSONError(w, http.StatusBadRequest, "invalid_json", "Invalid JSON format")
+		return
+	}
+	if len(req.IDs) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "empty_batch", "Empty list of URLs")
+		return
+	}
+
+	restored, err := h.admin.Restore(r.Context(), req.IDs)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to restore URLs")
+		writeStorageError(w, err, "Failed to restore URLs")
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, models.AdminRestoreResponse{Restored: restored})
+}
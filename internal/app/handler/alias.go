@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/AlenaMolokova/http/internal/app/generator"
+	"github.com/AlenaMolokova/http/internal/app/models"
+	"github.com/AlenaMolokova/http/internal/app/respond"
+	"github.com/AlenaMolokova/http/internal/app/tenant"
+	"github.com/sirupsen/logrus"
+)
+
+const maxAliasLength = 64
+
+// validAlias reports whether alias can be used as a short ID: 1-64 ASCII
+// letters, digits or dashes, and not a reserved route name.
+func validAlias(alias string) bool {
+	if alias == "" || len(alias) > maxAliasLength || generator.Reserved(alias) {
+		return false
+	}
+	for _, c := range alias {
+		if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (c < '0' || c > '9') && c != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+func (h *RedirectHandler) HandleAvailable(w http.ResponseWriter, r *http.Request) {
+	logrus.Info("Handling alias availability request")
+
+	r, err := h.opts.withTenant(r, h.baseURL)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_tenant", "Invalid tenant")
+		return
+	}
+	ctx := r.Context()
+
+	alias := r.URL.Query().Get("alias")
+	if h.opts.caseInsensitive {
+		alias = strings.ToLower(alias)
+	}
+	if !validAlias(alias) {
+		writeJSONError(w, http.StatusBadRequest, "invalid_alias", "Alias must be 1-64 letters, digits or dashes and not a reserved name")
+		return
+	}
+
+	shortID := tenant.Join(tenant.FromContext(ctx), alias)
+	var taken bool
+	if checker, ok := h.redirector.(models.ShortIDChecker); ok {
+		if taken, err = checker.ShortIDExists(ctx, shortID); err != nil {
+			logrus.WithError(err).Error("Failed to check alias availability")
+			writeStorageError(w, err, "Failed to check alias availability")
+			return
+		}
+	} else {
+		_, taken = h.redirector.Get(ctx, shortID)
+	}
+
+	respond.JSON(w, http.StatusOK, models.AvailabilityResponse{Available: !taken})
+}
@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/AlenaMolokova/http/internal/app/middleware"
+	"github.com/AlenaMolokova/http/internal/app/models"
+	"github.com/AlenaMolokova/http/internal/app/respond"
+	"github.com/sirupsen/logrus"
+)
+
+type BackupHandler struct {
+	store models.BackupStore
+}
+
+func NewBackupHandler(store models.BackupStore) *BackupHandler {
+	return &BackupHandler{store}
+}
+
+func (h *BackupHandler) supported(w http.ResponseWriter) bool {
+	if h.store == nil {
+		writeJSONError(w, http.StatusNotImplemented, "not_supported", models.ErrBackupNotSupported.Error())
+		return false
+	}
+	return true
+}
+
+func (h *BackupHandler) HandleExport(w http.ResponseWriter, r *http.Request) {
+	logrus.Info("Handling export request")
+	if !h.supported(w) {
+		return
+	}
+
+	urls, err := h.store.Export(r.Context())
+	if errors.Is(err, models.ErrBackupNotSupported) {
+		writeJSONError(w, http.StatusNotImplemented, "not_supported", err.Error())
+		return
+	}
+	if err != nil {
+		writeStorageError(w, err, "Failed to export URLs")
+		return
+	}
+	if urls == nil {
+		urls = []models.UserURL{}
+	}
+
+	respond.JSON(w, http.StatusOK, urls)
+}
+
+func (h *BackupHandler) HandleImport(w http.ResponseWriter, r *http.Request) {
+	logrus.Info("Handling import request")
+	if !h.supported(w) {
+		return
+	}
+	if r.Body == nil {
+		writeJSONError(w, http.StatusBadRequest, "empty_body", "Empty request body")
+		return
+	}
+	defer r.Body.Close()
+
+	var urls []models.UserURL
+	if err := json.NewDecoder(r.Body).Decode(&urls); err != nil {
+		if middleware.IsBodyTooLarge(err) {
+			writeJSONError(w, http.StatusRequestEntityTooLarge, "body_too_large", "Request body too large")
+			return
+		}
+		logrus.WithError(err).Error("Invalid JSON format")
+		writeJSONError(w, http.StatusBadRequest, "invalid_json", "Invalid JSON format")
+		return
+	}
+
+	for _, url := range urls {
+		if url.ShortURL == "" || url.OriginalURL == "" {
+			writeJSONError(w, http.StatusBadRequest, "validation_failed", "Every entry needs short_url and original_url")
+			return
+		}
+	}
+
+	err := h.store.Import(r.Context(), urls)
+	if errors.Is(err, models.ErrBackupNotSupported) {
+		writeJSONError(w, http.StatusNotImplemented, "not_supported", err.Error())
+		return
+	}
+	if err != nil {
+		writeStorageError(w, err, "Failed to import URLs")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+func (h *ShortenHandler) effectiveBaseURL(r *http.Request) string {
+	if !h.opts.trustProxyHeaders {
+		if base, ok := matchBaseURL(h.opts.baseURLs, r.Host); ok {
+			return base
+		}
+		return h.baseURL
+	}
+
+	proto, host := parseForwarded(r.Header.Get("Forwarded"))
+	if proto == "" {
+		proto = firstHeaderValue(r.Header.Get("X-Forwarded-Proto"))
+	}
+	if host == "" {
+		host = firstHeaderValue(r.Header.Get("X-Forwarded-Host"))
+	}
+	if host == "" {
+		host = r.Host
+	}
+	if host == "" {
+		return h.baseURL
+	}
+	if proto == "" {
+		proto = "http"
+		if r.TLS != nil {
+			proto = "https"
+		}
+	}
+
+	var path string
+	if base, err := url.Parse(h.baseURL); err == nil {
+		path = strings.TrimSuffix(base.Path, "/")
+	}
+	return strings.ToLower(proto) + "://" + host + path
+}
+
+func matchBaseURL(bases []string, host string) (string, bool) {
+	if host == "" {
+		return "", false
+	}
+	hostname := host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		hostname = h
+	}
+	for _, base := range bases {
+		u, err := url.Parse(base)
+		if err != nil {
+			continue
+		}
+		if strings.EqualFold(u.Host, host) || (u.Port() == "" && strings.EqualFold(u.Hostname(), hostname)) {
+			return strings.TrimRight(base, "/"), true
+		}
+	}
+	return "", false
+}
+
+func parseForwarded(header string) (proto, host string) {
+	if header == "" {
+		return "", ""
+	}
+	first := strings.Split(header, ",")[0]
+	for _, pair := range strings.Split(first, ";") {
+		key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			continue
+		}
+		value = strings.Trim(value, `"`)
+		switch strings.ToLower(key) {
+		case "proto":
+			proto = value
+		case "host":
+			host = value
+		}
+	}
+	return proto, host
+}
+
+func firstHeaderValue(header string) string {
+	return strings.TrimSpace(strings.Split(header, ",")[0])
+}
+
+func (h *RedirectHandler) extractShortID(value string) string {
+	bases := append([]string{h.baseURL}, h.opts.baseURLs...)
+	for _, base := range append(bases, h.opts.baseURLHistory...) {
+		base = strings.TrimRight(strings.TrimSpace(base), "/")
+		if base == "" {
+			continue
+		}
+		if shortID, ok := strings.CutPrefix(value, base+"/"); ok {
+			return shortID
+		}
+	}
+	return value
+}
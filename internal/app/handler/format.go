@@ -0,0 +1,28 @@
+package handler
+
+import (
+	"net/http"
+)
+
+const (
+	formatURL = "url"
+	formatID  = "id"
+)
+
+func responseFormat(r *http.Request) (string, bool) {
+	switch format := r.URL.Query().Get("format"); format {
+	case "", formatURL:
+		return formatURL, true
+	case formatID:
+		return formatID, true
+	default:
+		return format, false
+	}
+}
+
+func formatShortURL(shortURL, format string) string {
+	if format == formatID {
+		return shortIDFromURL(shortURL)
+	}
+	return shortURL
+}
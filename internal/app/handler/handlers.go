@@ -1,14 +1,22 @@
 package handler
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
-	"github.com/AlenaMolokova/http/internal/app/auth"
+	"github.com/AlenaMolokova/http/internal/app/logging"
+	"github.com/AlenaMolokova/http/internal/app/middleware"
 	"github.com/AlenaMolokova/http/internal/app/models"
+	"github.com/AlenaMolokova/http/internal/app/respond"
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
 )
@@ -17,12 +25,14 @@ type ShortenHandler struct {
 	shortener models.URLShortener
 	batch     models.BatchURLShortener
 	baseURL   string
+	opts      options
 }
 
 type RedirectHandler struct {
 	redirector models.URLGetter
 	fetcher    models.URLFetcher
 	baseURL    string
+	opts       options
 }
 
 type UserURLsHandler struct {
@@ -34,23 +44,28 @@ type DeleteHandler struct {
 }
 
 type PingHandler struct {
-	pinger models.Pinger
+	pinger  models.Pinger
+	opts    options
+	started time.Time
 }
 
+type AuthHandler struct{}
+
 type URLHandler struct {
 	shorten  *ShortenHandler
 	redirect *RedirectHandler
 	userURLs *UserURLsHandler
 	delete   *DeleteHandler
 	ping     *PingHandler
+	auth     *AuthHandler
 }
 
-func NewShortenHandler(shortener models.URLShortener, batch models.BatchURLShortener, baseURL string) *ShortenHandler {
-	return &ShortenHandler{shortener, batch, baseURL}
+func NewShortenHandler(shortener models.URLShortener, batch models.BatchURLShortener, baseURL string, opts ...Option) *ShortenHandler {
+	return &ShortenHandler{shortener, batch, baseURL, newOptions(opts)}
 }
 
-func NewRedirectHandler(redirector models.URLGetter, fetcher models.URLFetcher, baseURL string) *RedirectHandler {
-	return &RedirectHandler{redirector, fetcher, baseURL}
+func NewRedirectHandler(redirector models.URLGetter, fetcher models.URLFetcher, baseURL string, opts ...Option) *RedirectHandler {
+	return &RedirectHandler{redirector, fetcher, baseURL, newOptions(opts)}
 }
 
 func NewUserURLsHandler(fetcher models.URLFetcher) *UserURLsHandler {
@@ -61,29 +76,66 @@ func NewDeleteHandler(deleter models.URLDeleter) *DeleteHandler {
 	return &DeleteHandler{deleter}
 }
 
-func NewPingHandler(pinger models.Pinger) *PingHandler {
-	return &PingHandler{pinger}
+func NewPingHandler(pinger models.Pinger, opts ...Option) *PingHandler {
+	return &PingHandler{pinger, newOptions(opts), time.Now()}
+}
+
+func NewAuthHandler() *AuthHandler {
+	return &AuthHandler{}
 }
 
-func NewURLHandler(shortener models.URLShortener, batch models.BatchURLShortener, getter models.URLGetter, fetcher models.URLFetcher, deleter models.URLDeleter, pinger models.Pinger, baseURL string) *URLHandler {
+func NewURLHandler(shortener models.URLShortener, batch models.BatchURLShortener, getter models.URLGetter, fetcher models.URLFetcher, deleter models.URLDeleter, pinger models.Pinger, baseURL string, opts ...Option) *URLHandler {
 	return &URLHandler{
-		shorten:  NewShortenHandler(shortener, batch, baseURL),
-		redirect: NewRedirectHandler(getter, fetcher, baseURL),
+		shorten:  NewShortenHandler(shortener, batch, baseURL, opts...),
+		redirect: NewRedirectHandler(getter, fetcher, baseURL, opts...),
 		userURLs: NewUserURLsHandler(fetcher),
 		delete:   NewDeleteHandler(deleter),
-		ping:     NewPingHandler(pinger),
+		ping:     NewPingHandler(pinger, opts...),
+		auth:     NewAuthHandler(),
+	}
+}
+
+func writeJSONError(w http.ResponseWriter, status int, code, message string) {
+	respond.JSON(w, status, models.APIError{Code: code, Message: message})
+}
+// writeStorageError answers a failed storage call: 503 when the request ran
+// past its deadline, 500 otherwise.
+func writeStorageError(w http.ResponseWriter, err error, message string) {
+	if errors.Is(err, context.DeadlineExceeded) {
+		writeJSONError(w, http.StatusServiceUnavailable, "timeout", "Request timed out")
+		return
+	}
+	writeJSONError(w, http.StatusInternalServerError, "internal_error", message)
+}
+
+// storageErrorStatus is the plain-text counterpart of writeStorageError.
+func storageErrorStatus(err error) int {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusServiceUnavailable
 	}
+	return http.StatusInternalServerError
 }
 
+
 func (h *ShortenHandler) HandleShortenURL(w http.ResponseWriter, r *http.Request) {
 	logrus.Info("Handling shorten request")
-    ctx := r.Context()
 
-    userID, err := auth.GetUserIDFromCookie(r)
+    r, err := h.opts.withTenant(r, h.baseURL)
     if err != nil {
-        logrus.WithError(err).Warn("No valid cookie found, generating new user ID")
-        userID = auth.GenerateUserID()
-        auth.SetUserIDCookie(w, userID)
+        http.Error(w, "Invalid tenant", http.StatusBadRequest)
+        return
+    }
+    ctx := r.Context()
+
+    userID, ok := h.shortenUserID(w, r)
+    if !ok {
+        return
+    }
+
+    format, ok := responseFormat(r)
+    if !ok {
+        http.Error(w, "Unknown response format", http.StatusBadRequest)
+        return
     }
 
     contentType := r.Header.Get("Content-Type")
@@ -93,6 +145,10 @@ func (h *ShortenHandler) HandleShortenURL(w http.ResponseWriter, r *http.Request
     }
 
     body, err := io.ReadAll(r.Body)
+    if middleware.IsBodyTooLarge(err) {
+        http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+        return
+    }
     if err != nil {
         logrus.WithError(err).Error("Failed to read request body")
         http.Error(w, "Failed to read request body", http.StatusBadRequest)
@@ -112,267 +168,990 @@ func (h *ShortenHandler) HandleShortenURL(w http.ResponseWriter, r *http.Request
         return
     }
 
-    result, err := h.shortener.ShortenURL(ctx, originalURL, userID)
+    result, err := h.shortener.ShortenURLWithBase(ctx, originalURL, userID, h.effectiveBaseURL(r))
+    if errors.Is(err, models.ErrSelfLink) {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    if errors.Is(err, models.ErrDomainNotAllowed) {
+        http.Error(w, err.Error(), http.StatusForbidden)
+        return
+    }
+    if errors.Is(err, models.ErrUnreachable) {
+        http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+        return
+    }
+    if errors.Is(err, models.ErrURLLimitExceeded) {
+        http.Error(w, err.Error(), http.StatusForbidden)
+        return
+    }
     if err != nil {
         logrus.WithError(err).Error("Failed to shorten URL")
         cleanErr := strings.TrimSpace(err.Error())
-        http.Error(w, cleanErr, http.StatusInternalServerError)
+        http.Error(w, cleanErr, storageErrorStatus(err))
         return
     }
 
-    w.Header().Set("Content-Type", "text/plain")
-    if result.IsNew {
-        w.WriteHeader(http.StatusCreated)
-    } else {
-        w.WriteHeader(http.StatusConflict)
-    }
-    if _, err := io.WriteString(w, result.ShortURL); err != nil {
-        logrus.WithError(err).Error("Failed to write response")
+    logging.SetShortID(ctx, shortIDFromURL(result.ShortURL))
+    if prefersJSON(r) {
+        writeShortenJSON(w, result, format)
+        return
     }
+
+    respond.Text(w, shortenStatus(w, result), formatShortURL(result.ShortURL, format))
 }
 
 func (h *ShortenHandler) HandleShortenURLJSON(w http.ResponseWriter, r *http.Request) {
 	logrus.Info("Handling shorten JSON request")
-	ctx := r.Context()
 
-	userID, err := auth.GetUserIDFromCookie(r)
+	r, err := h.opts.withTenant(r, h.baseURL)
 	if err != nil {
-		logrus.WithError(err).Warn("No valid cookie found, generating new user ID")
-		userID = auth.GenerateUserID()
-		auth.SetUserIDCookie(w, userID)
+		writeJSONError(w, http.StatusBadRequest, "invalid_tenant", "Invalid tenant")
+		return
+	}
+
+	userID, ok := h.shortenUserID(w, r)
+	if !ok {
+		return
 	}
 
+	h.withIdempotency(w, r, userID, func(w http.ResponseWriter, r *http.Request) {
+		h.shortenJSON(w, r, userID)
+	})
+}
+
+func (h *ShortenHandler) shortenJSON(w http.ResponseWriter, r *http.Request, userID string) {
+	ctx := r.Context()
+
 	if r.Body == nil {
-		http.Error(w, "Empty request body", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "empty_body", "Empty request body")
 		return
 	}
 	defer r.Body.Close()
 
-	w.Header().Set("Content-Type", "application/json")
+	format, ok := responseFormat(r)
+	if !ok {
+		writeJSONError(w, http.StatusBadRequest, "invalid_format", "Unknown response format")
+		return
+	}
 
 	var req models.ShortenRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		logrus.WithError(err).Error("Invalid JSON format")
-		w.WriteHeader(http.StatusBadRequest)
-		if err := json.NewEncoder(w).Encode(map[string]string{"error": "Invalid JSON format"}); err != nil {
-			logrus.WithError(err).Error("Failed to encode error response")
+		if middleware.IsBodyTooLarge(err) {
+			writeJSONError(w, http.StatusRequestEntityTooLarge, "body_too_large", "Request body too large")
+			return
 		}
+		logrus.WithError(err).Error("Invalid JSON format")
+		writeJSONError(w, http.StatusBadRequest, "invalid_json", "Invalid JSON format")
 		return
 	}
 
+	req.URL = strings.TrimSpace(req.URL)
 	if req.URL == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		if err := json.NewEncoder(w).Encode(map[string]string{"error": "URL cannot be empty"}); err != nil {
-			logrus.WithError(err).Error("Failed to encode error response")
-		}
+		writeJSONError(w, http.StatusBadRequest, "empty_url", "URL cannot be empty")
 		return
 	}
 
 	if _, err := url.Parse(req.URL); err != nil {
 		logrus.WithError(err).Error("Invalid URL format")
-		w.WriteHeader(http.StatusBadRequest)
-		if err := json.NewEncoder(w).Encode(map[string]string{"error": "Invalid URL format"}); err != nil {
-			logrus.WithError(err).Error("Failed to encode error response")
-		}
+		writeJSONError(w, http.StatusBadRequest, "invalid_url", "Invalid URL format")
 		return
 	}
 
-	result, err := h.shortener.ShortenURL(ctx, req.URL, userID)
+	var tagger models.URLTagger
+	if len(req.Tags) > 0 {
+		validator, canValidate := h.shortener.(models.TagValidator)
+		var canTag bool
+		tagger, canTag = h.shortener.(models.URLTagger)
+		if !canValidate || !canTag {
+			writeJSONError(w, http.StatusNotImplemented, "not_supported", "Tags are not supported")
+			return
+		}
+		if err := validator.ValidateTags(req.Tags); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid_tags", err.Error())
+			return
+		}
+	}
+
+	var result models.ShortenResult
+	var err error
+	if req.Alias != "" {
+		if h.opts.caseInsensitive {
+			req.Alias = strings.ToLower(req.Alias)
+		}
+		if !validAlias(req.Alias) || req.Password != "" {
+			writeJSONError(w, http.StatusBadRequest, "invalid_alias", "Alias must be 1-64 letters, digits or dashes, not a reserved name, and without a password")
+			return
+		}
+		aliased, ok := h.shortener.(models.AliasShortener)
+		if !ok {
+			writeJSONError(w, http.StatusNotImplemented, "not_supported", "Custom aliases are not supported")
+			return
+		}
+		result, err = aliased.ShortenWithAlias(ctx, req.URL, userID, h.effectiveBaseURL(r), req.Alias)
+	} else if req.Password != "" {
+		protected, ok := h.shortener.(models.ProtectedURLShortener)
+		if !ok {
+			writeJSONError(w, http.StatusNotImplemented, "not_supported", "Password-protected URLs are not supported")
+			return
+		}
+		result, err = protected.ShortenProtectedURL(ctx, req.URL, userID, h.effectiveBaseURL(r), req.Password)
+	} else {
+		result, err = h.shortener.ShortenURLWithBase(ctx, req.URL, userID, h.effectiveBaseURL(r))
+	}
+	if errors.Is(err, models.ErrSelfLink) {
+		writeJSONError(w, http.StatusBadRequest, "self_link", err.Error())
+		return
+	}
+	if errors.Is(err, models.ErrDomainNotAllowed) {
+		writeJSONError(w, http.StatusForbidden, "domain_not_allowed", err.Error())
+		return
+	}
+	if errors.Is(err, models.ErrUnreachable) {
+		writeJSONError(w, http.StatusUnprocessableEntity, "unreachable", err.Error())
+		return
+	}
+	if errors.Is(err, models.ErrURLLimitExceeded) {
+		writeJSONError(w, http.StatusForbidden, "url_limit_exceeded", err.Error())
+		return
+	}
+	if errors.Is(err, models.ErrAliasTaken) {
+		writeJSONError(w, http.StatusConflict, "alias_taken", err.Error())
+		return
+	}
+	if errors.Is(err, models.ErrAliasNotSupported) {
+		writeJSONError(w, http.StatusNotImplemented, "not_supported", err.Error())
+		return
+	}
 	if err != nil {
 		logrus.WithError(err).Error("Failed to shorten URL")
-		w.WriteHeader(http.StatusInternalServerError)
-		if err := json.NewEncoder(w).Encode(map[string]string{"error": "Failed to shorten URL"}); err != nil {
-			logrus.WithError(err).Error("Failed to encode error response")
-		}
+		writeStorageError(w, err, "Failed to shorten URL")
 		return
 	}
 
-	resp := models.ShortenResponse{Result: result.ShortURL}
-	if result.IsNew {
-		w.WriteHeader(http.StatusCreated)
-	} else {
-		w.WriteHeader(http.StatusConflict)
+	shortID := shortIDFromURL(result.ShortURL)
+	logging.SetShortID(ctx, shortID)
+
+	if tagger != nil && result.IsNew {
+		if err := tagger.SetTags(ctx, shortID, req.Tags, userID); err != nil {
+			// The link is already saved and a retry would be answered
+			// with it, so report it rather than hide it behind the error.
+			logrus.WithError(err).Error("Failed to tag URL")
+			respond.JSON(w, storageErrorStatus(err), models.APIError{
+				Code:    "tags_not_saved",
+				Message: "URL was shortened but its tags were not saved",
+				Result:  formatShortURL(result.ShortURL, format),
+			})
+			return
+		}
 	}
-	if err := json.NewEncoder(w).Encode(resp); err != nil {
-		logrus.WithError(err).Error("Failed to encode response")
+	writeShortenJSON(w, result, format)
+}
+
+func writeShortenJSON(w http.ResponseWriter, result models.ShortenResult, format string) {
+	respond.JSON(w, shortenStatus(w, result), models.ShortenResponse{Result: formatShortURL(result.ShortURL, format)})
+}
+
+// shortenStatus is 201 for a new short URL, with Location pointing at it, and
+// 409 when the URL was already shortened.
+func shortenStatus(w http.ResponseWriter, result models.ShortenResult) int {
+	if result.IsNew {
+		w.Header().Set("Location", result.ShortURL)
+		return http.StatusCreated
 	}
+	return http.StatusConflict
 }
 
 func (h *ShortenHandler) HandleBatchShortenURL(w http.ResponseWriter, r *http.Request) {
 	logrus.Info("Handling batch shorten request")
-	ctx := r.Context()
 
-	userID, err := auth.GetUserIDFromCookie(r)
+	r, err := h.opts.withTenant(r, h.baseURL)
 	if err != nil {
-		logrus.WithError(err).Warn("No valid cookie found, generating new user ID")
-		userID = auth.GenerateUserID()
-		auth.SetUserIDCookie(w, userID)
+		writeJSONError(w, http.StatusBadRequest, "invalid_tenant", "Invalid tenant")
+		return
+	}
+
+	userID, ok := h.shortenUserID(w, r)
+	if !ok {
+		return
 	}
 
+	h.withIdempotency(w, r, userID, func(w http.ResponseWriter, r *http.Request) {
+		h.shortenBatch(w, r, userID)
+	})
+}
+
+func (h *ShortenHandler) shortenBatch(w http.ResponseWriter, r *http.Request, userID string) {
+	ctx := r.Context()
+
 	if r.Body == nil {
-		http.Error(w, "Empty request body", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "empty_body", "Empty request body")
 		return
 	}
 	defer r.Body.Close()
 
-	w.Header().Set("Content-Type", "application/json")
+	format, ok := responseFormat(r)
+	if !ok {
+		writeJSONError(w, http.StatusBadRequest, "invalid_format", "Unknown response format")
+		return
+	}
 
 	var req []models.BatchShortenRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		logrus.WithError(err).Error("Invalid JSON format")
-		w.WriteHeader(http.StatusBadRequest)
-		if err := json.NewEncoder(w).Encode(map[string]string{"error": "Invalid JSON format"}); err != nil {
-			logrus.WithError(err).Error("Failed to encode error response")
+		if middleware.IsBodyTooLarge(err) {
+			writeJSONError(w, http.StatusRequestEntityTooLarge, "body_too_large", "Request body too large")
+			return
 		}
+		logrus.WithError(err).Error("Invalid JSON format")
+		writeJSONError(w, http.StatusBadRequest, "invalid_json", "Invalid JSON format")
 		return
 	}
 
 	if len(req) == 0 {
-		w.WriteHeader(http.StatusBadRequest)
-		if err := json.NewEncoder(w).Encode(map[string]string{"error": "Empty batch"}); err != nil {
-			logrus.WithError(err).Error("Failed to encode error response")
-		}
+		writeJSONError(w, http.StatusBadRequest, "empty_batch", "Empty batch")
+		return
+	}
+	if h.batchTooLarge(len(req)) {
+		writeJSONError(w, http.StatusRequestEntityTooLarge, "batch_too_large", fmt.Sprintf("Batch exceeds %d URLs", h.opts.maxBatchSize))
 		return
 	}
 
 	for _, item := range req {
 		if item.OriginalURL == "" {
-			w.WriteHeader(http.StatusBadRequest)
-			if err := json.NewEncoder(w).Encode(map[string]string{"error": "URL cannot be empty"}); err != nil {
-				logrus.WithError(err).Error("Failed to encode error response")
-			}
+			writeJSONError(w, http.StatusBadRequest, "empty_url", "URL cannot be empty")
 			return
 		}
 		if _, err := url.Parse(item.OriginalURL); err != nil {
 			logrus.WithError(err).Error("Invalid URL format")
-			w.WriteHeader(http.StatusBadRequest)
-			if err := json.NewEncoder(w).Encode(map[string]string{"error": "Invalid URL format"}); err != nil {
-				logrus.WithError(err).Error("Failed to encode error response")
-			}
+			writeJSONError(w, http.StatusBadRequest, "invalid_url", "Invalid URL format")
 			return
 		}
 	}
 
-	resp, err := h.batch.ShortenBatch(ctx, req, userID)
+	resp, err := h.batch.ShortenBatchWithBase(ctx, req, userID, h.effectiveBaseURL(r))
+	if errors.Is(err, models.ErrSelfLink) {
+		writeJSONError(w, http.StatusBadRequest, "self_link", err.Error())
+		return
+	}
+	if errors.Is(err, models.ErrDomainNotAllowed) {
+		writeJSONError(w, http.StatusForbidden, "domain_not_allowed", err.Error())
+		return
+	}
+	if errors.Is(err, models.ErrUnreachable) {
+		writeJSONError(w, http.StatusUnprocessableEntity, "unreachable", err.Error())
+		return
+	}
+	if errors.Is(err, models.ErrURLLimitExceeded) {
+		writeJSONError(w, http.StatusForbidden, "url_limit_exceeded", err.Error())
+		return
+	}
+	for i := range resp {
+		resp[i].ShortURL = formatShortURL(resp[i].ShortURL, format)
+	}
+	var batchErr *models.BatchError
+	if errors.As(err, &batchErr) {
+		respond.JSON(w, http.StatusInternalServerError, models.APIError{
+			Code:    "batch_partially_saved",
+			Message: fmt.Sprintf("Saved %d of %d URLs", len(resp), len(req)),
+			Saved:   resp,
+		})
+		return
+	}
 	if err != nil {
 		logrus.WithError(err).Error("Failed to shorten batch")
-		w.WriteHeader(http.StatusInternalServerError)
-		if err := json.NewEncoder(w).Encode(map[string]string{"error": "Failed to shorten batch"}); err != nil {
-			logrus.WithError(err).Error("Failed to encode error response")
+		writeStorageError(w, err, "Failed to shorten batch")
+		return
+	}
+
+	respond.JSON(w, http.StatusCreated, resp)
+}
+
+func (h *ShortenHandler) HandleBatchShortenText(w http.ResponseWriter, r *http.Request) {
+	logrus.Info("Handling text batch shorten request")
+
+	r, err := h.opts.withTenant(r, h.baseURL)
+	if err != nil {
+		http.Error(w, "Invalid tenant", http.StatusBadRequest)
+		return
+	}
+	ctx := r.Context()
+
+	userID, ok := h.shortenUserID(w, r)
+	if !ok {
+		return
+	}
+
+	format, ok := responseFormat(r)
+	if !ok {
+		http.Error(w, "Unknown response format", http.StatusBadRequest)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if middleware.IsBodyTooLarge(err) {
+		http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		logrus.WithError(err).Error("Failed to read request body")
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	var req []models.BatchShortenRequest
+	for i, line := range strings.Split(string(body), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
 		}
+		if _, err := url.ParseRequestURI(line); err != nil {
+			logrus.WithError(err).WithField("line", i+1).Error("Invalid URL format")
+			http.Error(w, fmt.Sprintf("Invalid URL format on line %d", i+1), http.StatusBadRequest)
+			return
+		}
+		req = append(req, models.BatchShortenRequest{
+			CorrelationID: strconv.Itoa(i + 1),
+			OriginalURL:   line,
+		})
+	}
+
+	if len(req) == 0 {
+		http.Error(w, "Empty batch", http.StatusBadRequest)
+		return
+	}
+	if h.batchTooLarge(len(req)) {
+		http.Error(w, fmt.Sprintf("Batch exceeds %d URLs", h.opts.maxBatchSize), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	resp, err := h.batch.ShortenBatchWithBase(ctx, req, userID, h.effectiveBaseURL(r))
+	if errors.Is(err, models.ErrSelfLink) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, models.ErrDomainNotAllowed) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, models.ErrUnreachable) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if errors.Is(err, models.ErrURLLimitExceeded) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	var batchErr *models.BatchError
+	if err != nil && !errors.As(err, &batchErr) {
+		logrus.WithError(err).Error("Failed to shorten batch")
+		http.Error(w, "Failed to shorten batch", storageErrorStatus(err))
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	if err := json.NewEncoder(w).Encode(resp); err != nil {
-		logrus.WithError(err).Error("Failed to encode response")
+	// A partially saved batch lists the saved lines, numbered as in the
+	// request, after a summary line.
+	var out strings.Builder
+	if batchErr != nil {
+		fmt.Fprintf(&out, "Saved %d of %d URLs\n", len(resp), len(req))
 	}
+	for _, item := range resp {
+		if batchErr != nil {
+			out.WriteString(item.CorrelationID + " ")
+		}
+		out.WriteString(formatShortURL(item.ShortURL, format))
+		out.WriteByte('\n')
+	}
+
+	if batchErr != nil {
+		respond.Text(w, http.StatusInternalServerError, out.String())
+		return
+	}
+	respond.Text(w, http.StatusCreated, out.String())
+}
+
+func (h *ShortenHandler) batchTooLarge(n int) bool {
+	return h.opts.maxBatchSize > 0 && n > h.opts.maxBatchSize
 }
 
 func (h *RedirectHandler) HandleRedirect(w http.ResponseWriter, r *http.Request) {
 	logrus.Info("Handling redirect request")
+
+	r, err := h.opts.withTenant(r, h.baseURL)
+	if err != nil {
+		http.Error(w, "Invalid tenant", http.StatusBadRequest)
+		return
+	}
 	ctx := r.Context()
 
-	vars := mux.Vars(r)
-	id := vars["id"]
+	id, inTenant := scopedID(r, h.opts.pathID(r))
+	logging.SetShortID(ctx, id)
+	if !inTenant {
+		logrus.WithField("id", id).Warn("Short ID belongs to another tenant")
+		h.writeGone(w, r, id)
+		return
+	}
 
 	originalURL, found := h.redirector.Get(ctx, id)
 	if !found {
+		if h.redirectDeleted(w, r, id) {
+			return
+		}
 		logrus.WithField("id", id).Warn("URL not found or deleted")
-		http.Error(w, "Gone", http.StatusGone)
+		h.writeGone(w, r, id)
+		return
+	}
+
+	allowed, err := h.passwordAllowed(r, id)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to check link password")
+		http.Error(w, "Internal server error", storageErrorStatus(err))
+		return
+	}
+	if !allowed {
+		logrus.WithField("id", id).Warn("Missing or wrong link password")
+		writePasswordForm(w)
 		return
 	}
 
+	if recorder, ok := h.redirector.(models.HitRecorder); ok {
+		if err := recorder.RecordHit(ctx, id); err != nil {
+			logrus.WithError(err).WithField("id", id).Error("Failed to record hit")
+		}
+	}
+
+	status := h.opts.redirectStatus
+	if r.Method == http.MethodPost {
+		// The password form arrives by POST; 307 and 308 would make the
+		// browser re-post pw to the target, 303 turns it into a plain GET.
+		status = http.StatusSeeOther
+	}
 	w.Header().Set("Location", originalURL)
-	w.WriteHeader(http.StatusTemporaryRedirect)
+	w.WriteHeader(status)
+}
+
+// passwordAllowed reports whether the request may see the target of id: the
+// link has no password or pw, from the query or a form, matches it.
+func (h *RedirectHandler) passwordAllowed(r *http.Request, id string) (bool, error) {
+	checker, ok := h.redirector.(models.PasswordChecker)
+	if !ok {
+		return true, nil
+	}
+	return checker.CheckPassword(r.Context(), id, r.FormValue("pw"))
+}
+
+func (h *RedirectHandler) writeGone(w http.ResponseWriter, r *http.Request, id string) {
+	if recorder, ok := h.redirector.(models.GoneRecorder); ok {
+		recorder.RecordGone(r.Context(), id)
+	}
+	http.Error(w, "Gone", http.StatusGone)
+}
+
+// redirectDeleted sends the client to the configured removal page when id
+// exists but was soft-deleted. It reports whether a response was written.
+func (h *RedirectHandler) redirectDeleted(w http.ResponseWriter, r *http.Request, id string) bool {
+	if h.opts.deletedRedirect == "" {
+		return false
+	}
+	checker, ok := h.redirector.(models.DeletionChecker)
+	if !ok {
+		return false
+	}
+	deleted, err := checker.IsDeleted(r.Context(), id)
+	if err != nil {
+		logrus.WithError(err).WithField("id", id).Error("Failed to check deletion")
+		return false
+	}
+	if !deleted {
+		return false
+	}
+
+	logrus.WithField("id", id).Info("Redirecting deleted URL to removal page")
+	http.Redirect(w, r, h.opts.deletedRedirect, http.StatusFound)
+	return true
+}
+
+func (h *RedirectHandler) HandleResolve(w http.ResponseWriter, r *http.Request) {
+	logrus.Info("Handling resolve request")
+	ctx := r.Context()
+
+	if r.Body == nil {
+		writeJSONError(w, http.StatusBadRequest, "empty_body", "Empty request body")
+		return
+	}
+	defer r.Body.Close()
+
+	var shortIDs []string
+	if err := json.NewDecoder(r.Body).Decode(&shortIDs); err != nil {
+		if middleware.IsBodyTooLarge(err) {
+			writeJSONError(w, http.StatusRequestEntityTooLarge, "body_too_large", "Request body too large")
+			return
+		}
+		logrus.WithError(err).Error("Invalid JSON format")
+		writeJSONError(w, http.StatusBadRequest, "invalid_json", "Invalid JSON format")
+		return
+	}
+
+	if len(shortIDs) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "empty_batch", "Empty list of short IDs")
+		return
+	}
+
+	ids := make([]string, len(shortIDs))
+	for i, shortID := range shortIDs {
+		ids[i] = h.extractShortID(shortID)
+	}
+
+	resolved, err := h.redirector.GetBatch(ctx, ids)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to resolve URLs")
+		writeStorageError(w, err, "Failed to resolve URLs")
+		return
+	}
+
+	urls := make(map[string]string, len(resolved))
+	for i, shortID := range shortIDs {
+		if originalURL, ok := resolved[ids[i]]; ok {
+			urls[shortID] = originalURL
+		}
+	}
+
+	respond.JSON(w, http.StatusOK, urls)
 }
 
+func (h *RedirectHandler) HandleLookup(w http.ResponseWriter, r *http.Request) {
+	logrus.Info("Handling lookup request")
+	ctx := r.Context()
+
+	originalURL := r.URL.Query().Get("url")
+	if originalURL == "" {
+		writeJSONError(w, http.StatusBadRequest, "empty_url", "Query parameter url is required")
+		return
+	}
+
+	lookuper, ok := h.redirector.(models.URLLookuper)
+	if !ok {
+		writeJSONError(w, http.StatusNotImplemented, "not_supported", "Lookup is not supported")
+		return
+	}
+
+	shortURL, found, err := lookuper.LookupURL(ctx, originalURL, requestUserID(w, r))
+	if err != nil {
+		logrus.WithError(err).Error("Failed to look up URL")
+		writeStorageError(w, err, "Failed to look up URL")
+		return
+	}
+	if !found {
+		writeJSONError(w, http.StatusNotFound, "not_found", "URL has not been shortened")
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, models.ShortenResponse{Result: shortURL})
+}
+
+func writePasswordForm(w http.ResponseWriter) {
+	respond.HTML(w, http.StatusUnauthorized, passwordForm)
+}
+
+const passwordForm = `<!DOCTYPE html>
+<html>
+<body>
+<form method="POST">
+<label>This link is password protected: <input type="password" name="pw"></label>
+<button type="submit">Open</button>
+</form>
+</body>
+</html>
+`
+
 func (h *UserURLsHandler) HandleGetUserURLs(w http.ResponseWriter, r *http.Request) {
 	logrus.Info("Handling get user URLs request")
 	ctx := r.Context()
 
-	userID, err := auth.GetUserIDFromCookie(r)
-	if err != nil {
-		logrus.WithError(err).Warn("No valid cookie found, generating new user ID")
-		userID = auth.GenerateUserID()
-		auth.SetUserIDCookie(w, userID)
+	userID := requestUserID(w, r)
+
+	tag := r.URL.Query().Get("tag")
+	if streamer, ok := h.fetcher.(models.UserURLStreamer); ok && tag == "" {
+		h.streamUserURLs(w, r, streamer, userID)
+		return
 	}
 
-	urls, err := h.fetcher.GetURLsByUserID(ctx, userID)
+	var urls []models.UserURL
+	var err error
+	if tag != "" {
+		tagged, ok := h.fetcher.(models.TaggedURLFetcher)
+		if !ok {
+			writeJSONError(w, http.StatusNotImplemented, "not_supported", "Filtering by tag is not supported")
+			return
+		}
+		urls, err = tagged.GetURLsByTag(ctx, userID, tag)
+	} else {
+		urls, err = h.fetcher.GetURLsByUserID(ctx, userID)
+	}
 	if err != nil {
 		logrus.WithError(err).Error("Failed to get user URLs")
-		http.Error(w, "Failed to get user URLs", http.StatusInternalServerError)
+		writeStorageError(w, err, "Failed to get user URLs")
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
 	if len(urls) == 0 {
 		logrus.WithField("user_id", userID).Info("No URLs found for user")
+		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusNoContent)
 		return
 	}
 
-	if err := json.NewEncoder(w).Encode(urls); err != nil {
+	respond.JSON(w, http.StatusOK, urls)
+}
+
+// streamUserURLs writes each URL as storage yields it. Once the first element
+// is out the status is fixed, so later failures can only cut the body short.
+func (h *UserURLsHandler) streamUserURLs(w http.ResponseWriter, r *http.Request, streamer models.UserURLStreamer, userID string) {
+	out := newJSONArrayWriter(w)
+	err := streamer.GetURLsByUserIDStream(r.Context(), userID, func(url models.UserURL) error {
+		return out.Write(url)
+	})
+	if err != nil {
+		logrus.WithError(err).Error("Failed to stream user URLs")
+		if !out.started {
+			writeStorageError(w, err, "Failed to get user URLs")
+		}
+		return
+	}
+
+	wrote, err := out.Close()
+	if err != nil {
 		logrus.WithError(err).Error("Failed to encode user URLs")
-		http.Error(w, "Failed to encode user URLs", http.StatusInternalServerError)
+		return
+	}
+	if !wrote {
+		logrus.WithField("user_id", userID).Info("No URLs found for user")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (h *UserURLsHandler) HandleUpdateURL(w http.ResponseWriter, r *http.Request) {
+	logrus.Info("Handling update URL request")
+	ctx := r.Context()
+
+	userID, ok := authenticatedUserID(r)
+	if !ok {
+		logrus.Warn("No valid cookie found, unauthorized")
+		writeJSONError(w, http.StatusUnauthorized, "unauthorized", "Unauthorized")
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	logging.SetShortID(ctx, id)
+
+	updater, ok := h.fetcher.(models.URLUpdater)
+	if !ok {
+		writeJSONError(w, http.StatusNotImplemented, "not_supported", "Updating URLs is not supported")
+		return
 	}
+
+	if r.Body == nil {
+		writeJSONError(w, http.StatusBadRequest, "empty_body", "Empty request body")
+		return
+	}
+	defer r.Body.Close()
+
+	var req models.UpdateURLRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		if middleware.IsBodyTooLarge(err) {
+			writeJSONError(w, http.StatusRequestEntityTooLarge, "body_too_large", "Request body too large")
+			return
+		}
+		logrus.WithError(err).Error("Invalid JSON format")
+		writeJSONError(w, http.StatusBadRequest, "invalid_json", "Invalid JSON format")
+		return
+	}
+
+	if req.URL == "" {
+		writeJSONError(w, http.StatusBadRequest, "empty_url", "URL cannot be empty")
+		return
+	}
+	if _, err := url.ParseRequestURI(req.URL); err != nil {
+		logrus.WithError(err).Error("Invalid URL format")
+		writeJSONError(w, http.StatusBadRequest, "invalid_url", "Invalid URL format")
+		return
+	}
+
+	err := updater.UpdateURL(ctx, id, req.URL, userID)
+	switch {
+	case errors.Is(err, models.ErrURLNotFound):
+		writeJSONError(w, http.StatusNotFound, "not_found", err.Error())
+		return
+	case errors.Is(err, models.ErrURLNotOwned):
+		writeJSONError(w, http.StatusForbidden, "forbidden", err.Error())
+		return
+	case errors.Is(err, models.ErrSelfLink):
+		writeJSONError(w, http.StatusBadRequest, "self_link", err.Error())
+		return
+	case errors.Is(err, models.ErrDomainNotAllowed):
+		writeJSONError(w, http.StatusForbidden, "domain_not_allowed", err.Error())
+		return
+	case errors.Is(err, models.ErrUnreachable):
+		writeJSONError(w, http.StatusUnprocessableEntity, "unreachable", err.Error())
+		return
+	case errors.Is(err, models.ErrUpdateNotSupported):
+		writeJSONError(w, http.StatusNotImplemented, "not_supported", err.Error())
+		return
+	case err != nil:
+		logrus.WithError(err).Error("Failed to update URL")
+		writeStorageError(w, err, "Failed to update URL")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
 }
 
 func (h *DeleteHandler) HandleDeleteURLs(w http.ResponseWriter, r *http.Request) {
 	logrus.Info("Handling delete URLs request")
     ctx := r.Context()
 
-    userID, err := auth.GetUserIDFromCookie(r)
-    if err != nil {
-        logrus.WithError(err).Warn("No valid cookie found, unauthorized")
-        http.Error(w, "Unauthorized", http.StatusUnauthorized)
+    userID, ok := authenticatedUserID(r)
+    if !ok {
+        logrus.Warn("No valid cookie found, unauthorized")
+        writeJSONError(w, http.StatusUnauthorized, "unauthorized", "Unauthorized")
         return
     }
 
-    var shortIDs []string
-    if err := json.NewDecoder(r.Body).Decode(&shortIDs); err != nil {
+    req, err := decodeDeleteRequest(r.Body)
+    if err != nil {
+        if middleware.IsBodyTooLarge(err) {
+            writeJSONError(w, http.StatusRequestEntityTooLarge, "body_too_large", "Request body too large")
+            return
+        }
         logrus.WithError(err).Error("Invalid JSON format")
-        http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+        writeJSONError(w, http.StatusBadRequest, "invalid_json", "Invalid JSON format")
         return
     }
     defer r.Body.Close()
 
-    if len(shortIDs) == 0 {
-        http.Error(w, "Empty list of URLs", http.StatusBadRequest)
+    if len(req.IDs) == 0 {
+        writeJSONError(w, http.StatusBadRequest, "empty_batch", "Empty list of URLs")
         return
     }
 
-    if err := h.deleter.DeleteURLs(ctx, shortIDs, userID); err != nil {
+    if err := h.deleter.DeleteURLs(ctx, req.IDs, userID); err != nil {
         logrus.WithError(err).Error("Failed to delete URLs")
-        http.Error(w, "Failed to delete URLs", http.StatusInternalServerError)
+        writeStorageError(w, err, "Failed to delete URLs")
         return
     }
 
+    logrus.WithFields(logrus.Fields{
+        "user_id":   userID,
+        "short_ids": req.IDs,
+        "reason":    req.Reason,
+    }).Info("URLs deleted")
+
     w.WriteHeader(http.StatusAccepted)
 }
 
+// decodeDeleteRequest accepts either the legacy array of short IDs or an
+// object with ids and an optional reason, told apart by the first token.
+func decodeDeleteRequest(body io.Reader) (models.DeleteURLsRequest, error) {
+	var req models.DeleteURLsRequest
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return req, err
+	}
+
+	tok, err := json.NewDecoder(bytes.NewReader(data)).Token()
+	if err != nil {
+		return req, err
+	}
+	if tok == json.Delim('[') {
+		err = json.Unmarshal(data, &req.IDs)
+	} else {
+		err = json.Unmarshal(data, &req)
+	}
+	return req, err
+}
+
+func (h *DeleteHandler) HandleRestoreURLs(w http.ResponseWriter, r *http.Request) {
+	logrus.Info("Handling restore URLs request")
+	ctx := r.Context()
+
+	userID, ok := authenticatedUserID(r)
+	if !ok {
+		logrus.Warn("No valid cookie found, unauthorized")
+		writeJSONError(w, http.StatusUnauthorized, "unauthorized", "Unauthorized")
+		return
+	}
+
+	if r.Body == nil {
+		writeJSONError(w, http.StatusBadRequest, "empty_body", "Empty request body")
+		return
+	}
+	defer r.Body.Close()
+
+	var shortIDs []string
+	if err := json.NewDecoder(r.Body).Decode(&shortIDs); err != nil {
+		if middleware.IsBodyTooLarge(err) {
+			writeJSONError(w, http.StatusRequestEntityTooLarge, "body_too_large", "Request body too large")
+			return
+		}
+		logrus.WithError(err).Error("Invalid JSON format")
+		writeJSONError(w, http.StatusBadRequest, "invalid_json", "Invalid JSON format")
+		return
+	}
+
+	if len(shortIDs) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "empty_batch", "Empty list of URLs")
+		return
+	}
+
+	if err := h.deleter.RestoreURLs(ctx, shortIDs, userID); err != nil {
+		logrus.WithError(err).Error("Failed to restore URLs")
+		writeStorageError(w, err, "Failed to restore URLs")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *DeleteHandler) HandleSetDeleted(w http.ResponseWriter, r *http.Request) {
+	logrus.Info("Handling set deleted request")
+	ctx := r.Context()
+
+	userID, ok := authenticatedUserID(r)
+	if !ok {
+		logrus.Warn("No valid cookie found, unauthorized")
+		writeJSONError(w, http.StatusUnauthorized, "unauthorized", "Unauthorized")
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	logging.SetShortID(ctx, id)
+
+	toggler, ok := h.deleter.(models.DeletionToggler)
+	if !ok {
+		writeJSONError(w, http.StatusNotImplemented, "not_supported", "Toggling deletion is not supported")
+		return
+	}
+
+	if r.Body == nil {
+		writeJSONError(w, http.StatusBadRequest, "empty_body", "Empty request body")
+		return
+	}
+	defer r.Body.Close()
+
+	var req models.SetDeletedRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		if middleware.IsBodyTooLarge(err) {
+			writeJSONError(w, http.StatusRequestEntityTooLarge, "body_too_large", "Request body too large")
+			return
+		}
+		logrus.WithError(err).Error("Invalid JSON format")
+		writeJSONError(w, http.StatusBadRequest, "invalid_json", "Invalid JSON format")
+		return
+	}
+	if req.IsDeleted == nil {
+		writeJSONError(w, http.StatusBadRequest, "validation_failed", "is_deleted is required")
+		return
+	}
+
+	err := toggler.SetDeleted(ctx, id, *req.IsDeleted, userID)
+	switch {
+	case errors.Is(err, models.ErrURLNotFound):
+		writeJSONError(w, http.StatusNotFound, "not_found", err.Error())
+		return
+	case errors.Is(err, models.ErrURLNotOwned):
+		writeJSONError(w, http.StatusForbidden, "forbidden", err.Error())
+		return
+	case errors.Is(err, models.ErrUpdateNotSupported):
+		writeJSONError(w, http.StatusNotImplemented, "not_supported", err.Error())
+		return
+	case err != nil:
+		logrus.WithError(err).Error("Failed to set deleted flag")
+		writeStorageError(w, err, "Failed to update URL")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
 func (h *PingHandler) HandlePing(w http.ResponseWriter, r *http.Request) {
 	logrus.Info("Handling ping request")
 	ctx := r.Context()
 
 	err := h.pinger.Ping(ctx)
 	if err != nil {
-		if err.Error() == "file storage does not support database connection check" ||
-			err.Error() == "memory storage does not support database connection check" {
-			w.WriteHeader(http.StatusOK)
-			if _, err := w.Write([]byte("Storage does not require database connection")); err != nil {
-				logrus.WithError(err).Error("Failed to write response")
-			}
+		if errors.Is(err, models.ErrNoDatabase) {
+			respond.Text(w, http.StatusOK, "Storage does not require database connection")
 			return
 		}
 		logrus.WithError(err).Error("Database ping failed")
 		http.Error(w, "Database connection error", http.StatusInternalServerError)
 		return
 	}
-	w.WriteHeader(http.StatusOK)
-	if _, err := w.Write([]byte("Database connection is OK")); err != nil {
-		logrus.WithError(err).Error("Failed to write response")
+	respond.Text(w, http.StatusOK, "Database connection is OK")
+}
+
+func (h *PingHandler) HandleLivez(w http.ResponseWriter, r *http.Request) {
+	h.writeHealth(w, http.StatusOK, "ok")
+}
+
+func (h *PingHandler) HandleHealthz(w http.ResponseWriter, r *http.Request) {
+	if backlog, ok := h.pinger.(models.WriteBacklog); ok {
+		pending, saveErr := backlog.PendingWrites(), backlog.LastSaveError()
+		if saveErr != nil || pending > h.opts.pendingThreshold {
+			logrus.WithError(saveErr).WithField("pending_writes", pending).Warn("Storage writes are falling behind")
+			h.writeHealth(w, http.StatusServiceUnavailable, "degraded")
+			return
+		}
 	}
+	if err := h.pinger.Ping(r.Context()); err != nil && !errors.Is(err, models.ErrNoDatabase) {
+		logrus.WithError(err).Warn("Readiness check failed")
+		h.writeHealth(w, http.StatusServiceUnavailable, "unavailable")
+		return
+	}
+	h.writeHealth(w, http.StatusOK, "ok")
+}
+
+func (h *PingHandler) writeHealth(w http.ResponseWriter, status int, state string) {
+	resp := models.HealthResponse{
+		Status:        state,
+		StorageKind:   h.opts.storageKind,
+		UptimeSeconds: int64(time.Since(h.started).Seconds()),
+	}
+	if backlog, ok := h.pinger.(models.WriteBacklog); ok {
+		resp.PendingWrites = backlog.PendingWrites()
+	}
+
+	respond.JSON(w, status, resp)
+}
+
+func (h *PingHandler) HandleCounters(w http.ResponseWriter, r *http.Request) {
+	logrus.Info("Handling counters request")
+
+	reader, ok := h.pinger.(models.CounterReader)
+	if !ok {
+		writeJSONError(w, http.StatusNotImplemented, "not_supported", "Counters are not supported")
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, reader.Counters())
+}
+
+func (h *PingHandler) HandleServerTime(w http.ResponseWriter, r *http.Request) {
+	logrus.Info("Handling server time request")
+
+	now, source := time.Now(), "system"
+	if ts, ok := h.pinger.(models.TimeSource); ok {
+		now, source = ts.Now(), ts.ClockSource()
+	}
+
+	resp := models.ServerTimeResponse{
+		Time:   now.UTC(),
+		UnixMs: now.UnixMilli(),
+		Clock:  source,
+	}
+
+	respond.JSON(w, http.StatusOK, resp)
 }
 
 func (h *URLHandler) HandleShortenURL(w http.ResponseWriter, r *http.Request) {
@@ -387,10 +1166,26 @@ func (h *URLHandler) HandleBatchShortenURL(w http.ResponseWriter, r *http.Reques
 	h.shorten.HandleBatchShortenURL(w, r)
 }
 
+func (h *URLHandler) HandleBatchShortenText(w http.ResponseWriter, r *http.Request) {
+	h.shorten.HandleBatchShortenText(w, r)
+}
+
 func (h *URLHandler) HandleRedirect(w http.ResponseWriter, r *http.Request) {
 	h.redirect.HandleRedirect(w, r)
 }
 
+func (h *URLHandler) HandleResolve(w http.ResponseWriter, r *http.Request) {
+	h.redirect.HandleResolve(w, r)
+}
+
+func (h *URLHandler) HandleLookup(w http.ResponseWriter, r *http.Request) {
+	h.redirect.HandleLookup(w, r)
+}
+
+func (h *URLHandler) HandleAvailable(w http.ResponseWriter, r *http.Request) {
+	h.redirect.HandleAvailable(w, r)
+}
+
 func (h *URLHandler) HandleGetUserURLs(w http.ResponseWriter, r *http.Request) {
 	h.userURLs.HandleGetUserURLs(w, r)
 }
@@ -399,6 +1194,39 @@ func (h *URLHandler) HandleDeleteURLs(w http.ResponseWriter, r *http.Request) {
 	h.delete.HandleDeleteURLs(w, r)
 }
 
+func (h *URLHandler) HandleUpdateURL(w http.ResponseWriter, r *http.Request) {
+	h.userURLs.HandleUpdateURL(w, r)
+}
+
+func (h *URLHandler) HandleRestoreURLs(w http.ResponseWriter, r *http.Request) {
+	h.delete.HandleRestoreURLs(w, r)
+}
+
+func (h *URLHandler) HandleSetDeleted(w http.ResponseWriter, r *http.Request) {
+	h.delete.HandleSetDeleted(w, r)
+}
+
+func (h *URLHandler) HandleCounters(w http.ResponseWriter, r *http.Request) {
+	h.ping.HandleCounters(w, r)
+}
+
+func (h *URLHandler) HandleServerTime(w http.ResponseWriter, r *http.Request) {
+	h.ping.HandleServerTime(w, r)
+}
+
 func (h *URLHandler) HandlePing(w http.ResponseWriter, r *http.Request) {
 	h.ping.HandlePing(w, r)
-}
\ No newline at end of file
+}
+
+func (h *URLHandler) HandleIssueToken(w http.ResponseWriter, r *http.Request) {
+	h.auth.HandleIssueToken(w, r)
+}
+
+func (h *URLHandler) HandleLivez(w http.ResponseWriter, r *http.Request) {
+	h.ping.HandleLivez(w, r)
+}
+
+func (h *URLHandler) HandleHealthz(w http.ResponseWriter, r *http.Request) {
+	h.ping.HandleHealthz(w, r)
+}
+
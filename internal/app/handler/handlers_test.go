@@ -4,17 +4,27 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/AlenaMolokova/http/internal/app/auth"
+	"github.com/AlenaMolokova/http/internal/app/clock"
 	"github.com/AlenaMolokova/http/internal/app/config"
 	"github.com/AlenaMolokova/http/internal/app/generator"
 	"github.com/AlenaMolokova/http/internal/app/models"
 	"github.com/AlenaMolokova/http/internal/app/service"
 	"github.com/AlenaMolokova/http/internal/app/storage"
+	"github.com/AlenaMolokova/http/internal/app/storage/memory"
 	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
 )
 
 func TestHandleShortenURLValidInput(t *testing.T) {
@@ -380,3 +390,2289 @@ func TestHandleBatchShortenURLEmptyBatch(t *testing.T) {
 		t.Errorf("Expected 400, got %d", w.Code)
 	}
 }
+
+// failingBatchSaver saves the first item of a batch and reports the rest as
+// lost.
+type failingBatchSaver struct {
+	models.URLBatchSaver
+}
+
+func (f failingBatchSaver) SaveBatch(ctx context.Context, batch map[string]string, userID string) error {
+	for shortID, originalURL := range batch {
+		if err := f.URLBatchSaver.SaveBatch(ctx, map[string]string{shortID: originalURL}, userID); err != nil {
+			return err
+		}
+		return &models.BatchError{Saved: []string{shortID}, Err: errors.New("connection lost")}
+	}
+	return nil
+}
+
+func TestHandleBatchShortenURLPartialSave(t *testing.T) {
+	urlStorage, err := storage.NewStorage("", "")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	serviceImpl := service.NewService(
+		urlStorage.AsURLSaver(),
+		failingBatchSaver{urlStorage.AsURLBatchSaver()},
+		urlStorage.AsURLGetter(),
+		urlStorage.AsURLFetcher(),
+		urlStorage.AsURLDeleter(),
+		urlStorage.AsPinger(),
+		generator.NewGenerator(8),
+		"http://localhost:8080",
+	)
+	handler := NewURLHandler(serviceImpl, serviceImpl, serviceImpl, serviceImpl, serviceImpl, serviceImpl, "http://localhost:8080")
+
+	body := `[{"correlation_id":"1","original_url":"https://one.example.com"},{"correlation_id":"2","original_url":"https://two.example.com"}]`
+	req := authenticatedRequest(http.MethodPost, "/api/shorten/batch", body, "user")
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	handler.HandleBatchShortenURL(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected 500, got %d", w.Code)
+	}
+	var apiErr models.APIError
+	if err := json.NewDecoder(w.Body).Decode(&apiErr); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if apiErr.Code != "batch_partially_saved" {
+		t.Errorf("Expected code batch_partially_saved, got %q", apiErr.Code)
+	}
+	if len(apiErr.Saved) != 1 {
+		t.Fatalf("Expected one saved item, got %v", apiErr.Saved)
+	}
+	shortID := strings.TrimPrefix(apiErr.Saved[0].ShortURL, "http://localhost:8080/")
+	want := map[string]string{"1": "https://one.example.com", "2": "https://two.example.com"}[apiErr.Saved[0].CorrelationID]
+	if got, _ := urlStorage.AsURLGetter().Get(context.Background(), shortID); got != want {
+		t.Errorf("Expected saved item %+v to resolve to %s, got %q", apiErr.Saved[0], want, got)
+	}
+}
+
+func newTestHandler(t *testing.T) (*URLHandler, *storage.Storage) {
+	t.Helper()
+	cfg := &config.Config{BaseURL: "http://localhost:8080"}
+	urlStorage, err := storage.NewStorage("", "")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	serviceImpl := service.NewService(
+		urlStorage.AsURLSaver(),
+		urlStorage.AsURLBatchSaver(),
+		urlStorage.AsURLGetter(),
+		urlStorage.AsURLFetcher(),
+		urlStorage.AsURLDeleter(),
+		urlStorage.AsPinger(),
+		generator.NewGenerator(8),
+		cfg.BaseURL,
+	)
+	handler := NewURLHandler(serviceImpl, serviceImpl, serviceImpl, serviceImpl, serviceImpl, serviceImpl, cfg.BaseURL)
+	return handler, urlStorage
+}
+
+func assertAPIError(t *testing.T, w *httptest.ResponseRecorder, status int, code string) {
+	t.Helper()
+	if w.Code != status {
+		t.Errorf("Expected %d, got %d", status, w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.Contains(ct, "application/json") {
+		t.Errorf("Expected application/json error, got %q", ct)
+	}
+	var apiErr models.APIError
+	if err := json.NewDecoder(w.Body).Decode(&apiErr); err != nil {
+		t.Fatalf("Failed to decode error response: %v", err)
+	}
+	if apiErr.Code != code {
+		t.Errorf("Expected error code %q, got %q", code, apiErr.Code)
+	}
+	if apiErr.Message == "" {
+		t.Error("Expected non-empty error message")
+	}
+}
+
+type failingFetcher struct{}
+
+func (failingFetcher) GetURLsByUserID(ctx context.Context, userID string) ([]models.UserURL, error) {
+	return nil, errors.New("storage unavailable")
+}
+
+func TestAPIErrorEnvelope(t *testing.T) {
+	handler, _ := newTestHandler(t)
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		serve  http.HandlerFunc
+		status int
+		code   string
+	}{
+		{"shorten invalid json", http.MethodPost, "/api/shorten", "invalid json", handler.HandleShortenURLJSON, http.StatusBadRequest, "invalid_json"},
+		{"shorten empty url", http.MethodPost, "/api/shorten", `{"url":""}`, handler.HandleShortenURLJSON, http.StatusBadRequest, "empty_url"},
+		{"batch invalid json", http.MethodPost, "/api/shorten/batch", "{", handler.HandleBatchShortenURL, http.StatusBadRequest, "invalid_json"},
+		{"batch empty", http.MethodPost, "/api/shorten/batch", "[]", handler.HandleBatchShortenURL, http.StatusBadRequest, "empty_batch"},
+		{"batch empty url", http.MethodPost, "/api/shorten/batch", `[{"correlation_id":"1","original_url":""}]`, handler.HandleBatchShortenURL, http.StatusBadRequest, "empty_url"},
+		{"delete unauthorized", http.MethodDelete, "/api/user/urls", `["abc"]`, handler.HandleDeleteURLs, http.StatusUnauthorized, "unauthorized"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			tt.serve(w, req)
+
+			assertAPIError(t, w, tt.status, tt.code)
+		})
+	}
+}
+
+func TestAPIErrorEnvelopeDeleteInvalidJSON(t *testing.T) {
+	handler, _ := newTestHandler(t)
+
+	req := authenticatedRequest(http.MethodDelete, "/api/user/urls", "not json", "test-user")
+	w := httptest.NewRecorder()
+
+	handler.HandleDeleteURLs(w, req)
+
+	assertAPIError(t, w, http.StatusBadRequest, "invalid_json")
+}
+
+func TestHandleDeleteURLsRequestShapes(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		reason string
+	}{
+		{"legacy array", `["del1","del2"]`, ""},
+		{"object with reason", `{"ids":["del1","del2"],"reason":"spam report #42"}`, "spam report #42"},
+		{"object without reason", ` {"ids":["del1","del2"]}`, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hook := test.NewGlobal()
+			defer hook.Reset()
+
+			handler, urlStorage := newTestHandler(t)
+			ctx := context.Background()
+			for _, id := range []string{"del1", "del2"} {
+				if err := urlStorage.AsURLSaver().Save(ctx, id, "https://example.com/"+id, "owner"); err != nil {
+					t.Fatalf("Failed to save URL: %v", err)
+				}
+			}
+
+			w := httptest.NewRecorder()
+			handler.HandleDeleteURLs(w, authenticatedRequest(http.MethodDelete, "/api/user/urls", tt.body, "owner"))
+			if w.Code != http.StatusAccepted {
+				t.Fatalf("Expected 202, got %d: %s", w.Code, w.Body.String())
+			}
+
+			for _, id := range []string{"del1", "del2"} {
+				if _, ok := urlStorage.AsURLGetter().Get(ctx, id); ok {
+					t.Errorf("Expected %s to be deleted", id)
+				}
+			}
+
+			var entry *logrus.Entry
+			for _, e := range hook.AllEntries() {
+				if e.Message == "URLs deleted" {
+					entry = e
+				}
+			}
+			if entry == nil {
+				t.Fatal("Expected a deletion log entry")
+			}
+			if got := entry.Data["reason"]; got != tt.reason {
+				t.Errorf("Expected reason %q, got %v", tt.reason, got)
+			}
+			if got := entry.Data["user_id"]; got != "owner" {
+				t.Errorf("Expected user_id owner, got %v", got)
+			}
+		})
+	}
+}
+
+func TestHandleDeleteURLsObjectWithoutIDs(t *testing.T) {
+	handler, _ := newTestHandler(t)
+
+	w := httptest.NewRecorder()
+	handler.HandleDeleteURLs(w, authenticatedRequest(http.MethodDelete, "/api/user/urls", `{"reason":"cleanup"}`, "owner"))
+
+	assertAPIError(t, w, http.StatusBadRequest, "empty_batch")
+}
+
+func TestAPIErrorEnvelopeUserURLsFailure(t *testing.T) {
+	handler := NewUserURLsHandler(failingFetcher{})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/user/urls", nil)
+	w := httptest.NewRecorder()
+
+	handler.HandleGetUserURLs(w, req)
+
+	assertAPIError(t, w, http.StatusInternalServerError, "internal_error")
+}
+
+type timedOutFetcher struct{}
+
+func (timedOutFetcher) GetURLsByUserID(ctx context.Context, userID string) ([]models.UserURL, error) {
+	return nil, fmt.Errorf("failed to get user URLs: %w", context.DeadlineExceeded)
+}
+
+func TestStorageTimeoutAnswers503(t *testing.T) {
+	handler := NewUserURLsHandler(timedOutFetcher{})
+
+	w := httptest.NewRecorder()
+	handler.HandleGetUserURLs(w, httptest.NewRequest(http.MethodGet, "/api/user/urls", nil))
+
+	assertAPIError(t, w, http.StatusServiceUnavailable, "timeout")
+}
+
+func TestHandleRedirectDeletedURL(t *testing.T) {
+	const removedPage = "https://example.org/removed"
+	tests := []struct {
+		name     string
+		target   string
+		path     string
+		status   int
+		location string
+	}{
+		{"deleted without fallback", "", "/gone1234", http.StatusGone, ""},
+		{"deleted with fallback", removedPage, "/gone1234", http.StatusFound, removedPage},
+		{"missing with fallback", removedPage, "/missing1", http.StatusGone, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			urlStorage, err := storage.NewStorage("", "")
+			if err != nil {
+				t.Fatalf("Failed to create storage: %v", err)
+			}
+			ctx := context.Background()
+			if err := urlStorage.AsURLSaver().Save(ctx, "gone1234", "https://example.com", "owner"); err != nil {
+				t.Fatalf("Failed to save URL: %v", err)
+			}
+			if err := urlStorage.AsURLDeleter().DeleteURLs(ctx, []string{"gone1234"}, "owner"); err != nil {
+				t.Fatalf("Failed to delete URL: %v", err)
+			}
+			serviceImpl := service.NewService(
+				urlStorage.AsURLSaver(),
+				urlStorage.AsURLBatchSaver(),
+				urlStorage.AsURLGetter(),
+				urlStorage.AsURLFetcher(),
+				urlStorage.AsURLDeleter(),
+				urlStorage.AsPinger(),
+				generator.NewGenerator(8),
+				"http://localhost:8080",
+			)
+			handler := NewURLHandler(serviceImpl, serviceImpl, serviceImpl, serviceImpl, serviceImpl, serviceImpl, "http://localhost:8080", WithDeletedRedirect(tt.target))
+
+			router := mux.NewRouter()
+			router.HandleFunc("/{id}", handler.HandleRedirect).Methods(http.MethodGet)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if w.Code != tt.status {
+				t.Fatalf("Expected %d, got %d", tt.status, w.Code)
+			}
+			if got := w.Header().Get("Location"); got != tt.location {
+				t.Errorf("Expected Location %q, got %q", tt.location, got)
+			}
+		})
+	}
+}
+
+func TestHandleShortenURLRejectsSelfLink(t *testing.T) {
+	urlStorage, err := storage.NewStorage("", "")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	serviceImpl := service.NewService(
+		urlStorage.AsURLSaver(),
+		urlStorage.AsURLBatchSaver(),
+		urlStorage.AsURLGetter(),
+		urlStorage.AsURLFetcher(),
+		urlStorage.AsURLDeleter(),
+		urlStorage.AsPinger(),
+		generator.NewGenerator(8),
+		"http://localhost:8080",
+	)
+	serviceImpl.RejectSelfLinks = true
+	handler := NewURLHandler(serviceImpl, serviceImpl, serviceImpl, serviceImpl, serviceImpl, serviceImpl, "http://localhost:8080")
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("http://localhost:8080/abc"))
+	req.Header.Set("Content-Type", "text/plain")
+	w := httptest.NewRecorder()
+
+	handler.HandleShortenURL(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(`{"url":"http://localhost:8080/abc"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+
+	handler.HandleShortenURLJSON(w, req)
+
+	assertAPIError(t, w, http.StatusBadRequest, "self_link")
+}
+
+func TestShortenBlockedDomainReturnsForbidden(t *testing.T) {
+	urlStorage, err := storage.NewStorage("", "")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	serviceImpl := service.NewService(
+		urlStorage.AsURLSaver(),
+		urlStorage.AsURLBatchSaver(),
+		urlStorage.AsURLGetter(),
+		urlStorage.AsURLFetcher(),
+		urlStorage.AsURLDeleter(),
+		urlStorage.AsPinger(),
+		generator.NewGenerator(8),
+		"http://localhost:8080",
+	)
+	serviceImpl.DomainBlocklist = []string{"*.phish.example"}
+	handler := NewURLHandler(serviceImpl, serviceImpl, serviceImpl, serviceImpl, serviceImpl, serviceImpl, "http://localhost:8080")
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("https://login.phish.example"))
+	req.Header.Set("Content-Type", "text/plain")
+	w := httptest.NewRecorder()
+	handler.HandleShortenURL(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(`{"url":"https://login.phish.example"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	handler.HandleShortenURLJSON(w, req)
+	assertAPIError(t, w, http.StatusForbidden, "domain_not_allowed")
+
+	req = httptest.NewRequest(http.MethodPost, "/api/shorten/batch", strings.NewReader(`[{"correlation_id":"1","original_url":"https://login.phish.example"}]`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	handler.HandleBatchShortenURL(w, req)
+	assertAPIError(t, w, http.StatusForbidden, "domain_not_allowed")
+}
+
+type unreachableChecker struct{}
+
+func (unreachableChecker) Check(ctx context.Context, rawURL string) error {
+	return &models.UnreachableError{Status: http.StatusNotFound}
+}
+
+func TestShortenUnreachableURLReturnsUnprocessable(t *testing.T) {
+	urlStorage, err := storage.NewStorage("", "")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	serviceImpl := service.NewService(
+		urlStorage.AsURLSaver(),
+		urlStorage.AsURLBatchSaver(),
+		urlStorage.AsURLGetter(),
+		urlStorage.AsURLFetcher(),
+		urlStorage.AsURLDeleter(),
+		urlStorage.AsPinger(),
+		generator.NewGenerator(8),
+		"http://localhost:8080",
+	)
+	serviceImpl.Reachability = unreachableChecker{}
+	handler := NewURLHandler(serviceImpl, serviceImpl, serviceImpl, serviceImpl, serviceImpl, serviceImpl, "http://localhost:8080")
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("https://dead.example.com"))
+	req.Header.Set("Content-Type", "text/plain")
+	w := httptest.NewRecorder()
+	handler.HandleShortenURL(w, req)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "status 404") {
+		t.Errorf("Expected the encountered status in the body, got %q", w.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(`{"url":"https://dead.example.com"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	handler.HandleShortenURLJSON(w, req)
+	assertAPIError(t, w, http.StatusUnprocessableEntity, "unreachable")
+}
+
+func newTestHandlerWithOptions(t *testing.T, opts ...Option) *URLHandler {
+	t.Helper()
+	urlStorage, err := storage.NewStorage("", "")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	serviceImpl := service.NewService(
+		urlStorage.AsURLSaver(),
+		urlStorage.AsURLBatchSaver(),
+		urlStorage.AsURLGetter(),
+		urlStorage.AsURLFetcher(),
+		urlStorage.AsURLDeleter(),
+		urlStorage.AsPinger(),
+		generator.NewGenerator(8),
+		"http://localhost:8080",
+	)
+	return NewURLHandler(serviceImpl, serviceImpl, serviceImpl, serviceImpl, serviceImpl, serviceImpl, "http://localhost:8080", opts...)
+}
+
+func TestHandleShortenURLProxyHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		trust   bool
+		headers map[string]string
+		prefix  string
+	}{
+		{"ignored when disabled", false, map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "sho.rt"}, "http://localhost:8080/"},
+		{"x-forwarded headers", true, map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "sho.rt"}, "https://sho.rt/"},
+		{"forwarded header", true, map[string]string{"Forwarded": `for=1.2.3.4;proto=https;host="sho.rt"`}, "https://sho.rt/"},
+		{"request host fallback", true, map[string]string{"X-Forwarded-Proto": "https"}, "https://example.org/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := newTestHandlerWithOptions(t, WithTrustProxyHeaders(tt.trust))
+
+			req := httptest.NewRequest(http.MethodPost, "http://example.org/", strings.NewReader("https://example.com/page"))
+			req.Header.Set("Content-Type", "text/plain")
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+
+			handler.HandleShortenURL(w, req)
+
+			if w.Code != http.StatusCreated {
+				t.Fatalf("Expected 201, got %d", w.Code)
+			}
+			if body := w.Body.String(); !strings.HasPrefix(body, tt.prefix) {
+				t.Errorf("Expected short URL with prefix %s, got %s", tt.prefix, body)
+			}
+		})
+	}
+}
+
+func TestShortenUsesBaseURLMatchingHost(t *testing.T) {
+	handler := newTestHandlerWithOptions(t, WithBaseURLs("https://ex.am", "http://sh.rt:9000/"))
+
+	tests := []struct {
+		host   string
+		prefix string
+	}{
+		{"ex.am", "https://ex.am/"},
+		{"EX.AM:443", "https://ex.am/"},
+		{"sh.rt:9000", "http://sh.rt:9000/"},
+		{"sh.rt", "http://localhost:8080/"},
+		{"unknown.example", "http://localhost:8080/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("https://example.com/multi"))
+			req.Host = tt.host
+			req.Header.Set("Content-Type", "text/plain")
+			w := httptest.NewRecorder()
+			handler.HandleShortenURL(w, req)
+
+			if w.Code != http.StatusCreated && w.Code != http.StatusConflict {
+				t.Fatalf("Expected 201 or 409, got %d", w.Code)
+			}
+			if body := w.Body.String(); !strings.HasPrefix(body, tt.prefix) {
+				t.Errorf("Expected short URL with prefix %s, got %s", tt.prefix, body)
+			}
+
+			req = httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(`{"url":"https://example.com/multi"}`))
+			req.Host = tt.host
+			req.Header.Set("Content-Type", "application/json")
+			w = httptest.NewRecorder()
+			handler.HandleShortenURLJSON(w, req)
+
+			var resp models.ShortenResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if !strings.HasPrefix(resp.Result, tt.prefix) {
+				t.Errorf("Expected %s prefix, got %s", tt.prefix, resp.Result)
+			}
+		})
+	}
+}
+
+func TestBatchShortenUsesBaseURLMatchingHost(t *testing.T) {
+	handler := newTestHandlerWithOptions(t, WithBaseURLs("https://ex.am"))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/shorten/batch", strings.NewReader(`[{"correlation_id":"1","original_url":"https://example.com/batch"}]`))
+	req.Host = "ex.am"
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	handler.HandleBatchShortenURL(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d", w.Code)
+	}
+	var resp []models.BatchShortenResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(resp) != 1 || !strings.HasPrefix(resp[0].ShortURL, "https://ex.am/") {
+		t.Errorf("Expected https://ex.am/ prefix, got %v", resp)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/shorten/batch/text", strings.NewReader("https://example.com/text\n"))
+	req.Host = "ex.am"
+	w = httptest.NewRecorder()
+	handler.HandleBatchShortenText(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d", w.Code)
+	}
+	if body := w.Body.String(); !strings.HasPrefix(body, "https://ex.am/") {
+		t.Errorf("Expected https://ex.am/ prefix, got %s", body)
+	}
+}
+
+func TestHandleShortenURLJSONProxyHeaders(t *testing.T) {
+	handler := newTestHandlerWithOptions(t, WithTrustProxyHeaders(true))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(`{"url":"https://example.com"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Forwarded-Proto", "https")
+	req.Header.Set("X-Forwarded-Host", "sho.rt")
+	w := httptest.NewRecorder()
+
+	handler.HandleShortenURLJSON(w, req)
+
+	var resp models.ShortenResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !strings.HasPrefix(resp.Result, "https://sho.rt/") {
+		t.Errorf("Expected https://sho.rt/ prefix, got %s", resp.Result)
+	}
+}
+
+func TestHandleResolve(t *testing.T) {
+	handler, urlStorage := newTestHandler(t)
+	ctx := context.Background()
+
+	if err := urlStorage.AsURLSaver().Save(ctx, "live1", "https://live.example", "owner"); err != nil {
+		t.Fatalf("Failed to save URL: %v", err)
+	}
+	if err := urlStorage.AsURLSaver().Save(ctx, "dead1", "https://dead.example", "owner"); err != nil {
+		t.Fatalf("Failed to save URL: %v", err)
+	}
+	if err := urlStorage.AsURLDeleter().DeleteURLs(ctx, []string{"dead1"}, "owner"); err != nil {
+		t.Fatalf("Failed to delete URL: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/resolve", strings.NewReader(`["live1","dead1","unknown"]`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.HandleResolve(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	var resp map[string]string
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(resp) != 1 || resp["live1"] != "https://live.example" {
+		t.Errorf("Expected only live1 to resolve, got %v", resp)
+	}
+}
+
+func TestHandleResolveEmptyList(t *testing.T) {
+	handler, _ := newTestHandler(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/resolve", strings.NewReader(`[]`))
+	w := httptest.NewRecorder()
+
+	handler.HandleResolve(w, req)
+
+	assertAPIError(t, w, http.StatusBadRequest, "empty_batch")
+}
+
+func authenticatedRequest(method, target, body, userID string) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	auth.SetUserIDCookie(rec, userID)
+	for _, c := range rec.Result().Cookies() {
+		req.AddCookie(c)
+	}
+	return req
+}
+
+func TestShortenRequireAuth(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		body   string
+		serve  func(h *URLHandler) http.HandlerFunc
+	}{
+		{"text", "/", "https://closed.example.com/text", func(h *URLHandler) http.HandlerFunc { return h.HandleShortenURL }},
+		{"json", "/api/shorten", `{"url":"https://closed.example.com/json"}`, func(h *URLHandler) http.HandlerFunc { return h.HandleShortenURLJSON }},
+		{"batch", "/api/shorten/batch", `[{"correlation_id":"1","original_url":"https://closed.example.com/batch"}]`, func(h *URLHandler) http.HandlerFunc { return h.HandleBatchShortenURL }},
+		{"batch text", "/api/shorten/batch/text", "https://closed.example.com/batch-text", func(h *URLHandler) http.HandlerFunc { return h.HandleBatchShortenText }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			open := newTestHandlerWithOptions(t)
+			w := httptest.NewRecorder()
+			tt.serve(open)(w, httptest.NewRequest(http.MethodPost, tt.target, strings.NewReader(tt.body)))
+			if w.Code != http.StatusCreated {
+				t.Errorf("Open mode: expected 201 for an anonymous request, got %d: %s", w.Code, w.Body.String())
+			}
+
+			closed := newTestHandlerWithOptions(t, WithRequireAuth(true))
+			w = httptest.NewRecorder()
+			tt.serve(closed)(w, httptest.NewRequest(http.MethodPost, tt.target, strings.NewReader(tt.body)))
+			assertAPIError(t, w, http.StatusUnauthorized, "unauthorized")
+			if cookies := w.Result().Cookies(); len(cookies) != 0 {
+				t.Errorf("Closed mode: expected no cookies for a rejected request, got %d", len(cookies))
+			}
+
+			w = httptest.NewRecorder()
+			tt.serve(closed)(w, authenticatedRequest(http.MethodPost, tt.target, tt.body, "member"))
+			if w.Code != http.StatusCreated {
+				t.Errorf("Closed mode: expected 201 for an authenticated request, got %d: %s", w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandleRestoreURLs(t *testing.T) {
+	handler, urlStorage := newTestHandler(t)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/{id}", handler.HandleRedirect).Methods(http.MethodGet)
+
+	if err := urlStorage.AsURLSaver().Save(context.Background(), "restore1", "https://example.com", "owner"); err != nil {
+		t.Fatalf("Failed to save URL: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	handler.HandleDeleteURLs(w, authenticatedRequest(http.MethodDelete, "/api/user/urls", `["restore1"]`, "owner"))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected 202 on delete, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/restore1", nil))
+	if w.Code != http.StatusGone {
+		t.Fatalf("Expected 410 after delete, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	handler.HandleRestoreURLs(w, authenticatedRequest(http.MethodPost, "/api/user/urls/restore", `["restore1"]`, "owner"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 on restore, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/restore1", nil))
+	if w.Code != http.StatusTemporaryRedirect {
+		t.Errorf("Expected 307 after restore, got %d", w.Code)
+	}
+}
+
+func TestHandleRestoreURLsOtherUser(t *testing.T) {
+	handler, urlStorage := newTestHandler(t)
+	ctx := context.Background()
+
+	if err := urlStorage.AsURLSaver().Save(ctx, "restore2", "https://example.com", "owner"); err != nil {
+		t.Fatalf("Failed to save URL: %v", err)
+	}
+	if err := urlStorage.AsURLDeleter().DeleteURLs(ctx, []string{"restore2"}, "owner"); err != nil {
+		t.Fatalf("Failed to delete URL: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	handler.HandleRestoreURLs(w, authenticatedRequest(http.MethodPost, "/api/user/urls/restore", `["restore2"]`, "intruder"))
+
+	if _, found := urlStorage.AsURLGetter().Get(ctx, "restore2"); found {
+		t.Error("Expected URL owned by another user to stay deleted")
+	}
+}
+
+func TestHandleRedirectPasswordProtected(t *testing.T) {
+	handler, _ := newTestHandler(t)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/{id}", handler.HandleRedirect).Methods(http.MethodGet, http.MethodPost)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(`{"url":"https://secret.example","password":"hunter2"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	handler.HandleShortenURLJSON(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d", w.Code)
+	}
+	var resp models.ShortenResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	id := resp.Result[strings.LastIndex(resp.Result, "/")+1:]
+
+	tests := []struct {
+		name   string
+		req    *http.Request
+		status int
+	}{
+		{"no password", httptest.NewRequest(http.MethodGet, "/"+id, nil), http.StatusUnauthorized},
+		{"wrong password", httptest.NewRequest(http.MethodGet, "/"+id+"?pw=wrong", nil), http.StatusUnauthorized},
+		{"correct password", httptest.NewRequest(http.MethodGet, "/"+id+"?pw=hunter2", nil), http.StatusTemporaryRedirect},
+	}
+	formReq := httptest.NewRequest(http.MethodPost, "/"+id, strings.NewReader("pw=hunter2"))
+	formReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	tests = append(tests, struct {
+		name   string
+		req    *http.Request
+		status int
+	}{"form password", formReq, http.StatusSeeOther})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, tt.req)
+			if w.Code != tt.status {
+				t.Errorf("Expected %d, got %d", tt.status, w.Code)
+			}
+			if tt.status != http.StatusUnauthorized && w.Header().Get("Location") != "https://secret.example" {
+				t.Errorf("Unexpected Location %q", w.Header().Get("Location"))
+			}
+			if tt.status == http.StatusSeeOther && w.Body.Len() != 0 {
+				t.Errorf("Expected no body to be forwarded, got %q", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestProtectedLinkHiddenFromResolve(t *testing.T) {
+	handler, urlStorage := newTestHandler(t)
+	if err := urlStorage.AsURLSaver().Save(context.Background(), "open1", "https://open.example", "owner"); err != nil {
+		t.Fatalf("Failed to save URL: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(`{"url":"https://secret.example/x","password":"hunter2"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	handler.HandleShortenURLJSON(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d", w.Code)
+	}
+	var resp models.ShortenResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	id := resp.Result[strings.LastIndex(resp.Result, "/")+1:]
+
+	req = httptest.NewRequest(http.MethodPost, "/api/resolve", strings.NewReader(`["`+id+`","open1"]`))
+	w = httptest.NewRecorder()
+	handler.HandleResolve(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	var resolved map[string]string
+	if err := json.NewDecoder(w.Body).Decode(&resolved); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if _, ok := resolved[id]; ok {
+		t.Errorf("Expected the protected link to be left out, got %v", resolved)
+	}
+	if resolved["open1"] != "https://open.example" {
+		t.Errorf("Expected the open link to resolve, got %v", resolved)
+	}
+}
+
+func TestHandleRedirectUnprotectedIgnoresPassword(t *testing.T) {
+	handler, urlStorage := newTestHandler(t)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/{id}", handler.HandleRedirect).Methods(http.MethodGet)
+
+	if err := urlStorage.AsURLSaver().Save(context.Background(), "open1", "https://open.example", "owner"); err != nil {
+		t.Fatalf("Failed to save URL: %v", err)
+	}
+
+	for _, target := range []string{"/open1", "/open1?pw=anything"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		if w.Code != http.StatusTemporaryRedirect {
+			t.Errorf("%s: expected 307, got %d", target, w.Code)
+		}
+	}
+}
+
+func TestHandleRedirectCountsHits(t *testing.T) {
+	handler, urlStorage := newTestHandler(t)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/{id}", handler.HandleRedirect).Methods(http.MethodGet)
+
+	if err := urlStorage.AsURLSaver().Save(context.Background(), "hits1", "https://example.com", "owner"); err != nil {
+		t.Fatalf("Failed to save URL: %v", err)
+	}
+
+	const redirects = 7
+	for i := 0; i < redirects; i++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/hits1", nil))
+		if w.Code != http.StatusTemporaryRedirect {
+			t.Fatalf("Expected 307, got %d", w.Code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	handler.HandleGetUserURLs(w, authenticatedRequest(http.MethodGet, "/api/user/urls", "", "owner"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	var urls []models.UserURL
+	if err := json.NewDecoder(w.Body).Decode(&urls); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(urls) != 1 || urls[0].Hits != redirects {
+		t.Errorf("Expected %d hits, got %+v", redirects, urls)
+	}
+}
+
+func TestHandleServerTime(t *testing.T) {
+	handler, _ := newTestHandler(t)
+
+	w := httptest.NewRecorder()
+	handler.HandleServerTime(w, httptest.NewRequest(http.MethodGet, "/api/internal/time", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	var resp models.ServerTimeResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if skew := time.Since(resp.Time); skew < 0 || skew > 5*time.Second {
+		t.Errorf("Expected server time close to now, got skew %v", skew)
+	}
+	if resp.Clock != "system" {
+		t.Errorf("Expected system clock, got %q", resp.Clock)
+	}
+}
+
+func TestHandleServerTimeInjectedClock(t *testing.T) {
+	urlStorage, err := storage.NewStorage("", "")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	serviceImpl := service.NewService(
+		urlStorage.AsURLSaver(),
+		urlStorage.AsURLBatchSaver(),
+		urlStorage.AsURLGetter(),
+		urlStorage.AsURLFetcher(),
+		urlStorage.AsURLDeleter(),
+		urlStorage.AsPinger(),
+		generator.NewGenerator(8),
+		"http://localhost:8080",
+	)
+	fixed := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	serviceImpl.Clock = clock.Func(func() time.Time { return fixed })
+	handler := NewURLHandler(serviceImpl, serviceImpl, serviceImpl, serviceImpl, serviceImpl, serviceImpl, "http://localhost:8080")
+
+	w := httptest.NewRecorder()
+	handler.HandleServerTime(w, httptest.NewRequest(http.MethodGet, "/api/internal/time", nil))
+
+	var resp models.ServerTimeResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !resp.Time.Equal(fixed) || resp.UnixMs != fixed.UnixMilli() {
+		t.Errorf("Expected injected time %v, got %v", fixed, resp.Time)
+	}
+	if resp.Clock != "injected" {
+		t.Errorf("Expected injected clock, got %q", resp.Clock)
+	}
+}
+
+func TestHandleShortenURLJSONPerUserLimit(t *testing.T) {
+	urlStorage, err := storage.NewStorage("", "")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	serviceImpl := service.NewService(
+		urlStorage.AsURLSaver(),
+		urlStorage.AsURLBatchSaver(),
+		urlStorage.AsURLGetter(),
+		urlStorage.AsURLFetcher(),
+		urlStorage.AsURLDeleter(),
+		urlStorage.AsPinger(),
+		generator.NewGenerator(8),
+		"http://localhost:8080",
+	)
+	serviceImpl.MaxURLsPerUser = 1
+	handler := NewURLHandler(serviceImpl, serviceImpl, serviceImpl, serviceImpl, serviceImpl, serviceImpl, "http://localhost:8080")
+
+	req := authenticatedRequest(http.MethodPost, "/api/shorten", `{"url":"https://example.com/1"}`, "limited")
+	w := httptest.NewRecorder()
+	handler.HandleShortenURLJSON(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d", w.Code)
+	}
+
+	req = authenticatedRequest(http.MethodPost, "/api/shorten", `{"url":"https://example.com/2"}`, "limited")
+	w = httptest.NewRecorder()
+	handler.HandleShortenURLJSON(w, req)
+	assertAPIError(t, w, http.StatusForbidden, "url_limit_exceeded")
+
+	req = authenticatedRequest(http.MethodPost, "/api/shorten/batch", `[{"correlation_id":"1","original_url":"https://example.com/3"}]`, "limited")
+	w = httptest.NewRecorder()
+	handler.HandleBatchShortenURL(w, req)
+	assertAPIError(t, w, http.StatusForbidden, "url_limit_exceeded")
+}
+
+func TestHandleResolveStripsHistoricalBaseURLs(t *testing.T) {
+	store := memory.NewMemoryStorage()
+	if err := store.Save(context.Background(), "abc123", "https://example.com", "owner"); err != nil {
+		t.Fatalf("Failed to save URL: %v", err)
+	}
+	handler := NewRedirectHandler(store, store, "https://sho.rt",
+		WithBaseURLHistory("http://localhost:8080", "https://old.example.org/s/"))
+
+	body := `["https://sho.rt/abc123","http://localhost:8080/abc123","https://old.example.org/s/abc123","https://unknown.example/abc123"]`
+	req := httptest.NewRequest(http.MethodPost, "/api/resolve", strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	handler.HandleResolve(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	var resp map[string]string
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	for _, key := range []string{"https://sho.rt/abc123", "http://localhost:8080/abc123", "https://old.example.org/s/abc123"} {
+		if resp[key] != "https://example.com" {
+			t.Errorf("Expected %s to resolve, got %q", key, resp[key])
+		}
+	}
+	if _, ok := resp["https://unknown.example/abc123"]; ok {
+		t.Error("Expected URL with an unknown base not to resolve")
+	}
+}
+
+func TestHandleIssueTokenAuthorizesRequests(t *testing.T) {
+	handler, urlStorage := newTestHandler(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/token", nil)
+	w := httptest.NewRecorder()
+	handler.HandleIssueToken(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	var resp models.TokenResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.Token == "" || resp.UserID == "" {
+		t.Fatalf("Expected token and user ID, got %+v", resp)
+	}
+
+	if err := urlStorage.AsURLSaver().Save(context.Background(), "tok1", "https://example.com", resp.UserID); err != nil {
+		t.Fatalf("Failed to save URL: %v", err)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/user/urls", nil)
+	req.Header.Set("Authorization", "Bearer "+resp.Token)
+	w = httptest.NewRecorder()
+	handler.HandleGetUserURLs(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 with bearer token, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "https://example.com") {
+		t.Errorf("Expected the token user's URLs, got %s", w.Body.String())
+	}
+}
+
+func TestHandleIssueTokenForExistingUser(t *testing.T) {
+	handler, _ := newTestHandler(t)
+
+	req := authenticatedRequest(http.MethodPost, "/api/auth/token", "", "existing-user")
+	w := httptest.NewRecorder()
+	handler.HandleIssueToken(w, req)
+
+	var resp models.TokenResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.UserID != "existing-user" {
+		t.Errorf("Expected token for existing-user, got %s", resp.UserID)
+	}
+}
+
+func TestHandleShortenURLJSONIdempotencyReplay(t *testing.T) {
+	handler, _ := newTestHandler(t)
+
+	send := func(body string) *httptest.ResponseRecorder {
+		req := authenticatedRequest(http.MethodPost, "/api/shorten", body, "idem-user")
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(IdempotencyKeyHeader, "retry-1")
+		w := httptest.NewRecorder()
+		handler.HandleShortenURLJSON(w, req)
+		return w
+	}
+
+	first := send(`{"url":"https://idempotent.example.com"}`)
+	if first.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d", first.Code)
+	}
+
+	second := send(`{"url":"https://idempotent.example.com"}`)
+	if second.Code != first.Code {
+		t.Errorf("Expected replayed status %d, got %d", first.Code, second.Code)
+	}
+	if second.Body.String() != first.Body.String() {
+		t.Errorf("Expected identical body %q, got %q", first.Body.String(), second.Body.String())
+	}
+	if second.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("Expected replayed response to be marked")
+	}
+
+	mismatch := send(`{"url":"https://different.example.com"}`)
+	assertAPIError(t, mismatch, http.StatusConflict, "idempotency_key_mismatch")
+}
+
+func TestHandleBatchShortenURLIdempotencyReplay(t *testing.T) {
+	handler, urlStorage := newTestHandler(t)
+	body := `[{"correlation_id":"1","original_url":"https://a.example.com"},{"correlation_id":"2","original_url":"https://b.example.com"}]`
+
+	send := func() *httptest.ResponseRecorder {
+		req := authenticatedRequest(http.MethodPost, "/api/shorten/batch", body, "idem-batch")
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(IdempotencyKeyHeader, "batch-1")
+		w := httptest.NewRecorder()
+		handler.HandleBatchShortenURL(w, req)
+		return w
+	}
+
+	first := send()
+	second := send()
+	if first.Code != http.StatusCreated || second.Code != http.StatusCreated {
+		t.Fatalf("Expected 201 twice, got %d and %d", first.Code, second.Code)
+	}
+	if first.Body.String() != second.Body.String() {
+		t.Errorf("Expected identical bodies, got %q and %q", first.Body.String(), second.Body.String())
+	}
+
+	urls, err := urlStorage.AsURLFetcher().GetURLsByUserID(context.Background(), "idem-batch")
+	if err != nil {
+		t.Fatalf("Failed to list URLs: %v", err)
+	}
+	if len(urls) != 2 {
+		t.Errorf("Expected the batch to be stored once, found %d URLs", len(urls))
+	}
+}
+
+func TestHandleLookup(t *testing.T) {
+	handler, urlStorage := newTestHandler(t)
+	if err := urlStorage.AsURLSaver().Save(context.Background(), "look1", "https://lookup.example.com/a?b=c", "owner"); err != nil {
+		t.Fatalf("Failed to save URL: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/lookup?url="+url.QueryEscape("https://lookup.example.com/a?b=c"), nil)
+	w := httptest.NewRecorder()
+	handler.HandleLookup(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	var resp models.ShortenResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.Result != "http://localhost:8080/look1" {
+		t.Errorf("Expected http://localhost:8080/look1, got %s", resp.Result)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/lookup?url="+url.QueryEscape("https://missing.example.com"), nil)
+	w = httptest.NewRecorder()
+	handler.HandleLookup(w, req)
+	assertAPIError(t, w, http.StatusNotFound, "not_found")
+
+	urls, _ := urlStorage.AsURLFetcher().GetURLsByUserID(context.Background(), "owner")
+	if len(urls) != 1 {
+		t.Errorf("Expected lookup not to create URLs, owner has %d", len(urls))
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/lookup", nil)
+	w = httptest.NewRecorder()
+	handler.HandleLookup(w, req)
+	assertAPIError(t, w, http.StatusBadRequest, "empty_url")
+}
+
+type failingPinger struct{}
+
+func (failingPinger) Ping(ctx context.Context) error {
+	return errors.New("connection refused")
+}
+
+func TestHealthEndpointsAcrossStorageKinds(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name     string
+		dsn      string
+		filePath string
+		kind     string
+	}{
+		{"memory", "", "", storage.BackendMemory},
+		{"file", "", filepath.Join(dir, "urls.json"), storage.BackendFile},
+		{"sqlite", "sqlite:" + filepath.Join(dir, "urls.db"), "", storage.BackendSQLite},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			urlStorage, err := storage.NewStorage(tt.dsn, tt.filePath)
+			if err != nil {
+				t.Fatalf("Failed to create storage: %v", err)
+			}
+			if urlStorage.Kind() != tt.kind {
+				t.Fatalf("Expected %s storage, got %s", tt.kind, urlStorage.Kind())
+			}
+			h := NewPingHandler(urlStorage.AsPinger(), WithStorageKind(urlStorage.Kind()))
+
+			for _, serve := range []http.HandlerFunc{h.HandleHealthz, h.HandleLivez} {
+				w := httptest.NewRecorder()
+				serve(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+				if w.Code != http.StatusOK {
+					t.Fatalf("Expected 200, got %d", w.Code)
+				}
+				var resp models.HealthResponse
+				if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+					t.Fatalf("Failed to decode response: %v", err)
+				}
+				if resp.Status != "ok" || resp.StorageKind != tt.kind || resp.UptimeSeconds < 0 {
+					t.Errorf("Unexpected health response: %+v", resp)
+				}
+			}
+		})
+	}
+}
+
+func TestHealthzUnavailableWhenDatabaseIsDown(t *testing.T) {
+	h := NewPingHandler(failingPinger{}, WithStorageKind(storage.BackendPostgres))
+
+	w := httptest.NewRecorder()
+	h.HandleHealthz(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 from /healthz, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	h.HandleLivez(w, httptest.NewRequest(http.MethodGet, "/livez", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected 200 from /livez, got %d", w.Code)
+	}
+}
+
+type backloggedPinger struct {
+	pending int
+	saveErr error
+}
+
+func (backloggedPinger) Ping(ctx context.Context) error {
+	return models.ErrNoDatabase
+}
+
+func (p backloggedPinger) PendingWrites() int {
+	return p.pending
+}
+
+func (p backloggedPinger) LastSaveError() error {
+	return p.saveErr
+}
+
+func TestHealthzReportsWriteBacklog(t *testing.T) {
+	tests := []struct {
+		name   string
+		pinger backloggedPinger
+		code   int
+		status string
+	}{
+		{"clean", backloggedPinger{}, http.StatusOK, "ok"},
+		{"within threshold", backloggedPinger{pending: 2}, http.StatusOK, "ok"},
+		{"over threshold", backloggedPinger{pending: 3}, http.StatusServiceUnavailable, "degraded"},
+		{"dirty and unwritable", backloggedPinger{pending: 1, saveErr: errors.New("read-only file system")}, http.StatusServiceUnavailable, "degraded"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewPingHandler(tt.pinger, WithStorageKind(storage.BackendFile), WithPendingWritesThreshold(2))
+
+			w := httptest.NewRecorder()
+			h.HandleHealthz(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+			if w.Code != tt.code {
+				t.Fatalf("Expected %d, got %d", tt.code, w.Code)
+			}
+			var resp models.HealthResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if resp.Status != tt.status {
+				t.Errorf("Expected status %q, got %q", tt.status, resp.Status)
+			}
+			if resp.PendingWrites != tt.pinger.pending {
+				t.Errorf("Expected %d pending writes, got %d", tt.pinger.pending, resp.PendingWrites)
+			}
+		})
+	}
+}
+
+func TestHandleShortenURLAcceptNegotiation(t *testing.T) {
+	tests := []struct {
+		accept      string
+		contentType string
+	}{
+		{"", "text/plain"},
+		{"text/plain", "text/plain"},
+		{"*/*", "text/plain"},
+		{"application/json", "application/json"},
+		{"application/json, */*;q=0.5", "application/json"},
+		{"text/plain, application/json;q=0.9", "text/plain"},
+		{"application/json, text/plain", "text/plain"},
+		{"text/html,application/xhtml+xml,*/*;q=0.8", "text/plain"},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			handler, _ := newTestHandler(t)
+			target := fmt.Sprintf("https://accept.example.com/%d", i)
+
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(target))
+			req.Header.Set("Content-Type", "text/plain")
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+			handler.HandleShortenURL(w, req)
+
+			if w.Code != http.StatusCreated {
+				t.Fatalf("Expected 201, got %d", w.Code)
+			}
+			if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, tt.contentType) {
+				t.Fatalf("Expected %s, got %s", tt.contentType, ct)
+			}
+
+			if tt.contentType == "application/json" {
+				var resp models.ShortenResponse
+				if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+					t.Fatalf("Failed to decode JSON envelope: %v", err)
+				}
+				if !strings.HasPrefix(resp.Result, "http://localhost:8080/") {
+					t.Errorf("Unexpected result %q", resp.Result)
+				}
+			} else if !strings.HasPrefix(w.Body.String(), "http://localhost:8080/") {
+				t.Errorf("Unexpected body %q", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandleBatchShortenURLResponseFormats(t *testing.T) {
+	handler, _ := newTestHandler(t)
+	body := `[{"correlation_id":"1","original_url":"https://fmt-a.example.com"},{"correlation_id":"2","original_url":"https://fmt-b.example.com"}]`
+
+	send := func(target string) []models.BatchShortenResponse {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		handler.HandleBatchShortenURL(w, req)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("%s: expected 201, got %d", target, w.Code)
+		}
+		var resp []models.BatchShortenResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("%s: failed to decode response: %v", target, err)
+		}
+		if len(resp) != 2 {
+			t.Fatalf("%s: expected 2 items, got %d", target, len(resp))
+		}
+		return resp
+	}
+
+	full := send("/api/shorten/batch")
+	for _, item := range full {
+		if !strings.HasPrefix(item.ShortURL, "http://localhost:8080/") {
+			t.Errorf("Expected full short URL, got %q", item.ShortURL)
+		}
+	}
+
+	ids := send("/api/shorten/batch?format=id")
+	for _, item := range ids {
+		if item.ShortURL == "" || strings.Contains(item.ShortURL, "/") {
+			t.Errorf("Expected bare short ID, got %q", item.ShortURL)
+		}
+	}
+}
+
+func TestHandleShortenURLResponseFormatID(t *testing.T) {
+	handler, _ := newTestHandler(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/shorten?format=id", strings.NewReader(`{"url":"https://fmt-single.example.com"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	handler.HandleShortenURLJSON(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d", w.Code)
+	}
+	var resp models.ShortenResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.Result == "" || strings.Contains(resp.Result, "/") {
+		t.Errorf("Expected bare short ID, got %q", resp.Result)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/?format=id", strings.NewReader("https://fmt-single.example.com"))
+	req.Header.Set("Content-Type", "text/plain")
+	w = httptest.NewRecorder()
+	handler.HandleShortenURL(w, req)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("Expected 409, got %d", w.Code)
+	}
+	if w.Body.String() != resp.Result {
+		t.Errorf("Expected text response %q, got %q", resp.Result, w.Body.String())
+	}
+}
+
+func TestShortenSameURLTwiceReturnsConflict(t *testing.T) {
+	shortenText := func(h *URLHandler, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.Header.Set("Content-Type", "text/plain")
+		w := httptest.NewRecorder()
+		h.HandleShortenURL(w, req)
+		return w
+	}
+	shortenJSON := func(h *URLHandler, url string) (*httptest.ResponseRecorder, string) {
+		body, _ := json.Marshal(models.ShortenRequest{URL: url})
+		req := httptest.NewRequest(http.MethodPost, "/api/shorten", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		h.HandleShortenURLJSON(w, req)
+		var resp models.ShortenResponse
+		if err := json.NewDecoder(bytes.NewReader(w.Body.Bytes())).Decode(&resp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return w, resp.Result
+	}
+
+	t.Run("text", func(t *testing.T) {
+		handler, _ := newTestHandler(t)
+		first := shortenText(handler, "https://conflict.example.com/text")
+		second := shortenText(handler, "https://conflict.example.com/text")
+		if first.Code != http.StatusCreated || second.Code != http.StatusConflict {
+			t.Fatalf("Expected 201 then 409, got %d then %d", first.Code, second.Code)
+		}
+		if first.Body.String() != second.Body.String() {
+			t.Errorf("Expected the same short URL, got %q and %q", first.Body.String(), second.Body.String())
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		handler, _ := newTestHandler(t)
+		first, firstURL := shortenJSON(handler, "https://conflict.example.com/json")
+		second, secondURL := shortenJSON(handler, "https://conflict.example.com/json")
+		if first.Code != http.StatusCreated || second.Code != http.StatusConflict {
+			t.Fatalf("Expected 201 then 409, got %d then %d", first.Code, second.Code)
+		}
+		if firstURL == "" || firstURL != secondURL {
+			t.Errorf("Expected the same short URL, got %q and %q", firstURL, secondURL)
+		}
+	})
+
+	t.Run("text then json with surrounding whitespace", func(t *testing.T) {
+		handler, _ := newTestHandler(t)
+		first := shortenText(handler, "https://conflict.example.com/mixed\n")
+		second, secondURL := shortenJSON(handler, "  https://conflict.example.com/mixed ")
+		if first.Code != http.StatusCreated || second.Code != http.StatusConflict {
+			t.Fatalf("Expected 201 then 409, got %d then %d", first.Code, second.Code)
+		}
+		if first.Body.String() != secondURL {
+			t.Errorf("Expected the same short URL, got %q and %q", first.Body.String(), secondURL)
+		}
+	})
+}
+
+func TestBatchShortenMaxBatchSize(t *testing.T) {
+	handler := newTestHandlerWithOptions(t, WithMaxBatchSize(3))
+
+	jsonBatch := func(n int) string {
+		items := make([]models.BatchShortenRequest, n)
+		for i := range items {
+			items[i] = models.BatchShortenRequest{CorrelationID: fmt.Sprint(i), OriginalURL: fmt.Sprintf("https://batch-%d.example.com/%d", n, i)}
+		}
+		body, _ := json.Marshal(items)
+		return string(body)
+	}
+	textBatch := func(n int) string {
+		lines := make([]string, n)
+		for i := range lines {
+			lines[i] = fmt.Sprintf("https://text-%d.example.com/%d", n, i)
+		}
+		return strings.Join(lines, "\n")
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		handle func(http.ResponseWriter, *http.Request)
+		status int
+	}{
+		{"json at limit", "/api/shorten/batch", jsonBatch(3), handler.HandleBatchShortenURL, http.StatusCreated},
+		{"json over limit", "/api/shorten/batch", jsonBatch(4), handler.HandleBatchShortenURL, http.StatusRequestEntityTooLarge},
+		{"text at limit", "/api/shorten/batch/text", textBatch(3), handler.HandleBatchShortenText, http.StatusCreated},
+		{"text over limit", "/api/shorten/batch/text", textBatch(4), handler.HandleBatchShortenText, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			tt.handle(w, req)
+			if w.Code != tt.status {
+				t.Errorf("Expected %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandleBatchShortenURLUnknownFormat(t *testing.T) {
+	handler, _ := newTestHandler(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/shorten/batch?format=xml", strings.NewReader(`[{"correlation_id":"1","original_url":"https://a.example.com"}]`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	handler.HandleBatchShortenURL(w, req)
+	assertAPIError(t, w, http.StatusBadRequest, "invalid_format")
+}
+
+func TestHandleBatchShortenText(t *testing.T) {
+	handler, urlStorage := newTestHandler(t)
+
+	body := "https://text-a.example.com\n\n  https://text-b.example.com  \r\nhttps://text-c.example.com\n"
+	req := authenticatedRequest(http.MethodPost, "/api/shorten/batch/text", body, "text-user")
+	req.Header.Set("Content-Type", "text/plain")
+	w := httptest.NewRecorder()
+	handler.HandleBatchShortenText(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	lines := strings.Split(strings.TrimSuffix(w.Body.String(), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected 3 short URLs, got %d: %q", len(lines), w.Body.String())
+	}
+
+	want := []string{"https://text-a.example.com", "https://text-b.example.com", "https://text-c.example.com"}
+	for i, line := range lines {
+		if !strings.HasPrefix(line, "http://localhost:8080/") {
+			t.Fatalf("Expected short URL on line %d, got %q", i+1, line)
+		}
+		original, ok := urlStorage.AsURLGetter().Get(context.Background(), shortIDFromURL(line))
+		if !ok || original != want[i] {
+			t.Errorf("Line %d: expected %q, got %q", i+1, want[i], original)
+		}
+	}
+}
+
+func TestHandleBatchShortenTextBadLine(t *testing.T) {
+	handler, urlStorage := newTestHandler(t)
+
+	body := "https://text-ok.example.com\n\nnot a url\n"
+	req := authenticatedRequest(http.MethodPost, "/api/shorten/batch/text", body, "text-bad")
+	w := httptest.NewRecorder()
+	handler.HandleBatchShortenText(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected 400, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "line 3") {
+		t.Errorf("Expected error to name line 3, got %q", w.Body.String())
+	}
+
+	urls, err := urlStorage.AsURLFetcher().GetURLsByUserID(context.Background(), "text-bad")
+	if err != nil {
+		t.Fatalf("Failed to list URLs: %v", err)
+	}
+	if len(urls) != 0 {
+		t.Errorf("Expected nothing stored for a rejected batch, found %d URLs", len(urls))
+	}
+}
+
+func TestHandleBatchShortenTextEmpty(t *testing.T) {
+	handler, _ := newTestHandler(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/shorten/batch/text", strings.NewReader("\n  \n"))
+	w := httptest.NewRecorder()
+	handler.HandleBatchShortenText(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400, got %d", w.Code)
+	}
+}
+
+func newTestAdminHandler(t *testing.T) (*AdminHandler, *storage.Storage) {
+	t.Helper()
+	urlStorage, err := storage.NewStorage("", "")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	serviceImpl := service.NewService(
+		urlStorage.AsURLSaver(),
+		urlStorage.AsURLBatchSaver(),
+		urlStorage.AsURLGetter(),
+		urlStorage.AsURLFetcher(),
+		urlStorage.AsURLDeleter(),
+		urlStorage.AsPinger(),
+		generator.NewGenerator(8),
+		"http://localhost:8080",
+	)
+	return NewAdminHandler(service.NewAdminService(serviceImpl, serviceImpl)), urlStorage
+}
+
+func TestBackupExportImportRoundTrip(t *testing.T) {
+	source, sourceStorage := newTestAdminHandler(t)
+	ctx := context.Background()
+	saver := sourceStorage.AsURLSaver()
+	if err := saver.Save(ctx, "bk1", "https://backup.example.com/1", "alice"); err != nil {
+		t.Fatalf("Failed to save URL: %v", err)
+	}
+	if err := saver.Save(ctx, "bk2", "https://backup.example.com/2", "bob"); err != nil {
+		t.Fatalf("Failed to save URL: %v", err)
+	}
+	if err := sourceStorage.AsURLDeleter().DeleteURLs(ctx, []string{"bk2"}, "bob"); err != nil {
+		t.Fatalf("Failed to delete URL: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/internal/export", nil)
+	w := httptest.NewRecorder()
+	source.HandleExport(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 from export, got %d", w.Code)
+	}
+	dump := w.Body.String()
+
+	target, targetStorage := newTestAdminHandler(t)
+	req = httptest.NewRequest(http.MethodPost, "/api/internal/import", strings.NewReader(dump))
+	w = httptest.NewRecorder()
+	target.HandleImport(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected 204 from import, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if got, ok := targetStorage.AsURLGetter().Get(ctx, "bk1"); !ok || got != "https://backup.example.com/1" {
+		t.Errorf("Expected bk1 to be imported, got %q (found=%v)", got, ok)
+	}
+	if _, ok := targetStorage.AsURLGetter().Get(ctx, "bk2"); ok {
+		t.Error("Expected bk2 to stay deleted after import")
+	}
+	urls, err := targetStorage.AsURLFetcher().GetURLsByUserID(ctx, "alice")
+	if err != nil || len(urls) != 1 {
+		t.Errorf("Expected alice to own one URL after import, got %v (err=%v)", urls, err)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/internal/export", nil)
+	w = httptest.NewRecorder()
+	target.HandleExport(w, req)
+	if w.Body.String() != dump {
+		t.Errorf("Expected re-export to match the original dump\nwant %s\ngot  %s", dump, w.Body.String())
+	}
+}
+
+func TestAdminStatsAndRestore(t *testing.T) {
+	admin, urlStorage := newTestAdminHandler(t)
+	ctx := context.Background()
+	saver := urlStorage.AsURLSaver()
+	for i, owner := range []string{"alice", "alice", "bob"} {
+		if err := saver.Save(ctx, fmt.Sprintf("adm%d", i), fmt.Sprintf("https://admin.example.com/%d", i), owner); err != nil {
+			t.Fatalf("Failed to save URL: %v", err)
+		}
+	}
+	if err := urlStorage.AsURLDeleter().DeleteURLs(ctx, []string{"adm1"}, "alice"); err != nil {
+		t.Fatalf("Failed to delete URL: %v", err)
+	}
+	if err := urlStorage.AsURLDeleter().DeleteURLs(ctx, []string{"adm2"}, "bob"); err != nil {
+		t.Fatalf("Failed to delete URL: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	admin.HandleStats(w, httptest.NewRequest(http.MethodGet, "/api/internal/stats", nil))
+	var stats models.AdminStats
+	if err := json.NewDecoder(w.Body).Decode(&stats); err != nil {
+		t.Fatalf("Failed to decode stats: %v", err)
+	}
+	if stats != (models.AdminStats{URLs: 1, Deleted: 2, Users: 1}) {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	w = httptest.NewRecorder()
+	admin.HandleRestore(w, httptest.NewRequest(http.MethodPost, "/api/internal/restore", strings.NewReader(`{"ids":["adm1","adm2","missing"]}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 from restore, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp models.AdminRestoreResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode restore response: %v", err)
+	}
+	if resp.Restored != 2 {
+		t.Errorf("Expected 2 restored URLs, got %d", resp.Restored)
+	}
+	for _, id := range []string{"adm1", "adm2"} {
+		if _, ok := urlStorage.AsURLGetter().Get(ctx, id); !ok {
+			t.Errorf("Expected %s to be restored", id)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	admin.HandleRestore(w, httptest.NewRequest(http.MethodPost, "/api/internal/restore", strings.NewReader(`{"ids":[]}`)))
+	assertAPIError(t, w, http.StatusBadRequest, "empty_batch")
+}
+
+func TestHandleRedirectConfiguredStatus(t *testing.T) {
+	for _, status := range []int{http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect} {
+		t.Run(fmt.Sprint(status), func(t *testing.T) {
+			handler := newTestHandlerWithOptions(t, WithRedirectStatus(status))
+
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("https://status.example.com"))
+			req.Header.Set("Content-Type", "text/plain")
+			w := httptest.NewRecorder()
+			handler.HandleShortenURL(w, req)
+			if w.Code != http.StatusCreated {
+				t.Fatalf("Expected 201, got %d", w.Code)
+			}
+			shortID := shortIDFromURL(w.Body.String())
+
+			router := mux.NewRouter()
+			router.HandleFunc("/{id}", handler.HandleRedirect).Methods(http.MethodGet)
+			w = httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+shortID, nil))
+
+			if w.Code != status {
+				t.Errorf("Expected %d, got %d", status, w.Code)
+			}
+			if location := w.Header().Get("Location"); location != "https://status.example.com" {
+				t.Errorf("Unexpected Location %q", location)
+			}
+		})
+	}
+}
+
+type fixedGenerator string
+
+func (g fixedGenerator) Generate() string {
+	return string(g)
+}
+
+func TestTenantScopedShortenAndRedirect(t *testing.T) {
+	urlStorage, err := storage.NewStorage("", "")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	serviceImpl := service.NewService(
+		urlStorage.AsURLSaver(),
+		urlStorage.AsURLBatchSaver(),
+		urlStorage.AsURLGetter(),
+		urlStorage.AsURLFetcher(),
+		urlStorage.AsURLDeleter(),
+		urlStorage.AsPinger(),
+		fixedGenerator("same1234"),
+		"http://localhost:8080",
+	)
+	handler := NewURLHandler(serviceImpl, serviceImpl, serviceImpl, serviceImpl, serviceImpl, serviceImpl, "http://localhost:8080", WithTenants(true, ""))
+
+	shorten := func(host, tenantName, originalURL string) string {
+		req := httptest.NewRequest(http.MethodPost, "http://"+host+"/", strings.NewReader(originalURL))
+		req.Header.Set("Content-Type", "text/plain")
+		if tenantName != "" {
+			req.Header.Set(DefaultTenantHeader, tenantName)
+		}
+		w := httptest.NewRecorder()
+		handler.HandleShortenURL(w, req)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected 201 for tenant %q, got %d: %s", tenantName, w.Code, w.Body.String())
+		}
+		return w.Body.String()
+	}
+
+	if got := shorten("localhost:8080", "acme", "https://acme.example.com"); got != "http://localhost:8080/acme_same1234" {
+		t.Errorf("Unexpected acme short URL %q", got)
+	}
+	if got := shorten("globex.localhost:8080", "", "https://globex.example.com"); got != "http://localhost:8080/globex_same1234" {
+		t.Errorf("Unexpected globex short URL %q", got)
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/{id}", handler.HandleRedirect).Methods(http.MethodGet)
+	tests := []struct {
+		name     string
+		host     string
+		tenant   string
+		path     string
+		status   int
+		location string
+	}{
+		{"acme prefixed", "localhost:8080", "", "/acme_same1234", http.StatusTemporaryRedirect, "https://acme.example.com"},
+		{"globex prefixed", "localhost:8080", "", "/globex_same1234", http.StatusTemporaryRedirect, "https://globex.example.com"},
+		{"bare id via header", "localhost:8080", "acme", "/same1234", http.StatusTemporaryRedirect, "https://acme.example.com"},
+		{"bare id via subdomain", "globex.localhost:8080", "", "/same1234", http.StatusTemporaryRedirect, "https://globex.example.com"},
+		{"other tenant", "localhost:8080", "acme", "/globex_same1234", http.StatusGone, ""},
+		{"bare id without tenant", "localhost:8080", "", "/same1234", http.StatusGone, ""},
+		{"invalid tenant", "localhost:8080", "Bad Tenant!", "/same1234", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://"+tt.host+tt.path, nil)
+			if tt.tenant != "" {
+				req.Header.Set(DefaultTenantHeader, tt.tenant)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Fatalf("Expected %d, got %d", tt.status, w.Code)
+			}
+			if location := w.Header().Get("Location"); location != tt.location {
+				t.Errorf("Expected Location %q, got %q", tt.location, location)
+			}
+		})
+	}
+}
+
+func TestHandleAvailable(t *testing.T) {
+	handler, urlStorage := newTestHandler(t)
+	ctx := context.Background()
+	for _, shortID := range []string{"taken", "deleted"} {
+		if err := urlStorage.AsURLSaver().Save(ctx, shortID, "https://example.com/"+shortID, "user"); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+	if err := urlStorage.AsURLDeleter().DeleteURLs(ctx, []string{"deleted"}, "user"); err != nil {
+		t.Fatalf("DeleteURLs failed: %v", err)
+	}
+
+	tests := []struct {
+		alias     string
+		status    int
+		available bool
+	}{
+		{"taken", http.StatusOK, false},
+		{"deleted", http.StatusOK, false},
+		{"free-alias", http.StatusOK, true},
+		{"", http.StatusBadRequest, false},
+		{"api", http.StatusBadRequest, false},
+		{"has space", http.StatusBadRequest, false},
+		{"under_score", http.StatusBadRequest, false},
+		{strings.Repeat("a", 65), http.StatusBadRequest, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.alias, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/available?alias="+url.QueryEscape(tt.alias), nil)
+			w := httptest.NewRecorder()
+			handler.HandleAvailable(w, req)
+
+			if tt.status != http.StatusOK {
+				assertAPIError(t, w, tt.status, "invalid_alias")
+				return
+			}
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected 200, got %d", w.Code)
+			}
+			var resp models.AvailabilityResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if resp.Available != tt.available {
+				t.Errorf("Expected available=%v, got %v", tt.available, resp.Available)
+			}
+		})
+	}
+}
+
+func TestIsRedirectStatus(t *testing.T) {
+	for _, status := range []int{301, 302, 307, 308} {
+		if !IsRedirectStatus(status) {
+			t.Errorf("Expected %d to be accepted", status)
+		}
+	}
+	for _, status := range []int{0, 200, 303, 304, 400} {
+		if IsRedirectStatus(status) {
+			t.Errorf("Expected %d to be rejected", status)
+		}
+	}
+}
+
+func TestHandleUpdateURL(t *testing.T) {
+	handler, urlStorage := newTestHandler(t)
+	ctx := context.Background()
+	if err := urlStorage.AsURLSaver().Save(ctx, "upd1", "https://typo.exmaple.com", "owner"); err != nil {
+		t.Fatalf("Failed to save URL: %v", err)
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/user/urls/{id}", handler.HandleUpdateURL).Methods(http.MethodPut)
+	send := func(id, body, userID string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, authenticatedRequest(http.MethodPut, "/api/user/urls/"+id, body, userID))
+		return w
+	}
+
+	t.Run("owner updates target", func(t *testing.T) {
+		w := send("upd1", `{"url":"https://typo.example.com"}`, "owner")
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("Expected 204, got %d: %s", w.Code, w.Body.String())
+		}
+		if got, _ := urlStorage.AsURLGetter().Get(ctx, "upd1"); got != "https://typo.example.com" {
+			t.Errorf("Expected updated target, got %q", got)
+		}
+	})
+
+	t.Run("other user is rejected", func(t *testing.T) {
+		w := send("upd1", `{"url":"https://evil.example.com"}`, "intruder")
+		assertAPIError(t, w, http.StatusForbidden, "forbidden")
+		if got, _ := urlStorage.AsURLGetter().Get(ctx, "upd1"); got != "https://typo.example.com" {
+			t.Errorf("Expected target to be unchanged, got %q", got)
+		}
+	})
+
+	t.Run("missing ID", func(t *testing.T) {
+		w := send("nope", `{"url":"https://example.com"}`, "owner")
+		assertAPIError(t, w, http.StatusNotFound, "not_found")
+	})
+
+	t.Run("invalid URL", func(t *testing.T) {
+		w := send("upd1", `{"url":"not a url"}`, "owner")
+		assertAPIError(t, w, http.StatusBadRequest, "invalid_url")
+	})
+
+	t.Run("unauthenticated", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/api/user/urls/upd1", strings.NewReader(`{"url":"https://example.com"}`)))
+		assertAPIError(t, w, http.StatusUnauthorized, "unauthorized")
+	})
+}
+
+func TestHandleSetDeleted(t *testing.T) {
+	handler, urlStorage := newTestHandler(t)
+	ctx := context.Background()
+	if err := urlStorage.AsURLSaver().Save(ctx, "tog1", "https://example.com", "owner"); err != nil {
+		t.Fatalf("Failed to save URL: %v", err)
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/user/urls/{id}", handler.HandleSetDeleted).Methods(http.MethodPatch)
+	send := func(id, body, userID string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, authenticatedRequest(http.MethodPatch, "/api/user/urls/"+id, body, userID))
+		return w
+	}
+
+	t.Run("owner deletes", func(t *testing.T) {
+		w := send("tog1", `{"is_deleted":true}`, "owner")
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("Expected 204, got %d: %s", w.Code, w.Body.String())
+		}
+		if _, found := urlStorage.AsURLGetter().Get(ctx, "tog1"); found {
+			t.Error("Expected URL to be deleted")
+		}
+	})
+
+	t.Run("other user cannot restore", func(t *testing.T) {
+		w := send("tog1", `{"is_deleted":false}`, "intruder")
+		assertAPIError(t, w, http.StatusForbidden, "forbidden")
+		if _, found := urlStorage.AsURLGetter().Get(ctx, "tog1"); found {
+			t.Error("Expected URL to stay deleted")
+		}
+	})
+
+	t.Run("owner restores", func(t *testing.T) {
+		w := send("tog1", `{"is_deleted":false}`, "owner")
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("Expected 204, got %d: %s", w.Code, w.Body.String())
+		}
+		if _, found := urlStorage.AsURLGetter().Get(ctx, "tog1"); !found {
+			t.Error("Expected URL to be restored")
+		}
+	})
+
+	t.Run("other user cannot delete", func(t *testing.T) {
+		w := send("tog1", `{"is_deleted":true}`, "intruder")
+		assertAPIError(t, w, http.StatusForbidden, "forbidden")
+		if _, found := urlStorage.AsURLGetter().Get(ctx, "tog1"); !found {
+			t.Error("Expected URL to stay active")
+		}
+	})
+
+	t.Run("missing ID", func(t *testing.T) {
+		w := send("nope", `{"is_deleted":true}`, "owner")
+		assertAPIError(t, w, http.StatusNotFound, "not_found")
+	})
+
+	t.Run("missing field", func(t *testing.T) {
+		w := send("tog1", `{}`, "owner")
+		assertAPIError(t, w, http.StatusBadRequest, "validation_failed")
+	})
+}
+
+func TestHandleCountersReflectOperations(t *testing.T) {
+	handler, _ := newTestHandler(t)
+	router := mux.NewRouter()
+	router.HandleFunc("/", handler.HandleShortenURL).Methods(http.MethodPost)
+	router.HandleFunc("/api/user/urls", handler.HandleDeleteURLs).Methods(http.MethodDelete)
+	router.HandleFunc("/api/internal/counters", handler.HandleCounters).Methods(http.MethodGet)
+	router.HandleFunc("/{id}", handler.HandleRedirect).Methods(http.MethodGet)
+
+	var ids []string
+	for _, target := range []string{"https://example.com/a", "https://example.com/b"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, authenticatedRequest(http.MethodPost, "/", target, "owner"))
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d", w.Code)
+		}
+		ids = append(ids, w.Body.String()[strings.LastIndex(w.Body.String(), "/")+1:])
+	}
+
+	for _, path := range []string{"/" + ids[0], "/missing1"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, authenticatedRequest(http.MethodDelete, "/api/user/urls", `["`+ids[1]+`"]`, "owner"))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected 202, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/internal/counters", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	var counters models.Counters
+	if err := json.NewDecoder(w.Body).Decode(&counters); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	want := models.Counters{Shortened: 2, Redirects: 1, Gone: 1, Deleted: 1}
+	if counters != want {
+		t.Errorf("Expected counters %+v, got %+v", want, counters)
+	}
+}
+
+func TestShortenWithTagsAndFilterByTag(t *testing.T) {
+	handler, _ := newTestHandler(t)
+
+	shorten := func(body string) *httptest.ResponseRecorder {
+		req := authenticatedRequest(http.MethodPost, "/api/shorten", body, "tagger")
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		handler.HandleShortenURLJSON(w, req)
+		return w
+	}
+	for _, body := range []string{
+		`{"url":"https://example.com/work","tags":["work","docs"]}`,
+		`{"url":"https://example.com/home","tags":["home"]}`,
+		`{"url":"https://example.com/plain"}`,
+	} {
+		if w := shorten(body); w.Code != http.StatusCreated {
+			t.Fatalf("Expected 201 for %s, got %d: %s", body, w.Code, w.Body.String())
+		}
+	}
+
+	list := func(target string) []models.UserURL {
+		t.Helper()
+		w := httptest.NewRecorder()
+		handler.HandleGetUserURLs(w, authenticatedRequest(http.MethodGet, target, "", "tagger"))
+		if w.Code == http.StatusNoContent {
+			return nil
+		}
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected 200 for %s, got %d", target, w.Code)
+		}
+		var urls []models.UserURL
+		if err := json.NewDecoder(w.Body).Decode(&urls); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return urls
+	}
+
+	if urls := list("/api/user/urls"); len(urls) != 3 {
+		t.Fatalf("Expected 3 URLs, got %d", len(urls))
+	}
+	work := list("/api/user/urls?tag=work")
+	if len(work) != 1 || work[0].OriginalURL != "https://example.com/work" {
+		t.Fatalf("Expected only the work URL, got %+v", work)
+	}
+	if got := work[0].Tags; len(got) != 2 || got[0] != "work" || got[1] != "docs" {
+		t.Errorf("Expected tags [work docs], got %v", got)
+	}
+	if urls := list("/api/user/urls?tag=missing"); len(urls) != 0 {
+		t.Errorf("Expected no URLs for an unused tag, got %+v", urls)
+	}
+
+	w := shorten(`{"url":"https://example.com/bad","tags":["has space"]}`)
+	assertAPIError(t, w, http.StatusBadRequest, "invalid_tags")
+}
+
+type failingTagger struct {
+	*service.Service
+}
+
+func (failingTagger) SetTags(ctx context.Context, shortID string, tags []string, userID string) error {
+	return errors.New("storage unavailable")
+}
+
+func TestShortenReportsLinkWhenTaggingFails(t *testing.T) {
+	urlStorage, err := storage.NewStorage("", "")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	svc := service.NewService(urlStorage.AsURLSaver(), urlStorage.AsURLBatchSaver(), urlStorage.AsURLGetter(),
+		urlStorage.AsURLFetcher(), urlStorage.AsURLDeleter(), urlStorage.AsPinger(), generator.NewGenerator(8), "http://localhost:8080")
+	handler := NewURLHandler(failingTagger{svc}, svc, svc, svc, svc, svc, "http://localhost:8080")
+
+	req := authenticatedRequest(http.MethodPost, "/api/shorten", `{"url":"https://example.com/work","tags":["work"]}`, "tagger")
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	handler.HandleShortenURLJSON(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected 500, got %d", w.Code)
+	}
+	var apiErr models.APIError
+	if err := json.NewDecoder(w.Body).Decode(&apiErr); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if apiErr.Code != "tags_not_saved" {
+		t.Errorf("Expected code tags_not_saved, got %q", apiErr.Code)
+	}
+	shortID := strings.TrimPrefix(apiErr.Result, "http://localhost:8080/")
+	if got, ok := urlStorage.AsURLGetter().Get(context.Background(), shortID); !ok || got != "https://example.com/work" {
+		t.Errorf("Expected the reported link %q to resolve to the saved URL, got %q", apiErr.Result, got)
+	}
+}
+
+func TestShortenWithAlias(t *testing.T) {
+	handler, _ := newTestHandler(t)
+	shorten := func(body, userID string) *httptest.ResponseRecorder {
+		req := authenticatedRequest(http.MethodPost, "/api/shorten", body, userID)
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		handler.HandleShortenURLJSON(w, req)
+		return w
+	}
+
+	w := shorten(`{"url":"https://example.com/a","alias":"docs"}`, "alice")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp models.ShortenResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.Result != "http://localhost:8080/docs" {
+		t.Errorf("Expected the alias as short URL, got %q", resp.Result)
+	}
+
+	w = shorten(`{"url":"https://example.com/b","alias":"docs"}`, "bob")
+	assertAPIError(t, w, http.StatusConflict, "alias_taken")
+
+	w = shorten(`{"url":"https://example.com/c","alias":"api"}`, "bob")
+	assertAPIError(t, w, http.StatusBadRequest, "invalid_alias")
+}
+
+// streamingFetcher yields n URLs through the streaming interface and fails
+// the listing call, so tests notice if the handler buffers.
+type streamingFetcher struct {
+	failingFetcher
+	n int
+}
+
+func (f streamingFetcher) GetURLsByUserIDStream(ctx context.Context, userID string, fn func(models.UserURL) error) error {
+	for i := 0; i < f.n; i++ {
+		url := models.UserURL{
+			ShortURL:    fmt.Sprintf("http://localhost:8080/id%d", i),
+			OriginalURL: fmt.Sprintf("https://example.com/%d", i),
+		}
+		if err := fn(url); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestHandleGetUserURLsStreamsLargeResult(t *testing.T) {
+	const n = 5000
+	handler := NewUserURLsHandler(streamingFetcher{n: n})
+
+	w := httptest.NewRecorder()
+	handler.HandleGetUserURLs(w, authenticatedRequest(http.MethodGet, "/api/user/urls", "", "owner"))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Expected application/json, got %q", ct)
+	}
+	var urls []models.UserURL
+	if err := json.Unmarshal(w.Body.Bytes(), &urls); err != nil {
+		t.Fatalf("Streamed body is not valid JSON: %v", err)
+	}
+	if len(urls) != n {
+		t.Fatalf("Expected %d URLs, got %d", n, len(urls))
+	}
+	if urls[n-1].OriginalURL != fmt.Sprintf("https://example.com/%d", n-1) {
+		t.Errorf("Unexpected last element %+v", urls[n-1])
+	}
+}
+
+func TestHandleGetUserURLsStreamsEmptyResult(t *testing.T) {
+	handler := NewUserURLsHandler(streamingFetcher{})
+
+	w := httptest.NewRecorder()
+	handler.HandleGetUserURLs(w, authenticatedRequest(http.MethodGet, "/api/user/urls", "", "owner"))
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Expected 204, got %d", w.Code)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("Expected an empty body, got %q", w.Body.String())
+	}
+}
+
+func TestAnonymousShortensShareConfiguredUser(t *testing.T) {
+	saved := auth.AnonymousUserID
+	t.Cleanup(func() { auth.AnonymousUserID = saved })
+	auth.AnonymousUserID = "public"
+
+	handler, _ := newTestHandler(t)
+	for _, target := range []string{"https://example.com/one", "https://example.com/two"} {
+		req := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(`{"url":"`+target+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		handler.HandleShortenURLJSON(w, req)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d: %s", w.Code, w.Body.String())
+		}
+		if cookies := w.Result().Cookies(); len(cookies) != 0 {
+			t.Errorf("Expected no cookie for the shared user, got %v", cookies)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	handler.HandleGetUserURLs(w, authenticatedRequest(http.MethodGet, "/api/user/urls", "", "public"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	var urls []models.UserURL
+	if err := json.NewDecoder(w.Body).Decode(&urls); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(urls) != 2 {
+		t.Errorf("Expected both anonymous URLs in the shared listing, got %+v", urls)
+	}
+}
+
+func TestResponsesSetContentTypeBeforeStatus(t *testing.T) {
+	handler, _ := newTestHandler(t)
+
+	tests := []struct {
+		name        string
+		serve       func(w http.ResponseWriter)
+		status      int
+		contentType string
+	}{
+		{"shorten text", func(w http.ResponseWriter) {
+			handler.HandleShortenURL(w, authenticatedRequest(http.MethodPost, "/", "https://example.com/text", "owner"))
+		}, http.StatusCreated, "text/plain"},
+		{"shorten json", func(w http.ResponseWriter) {
+			handler.HandleShortenURLJSON(w, authenticatedRequest(http.MethodPost, "/api/shorten", `{"url":"https://example.com/json"}`, "owner"))
+		}, http.StatusCreated, "application/json"},
+		{"shorten batch", func(w http.ResponseWriter) {
+			handler.HandleBatchShortenURL(w, authenticatedRequest(http.MethodPost, "/api/shorten/batch", `[{"correlation_id":"1","original_url":"https://example.com/batch"}]`, "owner"))
+		}, http.StatusCreated, "application/json"},
+		{"user urls", func(w http.ResponseWriter) {
+			handler.HandleGetUserURLs(w, authenticatedRequest(http.MethodGet, "/api/user/urls", "", "owner"))
+		}, http.StatusOK, "application/json"},
+		{"error", func(w http.ResponseWriter) {
+			handler.HandleShortenURLJSON(w, authenticatedRequest(http.MethodPost, "/api/shorten", `{"url":""}`, "owner"))
+		}, http.StatusBadRequest, "application/json"},
+		{"ping", func(w http.ResponseWriter) {
+			handler.HandlePing(w, httptest.NewRequest(http.MethodGet, "/ping", nil))
+		}, http.StatusOK, "text/plain"},
+		{"healthz", func(w http.ResponseWriter) {
+			handler.HandleHealthz(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		}, http.StatusOK, "application/json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tt.serve(w)
+
+			res := w.Result()
+			if res.StatusCode != tt.status {
+				t.Fatalf("Expected %d, got %d: %s", tt.status, res.StatusCode, w.Body.String())
+			}
+			if ct := res.Header.Get("Content-Type"); ct != tt.contentType {
+				t.Errorf("Expected Content-Type %q at the status line, got %q", tt.contentType, ct)
+			}
+		})
+	}
+}
+
+func TestShortenSetsLocationOnCreation(t *testing.T) {
+	handler, _ := newTestHandler(t)
+
+	tests := []struct {
+		name  string
+		serve func(w http.ResponseWriter, body string)
+		body  string
+	}{
+		{"text", func(w http.ResponseWriter, body string) {
+			handler.HandleShortenURL(w, authenticatedRequest(http.MethodPost, "/?format=id", body, "owner"))
+		}, "https://example.com/located-text"},
+		{"json", func(w http.ResponseWriter, body string) {
+			handler.HandleShortenURLJSON(w, authenticatedRequest(http.MethodPost, "/api/shorten?format=id", body, "owner"))
+		}, `{"url":"https://example.com/located-json"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tt.serve(w, tt.body)
+			if w.Code != http.StatusCreated {
+				t.Fatalf("Expected 201, got %d: %s", w.Code, w.Body.String())
+			}
+			location := w.Result().Header.Get("Location")
+			if !strings.HasPrefix(location, "http://localhost:8080/") || strings.TrimPrefix(location, "http://localhost:8080/") == "" {
+				t.Fatalf("Expected Location to be the full short URL, got %q", location)
+			}
+			if !strings.Contains(w.Body.String(), shortIDFromURL(location)) {
+				t.Errorf("Expected the body to keep naming the short ID of %q, got %q", location, w.Body.String())
+			}
+
+			w = httptest.NewRecorder()
+			tt.serve(w, tt.body)
+			if w.Code != http.StatusConflict {
+				t.Fatalf("Expected 409 for a repeat, got %d", w.Code)
+			}
+			if got := w.Result().Header.Get("Location"); got != "" {
+				t.Errorf("Expected no Location on a conflict, got %q", got)
+			}
+		})
+	}
+}
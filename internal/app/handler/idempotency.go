@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/AlenaMolokova/http/internal/app/middleware"
+	"github.com/AlenaMolokova/http/internal/app/models"
+	"github.com/sirupsen/logrus"
+)
+
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+type capturingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (c *capturingWriter) WriteHeader(statusCode int) {
+	if c.status == 0 {
+		c.status = statusCode
+	}
+	c.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (c *capturingWriter) Write(p []byte) (int, error) {
+	if c.status == 0 {
+		c.status = http.StatusOK
+	}
+	c.body.Write(p)
+	return c.ResponseWriter.Write(p)
+}
+
+func (h *ShortenHandler) withIdempotency(w http.ResponseWriter, r *http.Request, userID string, serve func(http.ResponseWriter, *http.Request)) {
+	key := r.Header.Get(IdempotencyKeyHeader)
+	store, ok := h.shortener.(models.IdempotencyStore)
+	if key == "" || !ok || r.Body == nil {
+		serve(w, r)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if middleware.IsBodyTooLarge(err) {
+		writeJSONError(w, http.StatusRequestEntityTooLarge, "body_too_large", "Request body too large")
+		return
+	}
+	if err != nil {
+		logrus.WithError(err).Error("Failed to read request body")
+		writeJSONError(w, http.StatusBadRequest, "invalid_body", "Failed to read request body")
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	sum := sha256.Sum256(append([]byte(r.Method+" "+r.URL.RequestURI()+"\n"), body...))
+	fingerprint := hex.EncodeToString(sum[:])
+
+	cached, found, err := store.LookupIdempotent(r.Context(), userID, key, fingerprint)
+	if errors.Is(err, models.ErrIdempotencyKeyMismatch) {
+		logrus.WithField("key", key).Warn("Idempotency key reused with a different body")
+		writeJSONError(w, http.StatusConflict, "idempotency_key_mismatch", err.Error())
+		return
+	}
+	if err != nil {
+		logrus.WithError(err).Error("Failed to look up idempotency key")
+		writeStorageError(w, err, "Failed to process request")
+		return
+	}
+	if found {
+		logrus.WithField("key", key).Info("Replaying response for idempotency key")
+		w.Header().Set("Content-Type", cached.ContentType)
+		if cached.Location != "" {
+			w.Header().Set("Location", cached.Location)
+		}
+		w.Header().Set("Idempotent-Replayed", "true")
+		w.WriteHeader(cached.StatusCode)
+		if _, err := w.Write(cached.Body); err != nil {
+			logrus.WithError(err).Error("Failed to write response")
+		}
+		return
+	}
+
+	cw := &capturingWriter{ResponseWriter: w}
+	serve(cw, r)
+
+	if cw.status != 0 && cw.status < http.StatusInternalServerError {
+		store.SaveIdempotent(r.Context(), userID, key, fingerprint, models.IdempotentResponse{
+			StatusCode:  cw.status,
+			ContentType: w.Header().Get("Content-Type"),
+			Location:    w.Header().Get("Location"),
+			Body:        cw.body.Bytes(),
+		})
+	}
+}
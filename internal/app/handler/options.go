@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	DefaultPendingWritesThreshold = 100
+	DefaultMaxBatchSize           = 1000
+)
+
+type options struct {
+	trustProxyHeaders bool
+	baseURLHistory    []string
+	baseURLs          []string
+	storageKind       string
+	redirectStatus    int
+	pendingThreshold  int
+	requireAuth       bool
+	tenants           bool
+	tenantHeader      string
+	maxBatchSize      int
+	deletedRedirect   string
+	caseInsensitive   bool
+}
+
+type Option func(*options)
+
+func WithTrustProxyHeaders(trust bool) Option {
+	return func(o *options) {
+		o.trustProxyHeaders = trust
+	}
+}
+
+func WithBaseURLHistory(bases ...string) Option {
+	return func(o *options) {
+		o.baseURLHistory = append(o.baseURLHistory, bases...)
+	}
+}
+
+// WithBaseURLs adds base URLs for extra short domains. A shorten request
+// whose Host matches one of them gets short URLs on that domain.
+func WithBaseURLs(bases ...string) Option {
+	return func(o *options) {
+		o.baseURLs = append(o.baseURLs, bases...)
+	}
+}
+
+func WithStorageKind(kind string) Option {
+	return func(o *options) {
+		o.storageKind = kind
+	}
+}
+
+func WithRedirectStatus(status int) Option {
+	return func(o *options) {
+		o.redirectStatus = status
+	}
+}
+
+// WithPendingWritesThreshold sets how many unsaved writes a storage may hold
+// before /healthz reports it as degraded.
+func WithPendingWritesThreshold(n int) Option {
+	return func(o *options) {
+		o.pendingThreshold = n
+	}
+}
+
+// WithRequireAuth makes the shorten endpoints answer 401 to requests without
+// an existing authenticated user instead of creating one.
+func WithRequireAuth(require bool) Option {
+	return func(o *options) {
+		o.requireAuth = require
+	}
+}
+
+// WithTenants namespaces short IDs by the tenant named in header or in a
+// subdomain of the base URL. An empty header uses DefaultTenantHeader.
+func WithTenants(enabled bool, header string) Option {
+	return func(o *options) {
+		o.tenants = enabled
+		if header != "" {
+			o.tenantHeader = header
+		}
+	}
+}
+
+// WithMaxBatchSize limits how many URLs one batch request may shorten. Zero
+// or less removes the limit.
+func WithMaxBatchSize(n int) Option {
+	return func(o *options) {
+		o.maxBatchSize = n
+	}
+}
+
+// WithDeletedRedirect sends redirects of soft-deleted short IDs to target
+// with 302 Found instead of answering 410. An empty target keeps the 410.
+func WithDeletedRedirect(target string) Option {
+	return func(o *options) {
+		o.deletedRedirect = target
+	}
+}
+
+// WithCaseInsensitiveIDs lowercases short IDs from the request path before
+// lookup. It only makes sense when IDs are generated from a lowercase alphabet.
+func WithCaseInsensitiveIDs(enabled bool) Option {
+	return func(o *options) {
+		o.caseInsensitive = enabled
+	}
+}
+
+// pathID returns the short ID named in the request path.
+func (o options) pathID(r *http.Request) string {
+	id := mux.Vars(r)["id"]
+	if o.caseInsensitive {
+		id = strings.ToLower(id)
+	}
+	return id
+}
+
+func IsRedirectStatus(status int) bool {
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		redirectStatus:   http.StatusTemporaryRedirect,
+		pendingThreshold: DefaultPendingWritesThreshold,
+		tenantHeader:     DefaultTenantHeader,
+		maxBatchSize:     DefaultMaxBatchSize,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
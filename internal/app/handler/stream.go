@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// streamFlushEvery is how many elements are written between flushes, so long
+// arrays reach the client in chunks even through a compressing writer.
+const streamFlushEvery = 100
+
+// jsonArrayWriter encodes a JSON array element by element. Nothing is sent
+// until the first element, so an empty result can still be answered with a
+// different status.
+type jsonArrayWriter struct {
+	w       http.ResponseWriter
+	enc     *json.Encoder
+	started bool
+	count   int
+}
+
+func newJSONArrayWriter(w http.ResponseWriter) *jsonArrayWriter {
+	return &jsonArrayWriter{w: w, enc: json.NewEncoder(w)}
+}
+
+func (a *jsonArrayWriter) Write(v any) error {
+	sep := ","
+	if !a.started {
+		a.w.Header().Set("Content-Type", "application/json")
+		a.w.WriteHeader(http.StatusOK)
+		a.started = true
+		sep = "["
+	}
+	if _, err := io.WriteString(a.w, sep); err != nil {
+		return err
+	}
+	if err := a.enc.Encode(v); err != nil {
+		return err
+	}
+	a.count++
+	if a.count%streamFlushEvery == 0 {
+		// Writers that cannot flush simply deliver the array at the end.
+		_ = http.NewResponseController(a.w).Flush()
+	}
+	return nil
+}
+
+// Close terminates the array. It reports false when no element was written.
+func (a *jsonArrayWriter) Close() (bool, error) {
+	if !a.started {
+		return false, nil
+	}
+	_, err := io.WriteString(a.w, "]\n")
+	return true, err
+}
@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/AlenaMolokova/http/internal/app/tenant"
+)
+
+const DefaultTenantHeader = "X-Tenant"
+
+var errInvalidTenant = errors.New("invalid tenant")
+
+// requestTenant resolves the tenant of r from the tenant header or, failing
+// that, from a subdomain of the base URL host. It returns an empty tenant
+// when tenancy is disabled or the request names none.
+func (o options) requestTenant(r *http.Request, baseURL string) (string, error) {
+	if !o.tenants {
+		return "", nil
+	}
+
+	if name := strings.TrimSpace(r.Header.Get(o.tenantHeader)); name != "" {
+		name = strings.ToLower(name)
+		if !tenant.Valid(name) {
+			return "", errInvalidTenant
+		}
+		return name, nil
+	}
+
+	base, err := url.Parse(baseURL)
+	if err != nil || base.Hostname() == "" {
+		return "", nil
+	}
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	label, ok := strings.CutSuffix(strings.ToLower(host), "."+strings.ToLower(base.Hostname()))
+	if !ok || strings.Contains(label, ".") || !tenant.Valid(label) {
+		return "", nil
+	}
+	return label, nil
+}
+
+// withTenant stores the request tenant in the context of r.
+func (o options) withTenant(r *http.Request, baseURL string) (*http.Request, error) {
+	name, err := o.requestTenant(r, baseURL)
+	if err != nil || name == "" {
+		return r, err
+	}
+	return r.WithContext(tenant.WithTenant(r.Context(), name)), nil
+}
+
+// scopedID maps a short ID from the redirect path to its stored form. A bare
+// ID is looked up under the request tenant; an ID naming another tenant is
+// reported as not found.
+func scopedID(r *http.Request, id string) (string, bool) {
+	current := tenant.FromContext(r.Context())
+	if current == "" {
+		return id, true
+	}
+	owner, _ := tenant.Split(id)
+	if owner == "" {
+		return tenant.Join(current, id), true
+	}
+	return id, owner == current
+}
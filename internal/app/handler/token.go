@@ -0,0 +1,32 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/AlenaMolokova/http/internal/app/auth"
+	"github.com/AlenaMolokova/http/internal/app/models"
+	"github.com/AlenaMolokova/http/internal/app/respond"
+	"github.com/sirupsen/logrus"
+)
+
+func (h *AuthHandler) HandleIssueToken(w http.ResponseWriter, r *http.Request) {
+	logrus.Info("Handling token request")
+
+	userID, ok := authenticatedUserID(r)
+	if !ok {
+		userID = auth.GenerateUserID()
+		if id, found := auth.UserIDFromContext(r.Context()); found && id != auth.AnonymousUserID {
+			userID = id
+		}
+		logrus.WithField("userID", userID).Info("Issuing token for a new user")
+	}
+
+	token, err := auth.IssueJWT(userID)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to issue token")
+		writeJSONError(w, http.StatusInternalServerError, "internal_error", "Failed to issue token")
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, models.TokenResponse{Token: token, UserID: userID})
+}
@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/AlenaMolokova/http/internal/app/auth"
+	"github.com/AlenaMolokova/http/internal/app/logging"
+	"github.com/sirupsen/logrus"
+)
+
+func requestUserID(w http.ResponseWriter, r *http.Request) string {
+	if userID, ok := auth.UserIDFromContext(r.Context()); ok {
+		logging.SetUserID(r.Context(), userID)
+		return userID
+	}
+
+	userID, err := auth.GetUserIDFromCookie(r)
+	if err != nil {
+		logrus.WithError(err).Warn("No valid cookie found, using an anonymous user ID")
+		var minted bool
+		userID, minted = auth.AnonymousUser()
+		if minted {
+			auth.SetUserIDCookie(w, userID)
+		}
+	}
+	logging.SetUserID(r.Context(), userID)
+	return userID
+}
+
+func (h *ShortenHandler) shortenUserID(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if !h.opts.requireAuth {
+		return requestUserID(w, r), true
+	}
+	userID, ok := authenticatedUserID(r)
+	if !ok {
+		logrus.Warn("Anonymous shortening is disabled, unauthorized")
+		writeJSONError(w, http.StatusUnauthorized, "unauthorized", "Unauthorized")
+	}
+	return userID, ok
+}
+
+func authenticatedUserID(r *http.Request) (string, bool) {
+	if userID, ok := auth.UserIDFromContext(r.Context()); ok {
+		if auth.IsNewUser(r.Context()) {
+			return userID, false
+		}
+		logging.SetUserID(r.Context(), userID)
+		return userID, true
+	}
+
+	userID, err := auth.GetUserIDFromCookie(r)
+	if err != nil {
+		return "", false
+	}
+	logging.SetUserID(r.Context(), userID)
+	return userID, true
+}
+
+func shortIDFromURL(shortURL string) string {
+	return shortURL[strings.LastIndex(shortURL, "/")+1:]
+}
@@ -0,0 +1,49 @@
+package logging
+
+import (
+	"context"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+type accessFieldsKey struct{}
+
+type AccessFields struct {
+	mu     sync.Mutex
+	fields logrus.Fields
+}
+
+func NewAccessContext(ctx context.Context) (context.Context, *AccessFields) {
+	fields := &AccessFields{fields: logrus.Fields{}}
+	return context.WithValue(ctx, accessFieldsKey{}, fields), fields
+}
+
+func (a *AccessFields) Fields() logrus.Fields {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	fields := make(logrus.Fields, len(a.fields))
+	for k, v := range a.fields {
+		fields[k] = v
+	}
+	return fields
+}
+
+func SetAccessField(ctx context.Context, key string, value interface{}) {
+	a, ok := ctx.Value(accessFieldsKey{}).(*AccessFields)
+	if !ok {
+		return
+	}
+	a.mu.Lock()
+	a.fields[key] = value
+	a.mu.Unlock()
+}
+
+func SetUserID(ctx context.Context, userID string) {
+	SetAccessField(ctx, "user_id", userID)
+}
+
+func SetShortID(ctx context.Context, shortID string) {
+	SetAccessField(ctx, "short_id", shortID)
+}
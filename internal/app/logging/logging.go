@@ -0,0 +1,37 @@
+package logging
+
+import (
+	"strings"
+
+	"github.com/AlenaMolokova/http/internal/app/config"
+	"github.com/sirupsen/logrus"
+)
+
+func Configure(cfg *config.Config) {
+	switch strings.ToLower(cfg.LogFormat) {
+	case "text":
+		logrus.SetFormatter(&logrus.TextFormatter{FullTimestamp: true})
+	case "json", "":
+		logrus.SetFormatter(&logrus.JSONFormatter{})
+	default:
+		logrus.SetFormatter(&logrus.JSONFormatter{})
+		logrus.WithField("format", cfg.LogFormat).Warn("Unknown log format, using json")
+	}
+
+	if cfg.LogFile != "" {
+		w, err := NewRotatingWriter(cfg.LogFile, int64(cfg.LogMaxSizeMB)*1024*1024, cfg.LogMaxBackups)
+		if err != nil {
+			logrus.WithError(err).Warn("Failed to open log file, logging to stderr")
+		} else {
+			logrus.SetOutput(w)
+		}
+	}
+
+	level, err := logrus.ParseLevel(cfg.LogLevel)
+	if err != nil {
+		logrus.SetLevel(logrus.InfoLevel)
+		logrus.WithField("level", cfg.LogLevel).Warn("Invalid log level, using info")
+		return
+	}
+	logrus.SetLevel(level)
+}
@@ -0,0 +1,47 @@
+package logging
+
+import (
+	"testing"
+
+	"github.com/AlenaMolokova/http/internal/app/config"
+	"github.com/sirupsen/logrus"
+)
+
+func TestConfigureLevel(t *testing.T) {
+	defer logrus.SetLevel(logrus.GetLevel())
+
+	tests := []struct {
+		input string
+		want  logrus.Level
+	}{
+		{"debug", logrus.DebugLevel},
+		{"info", logrus.InfoLevel},
+		{"WARN", logrus.WarnLevel},
+		{"error", logrus.ErrorLevel},
+		{"trace", logrus.TraceLevel},
+		{"", logrus.InfoLevel},
+		{"verbose", logrus.InfoLevel},
+	}
+
+	for _, tt := range tests {
+		logrus.SetLevel(logrus.PanicLevel)
+		Configure(&config.Config{LogLevel: tt.input})
+		if got := logrus.GetLevel(); got != tt.want {
+			t.Errorf("Configure(%q): expected level %s, got %s", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestConfigureFormat(t *testing.T) {
+	defer logrus.SetFormatter(logrus.StandardLogger().Formatter)
+
+	Configure(&config.Config{LogLevel: "info", LogFormat: "text"})
+	if _, ok := logrus.StandardLogger().Formatter.(*logrus.TextFormatter); !ok {
+		t.Errorf("Expected text formatter, got %T", logrus.StandardLogger().Formatter)
+	}
+
+	Configure(&config.Config{LogLevel: "info", LogFormat: "json"})
+	if _, ok := logrus.StandardLogger().Formatter.(*logrus.JSONFormatter); !ok {
+		t.Errorf("Expected json formatter, got %T", logrus.StandardLogger().Formatter)
+	}
+}
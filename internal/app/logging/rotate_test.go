@@ -0,0 +1,83 @@
+package logging
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRotatingWriterRotatesAndCompresses(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	w, err := NewRotatingWriter(path, 100, 2)
+	if err != nil {
+		t.Fatalf("NewRotatingWriter failed: %v", err)
+	}
+	defer w.Close()
+
+	first := strings.Repeat("a", 60) + "\n"
+	second := strings.Repeat("b", 60) + "\n"
+	for _, line := range []string{first, second} {
+		if _, err := w.Write([]byte(line)); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+
+	current, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read current log: %v", err)
+	}
+	if string(current) != second {
+		t.Errorf("Expected current log to contain only the latest line, got %q", current)
+	}
+
+	if got := readGzip(t, path+".1.gz"); got != first {
+		t.Errorf("Expected rotated log to contain the first line, got %q", got)
+	}
+}
+
+func TestRotatingWriterKeepsMaxBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	w, err := NewRotatingWriter(path, 10, 2)
+	if err != nil {
+		t.Fatalf("NewRotatingWriter failed: %v", err)
+	}
+	defer w.Close()
+
+	for _, line := range []string{"one-------\n", "two-------\n", "three-----\n", "four------\n"} {
+		if _, err := w.Write([]byte(line)); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+
+	if got := readGzip(t, path+".1.gz"); got != "three-----\n" {
+		t.Errorf("Expected newest backup to hold three, got %q", got)
+	}
+	if got := readGzip(t, path+".2.gz"); got != "two-------\n" {
+		t.Errorf("Expected oldest backup to hold two, got %q", got)
+	}
+	if _, err := os.Stat(path + ".3.gz"); !os.IsNotExist(err) {
+		t.Errorf("Expected no more than 2 backups, stat err: %v", err)
+	}
+}
+
+func readGzip(t *testing.T, path string) string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open %s: %v", path, err)
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("Failed to create gzip reader for %s: %v", path, err)
+	}
+	data, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	return string(data)
+}
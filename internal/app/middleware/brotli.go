@@ -0,0 +1,17 @@
+package middleware
+
+import (
+	"io"
+
+	"github.com/andybalholm/brotli"
+)
+
+var BrotliCompressor = Compressor{
+	Encoding: "br",
+	NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+		return brotli.NewWriterLevel(w, brotli.DefaultCompression), nil
+	},
+	NewReader: func(r io.Reader) (io.ReadCloser, error) {
+		return io.NopCloser(brotli.NewReader(r)), nil
+	},
+}
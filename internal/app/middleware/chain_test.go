@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestChainRunsMiddlewaresInOrder(t *testing.T) {
+	var calls []string
+	record := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls = append(calls, name+":in")
+				next.ServeHTTP(w, r)
+				calls = append(calls, name+":out")
+			})
+		}
+	}
+
+	handler := Chain(record("first"), record("second"), record("third"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, "handler")
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	want := []string{"first:in", "second:in", "third:in", "handler", "third:out", "second:out", "first:out"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("unexpected order:\nwant %v\ngot  %v", want, calls)
+	}
+}
+
+func TestChainEmpty(t *testing.T) {
+	called := false
+	Chain()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if !called {
+		t.Error("expected the handler to run")
+	}
+}
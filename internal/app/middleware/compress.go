@@ -0,0 +1,194 @@
+package middleware
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+type Compressor struct {
+	Encoding  string
+	NewWriter func(w io.Writer) (io.WriteCloser, error)
+	NewReader func(r io.Reader) (io.ReadCloser, error)
+}
+
+var DefaultCompressors = []Compressor{BrotliCompressor, GzipCompressor}
+
+type decompressReader struct {
+	r       io.ReadCloser
+	decoder io.ReadCloser
+}
+
+func (d *decompressReader) Read(p []byte) (n int, err error) {
+	return d.decoder.Read(p)
+}
+
+func (d *decompressReader) Close() error {
+	if err := d.decoder.Close(); err != nil {
+		d.r.Close()
+		return err
+	}
+	return d.r.Close()
+}
+
+type compressWriter struct {
+	http.ResponseWriter
+	w io.WriteCloser
+}
+
+func (c *compressWriter) WriteHeader(statusCode int) {
+	c.ResponseWriter.Header().Del("Content-Length")
+	c.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (c *compressWriter) Write(p []byte) (int, error) {
+	c.ResponseWriter.Header().Del("Content-Length")
+	return c.w.Write(p)
+}
+
+// Flush pushes the data buffered in the encoder out as a complete compressed
+// block, then flushes the underlying writer, so streaming handlers are not
+// held back until the response ends.
+func (c *compressWriter) Flush() {
+	if f, ok := c.w.(interface{ Flush() error }); ok {
+		if err := f.Flush(); err != nil {
+			logrus.WithError(err).Error("Failed to flush response encoder")
+			return
+		}
+	}
+	if f, ok := c.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (c *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := c.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	return h.Hijack()
+}
+
+func (c *compressWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
+func CompressMiddleware(compressors ...Compressor) Middleware {
+	return LimitedCompressMiddleware(0, compressors...)
+}
+
+// LimitedCompressMiddleware behaves like CompressMiddleware but caps decoded
+// request bodies at limit bytes, so a small compressed payload cannot expand
+// into an unbounded read. Exceeding the cap surfaces as *http.MaxBytesError.
+// A non-positive limit disables the cap.
+func LimitedCompressMiddleware(limit int64, compressors ...Compressor) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if encoding := strings.TrimSpace(strings.ToLower(r.Header.Get("Content-Encoding"))); encoding != "" {
+				if c, ok := findCompressor(compressors, encoding); ok && c.NewReader != nil {
+					body := r.Body
+					decoder, err := c.NewReader(body)
+					if err != nil {
+						logrus.WithError(err).WithField("encoding", encoding).Error("Failed to create request decoder")
+						http.Error(w, "Invalid "+encoding+" data", http.StatusBadRequest)
+						return
+					}
+					if limit > 0 {
+						decoder = http.MaxBytesReader(w, decoder, limit)
+					}
+					r.Body = &decompressReader{r: body, decoder: decoder}
+
+					if r.Header.Get("Content-Type") == "application/x-"+encoding {
+						r.Header.Set("Content-Type", "text/plain")
+					}
+					r.Header.Del("Content-Encoding")
+				}
+			}
+
+			contentType := w.Header().Get("Content-Type")
+			compressible := contentType == "" ||
+				strings.Contains(contentType, "application/json") ||
+				strings.Contains(contentType, "text/html") ||
+				strings.Contains(contentType, "text/plain")
+
+			c, ok := negotiateEncoding(r.Header.Get("Accept-Encoding"), compressors)
+			if !ok || !compressible {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			enc, err := c.NewWriter(w)
+			if err != nil {
+				logrus.WithError(err).WithField("encoding", c.Encoding).Error("Failed to create response encoder")
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("Content-Encoding", c.Encoding)
+			w.Header().Add("Vary", "Accept-Encoding")
+
+			// Deferred so pooled encoders are released even if next panics.
+			defer func() {
+				if err := enc.Close(); err != nil {
+					logrus.WithError(err).WithField("encoding", c.Encoding).Error("Failed to flush response encoder")
+				}
+			}()
+
+			next.ServeHTTP(&compressWriter{ResponseWriter: w, w: enc}, r)
+		})
+	}
+}
+
+func findCompressor(compressors []Compressor, encoding string) (Compressor, bool) {
+	for _, c := range compressors {
+		if c.Encoding == encoding {
+			return c, true
+		}
+	}
+	return Compressor{}, false
+}
+
+func negotiateEncoding(header string, compressors []Compressor) (Compressor, bool) {
+	if header == "" {
+		return Compressor{}, false
+	}
+
+	weights := make(map[string]float64)
+	wildcard := -1.0
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			key, value, found := strings.Cut(strings.TrimSpace(param), "=")
+			if found && strings.EqualFold(key, "q") {
+				if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if name == "*" {
+			wildcard = q
+		} else if name != "" {
+			weights[name] = q
+		}
+	}
+
+	var best Compressor
+	bestQ := 0.0
+	for _, c := range compressors {
+		q, listed := weights[c.Encoding]
+		if !listed {
+			q = wildcard
+		}
+		if q > bestQ {
+			best, bestQ = c, q
+		}
+	}
+	return best, bestQ > 0
+}
@@ -0,0 +1,155 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+const compressTestBody = `{"result":"http://localhost:8080/abcdefgh"}`
+
+func serveCompressed(t *testing.T, acceptEncoding string) *httptest.ResponseRecorder {
+	t.Helper()
+	handler := CompressMiddleware(DefaultCompressors...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, compressTestBody)
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func decodeBody(t *testing.T, encoding string, body []byte) string {
+	t.Helper()
+	var r io.Reader
+	switch encoding {
+	case "br":
+		r = brotli.NewReader(bytes.NewReader(body))
+	case "gzip":
+		gz, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("Failed to open gzip body: %v", err)
+		}
+		r = gz
+	default:
+		r = bytes.NewReader(body)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("Failed to decode %q body: %v", encoding, err)
+	}
+	return string(data)
+}
+
+func TestCompressMiddlewareNegotiation(t *testing.T) {
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		want           string
+	}{
+		{"brotli only", "br", "br"},
+		{"gzip only", "gzip", "gzip"},
+		{"both prefers brotli", "gzip, deflate, br", "br"},
+		{"q-values prefer gzip", "br;q=0.5, gzip;q=0.9", "gzip"},
+		{"brotli refused", "br;q=0, gzip", "gzip"},
+		{"wildcard", "*", "br"},
+		{"wildcard with brotli excluded", "*, br;q=0", "gzip"},
+		{"neither", "", ""},
+		{"unsupported only", "deflate", ""},
+		{"identity only", "identity", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveCompressed(t, tt.acceptEncoding)
+			if got := w.Header().Get("Content-Encoding"); got != tt.want {
+				t.Fatalf("Expected Content-Encoding %q, got %q", tt.want, got)
+			}
+			if got := decodeBody(t, tt.want, w.Body.Bytes()); got != compressTestBody {
+				t.Errorf("Expected body %q, got %q", compressTestBody, got)
+			}
+		})
+	}
+}
+
+func TestCompressMiddlewareDecodesBrotliRequest(t *testing.T) {
+	var compressed bytes.Buffer
+	bw := brotli.NewWriter(&compressed)
+	io.WriteString(bw, "https://example.com")
+	bw.Close()
+
+	var got string
+	handler := CompressMiddleware(DefaultCompressors...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		got = string(data)
+	}))
+	req := httptest.NewRequest(http.MethodPost, "/", &compressed)
+	req.Header.Set("Content-Encoding", "br")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if got != "https://example.com" {
+		t.Errorf("Expected decoded body, got %q", got)
+	}
+}
+
+func TestGzipMiddlewareIgnoresBrotli(t *testing.T) {
+	handler := GzipMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "plain")
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "br")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if enc := w.Header().Get("Content-Encoding"); enc != "" {
+		t.Errorf("Expected no encoding, got %q", enc)
+	}
+	if !strings.Contains(w.Body.String(), "plain") {
+		t.Errorf("Unexpected body %q", w.Body.String())
+	}
+}
+
+func TestGzipWriterFlushesCompressedChunks(t *testing.T) {
+	rec := httptest.NewRecorder()
+	handler := Chain(GzipMiddleware, LoggingMiddleware)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, compressTestBody)
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			t.Fatal("Expected the wrapped writer to implement http.Flusher")
+		}
+		flusher.Flush()
+		if !rec.Flushed {
+			t.Error("Expected the flush to reach the underlying writer")
+		}
+
+		zr, err := gzip.NewReader(bytes.NewReader(rec.Body.Bytes()))
+		if err != nil {
+			t.Fatalf("Flushed data is not a gzip stream: %v", err)
+		}
+		got := make([]byte, len(compressTestBody))
+		if _, err := io.ReadFull(zr, got); err != nil || string(got) != compressTestBody {
+			t.Errorf("Expected %q before the handler returned, got %q (err %v)", compressTestBody, got, err)
+		}
+
+		if _, _, err := http.NewResponseController(w).Hijack(); !errors.Is(err, http.ErrNotSupported) {
+			t.Errorf("Expected hijacking to pass through and report ErrNotSupported, got %v", err)
+		}
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	handler.ServeHTTP(rec, req)
+}
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ConcurrencyLimitMiddleware lets at most max requests run at once and
+// answers the rest with 503 and Retry-After. Zero or less disables the limit.
+func ConcurrencyLimitMiddleware(max int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if max <= 0 {
+			return next
+		}
+		sem := make(chan struct{}, max)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case sem <- struct{}{}:
+			default:
+				logrus.WithField("uri", RedactedURI(r)).Warn("Too many concurrent requests")
+				w.Header().Set("Retry-After", "1")
+				http.Error(w, "Server is busy", http.StatusServiceUnavailable)
+				return
+			}
+			// Deferred so a panicking handler still frees its slot.
+			defer func() { <-sem }()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
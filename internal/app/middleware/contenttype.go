@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RequireContentType rejects requests whose Content-Type media type is not
+// one of allowed with 415. Parameters such as charset are ignored.
+func RequireContentType(allowed ...string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header := r.Header.Get("Content-Type")
+			mediaType, _, err := mime.ParseMediaType(header)
+			if err == nil {
+				for _, t := range allowed {
+					if strings.EqualFold(mediaType, t) {
+						next.ServeHTTP(w, r)
+						return
+					}
+				}
+			}
+
+			logrus.WithFields(logrus.Fields{
+				"content_type": header,
+				"uri":          RedactedURI(r),
+			}).Warn("Unsupported request content type")
+			http.Error(w, "Content-Type must be "+strings.Join(allowed, " or "), http.StatusUnsupportedMediaType)
+		})
+	}
+}
@@ -0,0 +1,259 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/AlenaMolokova/http/internal/app/models"
+)
+
+func TestGzipMiddlewareLargeBatchResponse(t *testing.T) {
+	const total = 20000
+	items := make([]models.BatchShortenResponse, total)
+	for i := range items {
+		items[i] = models.BatchShortenResponse{
+			CorrelationID: strconv.Itoa(i),
+			ShortURL:      fmt.Sprintf("http://localhost:8080/id%06d", i),
+		}
+	}
+
+	handler := GzipMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := json.Marshal(items)
+		if err != nil {
+			t.Errorf("Failed to marshal items: %v", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(items)
+	}))
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodPost, server.URL, nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d", resp.StatusCode)
+	}
+	if resp.Header.Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Expected gzip response, got %q", resp.Header.Get("Content-Encoding"))
+	}
+
+	var compressed bytes.Buffer
+	if _, err := compressed.ReadFrom(resp.Body); err != nil {
+		t.Fatalf("Failed to read body: %v", err)
+	}
+	if resp.ContentLength >= 0 && resp.ContentLength != int64(compressed.Len()) {
+		t.Fatalf("Content-Length %d does not match body size %d", resp.ContentLength, compressed.Len())
+	}
+
+	gz, err := gzip.NewReader(&compressed)
+	if err != nil {
+		t.Fatalf("Failed to create gzip reader: %v", err)
+	}
+	var got []models.BatchShortenResponse
+	if err := json.NewDecoder(gz).Decode(&got); err != nil {
+		t.Fatalf("Failed to decode gzipped body: %v", err)
+	}
+	if len(got) != total {
+		t.Fatalf("Expected %d items, got %d", total, len(got))
+	}
+	if got[total-1] != items[total-1] {
+		t.Errorf("Expected last item %+v, got %+v", items[total-1], got[total-1])
+	}
+}
+
+func gzipBytes(t testing.TB, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(data); err != nil {
+		t.Fatalf("Failed to gzip data: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("Failed to close gzip writer: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func readBodyHandler(w http.ResponseWriter, r *http.Request) {
+	if _, err := io.ReadAll(r.Body); err != nil {
+		if IsBodyTooLarge(err) {
+			http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func TestGzipRequestDecompressionLimit(t *testing.T) {
+	const limit = 1024
+	handler := LimitedCompressMiddleware(limit, GzipCompressor)(http.HandlerFunc(readBodyHandler))
+
+	tests := []struct {
+		name string
+		size int
+		want int
+	}{
+		{"within limit", limit, http.StatusOK},
+		{"expands past limit", 256 << 10, http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload := gzipBytes(t, []byte(strings.Repeat("a", tt.size)))
+			if len(payload) > limit {
+				t.Fatalf("Compressed payload of %d bytes should fit under the %d byte limit", len(payload), limit)
+			}
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(payload))
+			req.Header.Set("Content-Encoding", "gzip")
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Errorf("Expected %d, got %d: %s", tt.want, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func FuzzGzipMiddlewareRequestBody(f *testing.F) {
+	f.Add([]byte{})
+	f.Add([]byte("not gzip at all"))
+	f.Add(gzipBytes(f, []byte(`{"url":"https://example.com"}`)))
+	f.Add(gzipBytes(f, []byte(strings.Repeat("a", 4096))))
+
+	handler := LimitedCompressMiddleware(1<<16, GzipCompressor)(http.HandlerFunc(readBodyHandler))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(data))
+		req.Header.Set("Content-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		switch w.Code {
+		case http.StatusOK, http.StatusBadRequest, http.StatusRequestEntityTooLarge:
+		default:
+			t.Errorf("Unexpected status %d", w.Code)
+		}
+	})
+}
+
+type closeRecorder struct {
+	io.WriteCloser
+	closed *bool
+}
+
+func (c closeRecorder) Close() error {
+	*c.closed = true
+	return c.WriteCloser.Close()
+}
+
+func TestCompressMiddlewareReleasesEncoderOnPanic(t *testing.T) {
+	var closed bool
+	tracked := Compressor{
+		Encoding: "gzip",
+		NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+			enc, err := GzipCompressor.NewWriter(w)
+			return closeRecorder{WriteCloser: enc, closed: &closed}, err
+		},
+	}
+	handler := CompressMiddleware(tracked)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("Expected the panic to propagate")
+			}
+		}()
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+	if !closed {
+		t.Error("Expected the encoder to be closed and released despite the panic")
+	}
+}
+
+func TestPooledGzipWriterProducesIndependentStreams(t *testing.T) {
+	handler := GzipMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, r.URL.Query().Get("body"))
+	}))
+
+	for _, body := range []string{"first", "second", "third"} {
+		req := httptest.NewRequest(http.MethodGet, "/?body="+body, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		zr, err := gzip.NewReader(rec.Body)
+		if err != nil {
+			t.Fatalf("Response is not gzip: %v", err)
+		}
+		got, err := io.ReadAll(zr)
+		if err != nil || string(got) != body {
+			t.Errorf("Expected %q from a reused writer, got %q (err %v)", body, got, err)
+		}
+	}
+}
+
+// BenchmarkGzipMiddleware compares pooled writers against allocating a new
+// gzip.Writer per response; run with -benchmem to see the difference.
+func BenchmarkGzipMiddleware(b *testing.B) {
+	body := strings.Repeat(`{"short_url":"http://localhost:8080/abcdefgh","original_url":"https://example.com/"},`, 50)
+	unpooled := Compressor{
+		Encoding: "gzip",
+		NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+			return gzip.NewWriterLevel(w, gzip.BestSpeed)
+		},
+	}
+
+	for _, bc := range []struct {
+		name       string
+		compressor Compressor
+	}{
+		{"pooled", GzipCompressor},
+		{"unpooled", unpooled},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			handler := CompressMiddleware(bc.compressor)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				io.WriteString(w, body)
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept-Encoding", "gzip")
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				handler.ServeHTTP(httptest.NewRecorder(), req)
+			}
+		})
+	}
+}
@@ -1,9 +1,12 @@
 package middleware
 
 import (
+	"bufio"
+	"net"
 	"net/http"
 	"time"
 
+	"github.com/AlenaMolokova/http/internal/app/logging"
 	"github.com/sirupsen/logrus"
 )
 
@@ -38,23 +41,58 @@ func (rw *responseWriter) Write(b []byte) (int,error) {
 	return size, err
 }
 
+func (rw *responseWriter) Flush() {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := rw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	return h.Hijack()
+}
+
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 func LoggingMiddleware(next http.Handler) http.Handler {
+	return SlowLoggingMiddleware(0)(next)
+}
+
+// SlowLoggingMiddleware logs every request like LoggingMiddleware and also
+// logs requests slower than threshold at warn level with slow=true. Zero or
+// less disables the slow-request entry.
+func SlowLoggingMiddleware(threshold time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return logRequests(next, threshold)
+	}
+}
+
+func logRequests(next http.Handler, threshold time.Duration) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request){
 		start := time.Now()
 		rw := newResponseWriter(w)
+		ctx, accessFields := logging.NewAccessContext(r.Context())
 
-		next.ServeHTTP(rw, r)
+		next.ServeHTTP(rw, r.WithContext(ctx))
 
 		duration :=time.Since(start)
 
 		entry := logrus.WithFields(logrus.Fields{
-			"uri": r.RequestURI,
+			"uri": RedactedURI(r),
 			"method": r.Method,
 			"duration": duration.String(),
 			"status": rw.status,
 			"response_size": rw.size,
 			"content_type": r.Header.Get("Content-Type"),
-		})
+		}).WithFields(accessFields.Fields())
 
 		if r.Method == http.MethodPost && r.RequestURI=="/" {
 			entry =entry.WithField("operation", "shorten_url")
@@ -63,5 +101,8 @@ func LoggingMiddleware(next http.Handler) http.Handler {
 		}
 
 		entry.Info("Request processed")
+		if threshold > 0 && duration > threshold {
+			entry.WithField("slow", true).Warn("Slow request")
+		}
 	})
 }
\ No newline at end of file
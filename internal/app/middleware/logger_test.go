@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestSlowLoggingMiddlewareWarnsAboveThreshold(t *testing.T) {
+	tests := []struct {
+		name      string
+		threshold time.Duration
+		delay     time.Duration
+		slow      bool
+	}{
+		{"slow request", 10 * time.Millisecond, 30 * time.Millisecond, true},
+		{"fast request", time.Second, 0, false},
+		{"disabled", 0, 30 * time.Millisecond, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hook := test.NewGlobal()
+			handler := SlowLoggingMiddleware(tt.threshold)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(tt.delay)
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/abc", nil))
+
+			var info, warn int
+			for _, e := range hook.AllEntries() {
+				switch {
+				case e.Level == logrus.InfoLevel && e.Message == "Request processed":
+					info++
+					if _, ok := e.Data["slow"]; ok {
+						t.Error("Expected the info entry to have no slow field")
+					}
+				case e.Level == logrus.WarnLevel && e.Data["slow"] == true:
+					warn++
+				}
+			}
+			if info != 1 {
+				t.Errorf("Expected 1 info entry, got %d", info)
+			}
+			if want := map[bool]int{true: 1, false: 0}[tt.slow]; warn != want {
+				t.Errorf("Expected %d slow warn entries, got %d", want, warn)
+			}
+		})
+	}
+}
+
+func TestLoggingMiddlewareRedactsLinkPassword(t *testing.T) {
+	hook := test.NewGlobal()
+	defer hook.Reset()
+
+	handler := LoggingMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/abc?pw=hunter2&x=1", nil))
+
+	entry := hook.LastEntry()
+	if entry == nil {
+		t.Fatal("Expected an access log entry")
+	}
+	uri, _ := entry.Data["uri"].(string)
+	if strings.Contains(uri, "hunter2") || !strings.Contains(uri, "pw=REDACTED") {
+		t.Errorf("Expected pw to be redacted in the logged URI, got %q", uri)
+	}
+}
@@ -0,0 +1,29 @@
+package middleware
+
+import "net/http"
+
+// sensitiveParams are query parameters whose values never reach the logs,
+// such as link passwords submitted as ?pw=.
+var sensitiveParams = []string{"pw"}
+
+// RedactedURI returns the request URI for logging, with the values of
+// sensitive query parameters masked.
+func RedactedURI(r *http.Request) string {
+	if r.URL == nil || r.URL.RawQuery == "" {
+		return r.RequestURI
+	}
+	q := r.URL.Query()
+	changed := false
+	for _, name := range sensitiveParams {
+		if q.Has(name) {
+			q.Set(name, "REDACTED")
+			changed = true
+		}
+	}
+	if !changed {
+		return r.RequestURI
+	}
+	u := *r.URL
+	u.RawQuery = q.Encode()
+	return u.RequestURI()
+}
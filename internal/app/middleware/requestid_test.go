@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/AlenaMolokova/http/internal/app/logging"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming string
+		keep     bool
+	}{
+		{"generated when missing", "", false},
+		{"taken from header", "client-supplied-id", true},
+		{"replaced when too long", strings.Repeat("x", maxRequestIDLength+1), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fromCtx string
+			handler := RequestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fromCtx = logging.RequestIDFromContext(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.incoming != "" {
+				req.Header.Set(RequestIDHeader, tt.incoming)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			got := w.Header().Get(RequestIDHeader)
+			if got == "" {
+				t.Fatal("Expected a request ID response header")
+			}
+			if got != fromCtx {
+				t.Errorf("Expected context request ID %q to match header %q", fromCtx, got)
+			}
+			if tt.keep && got != tt.incoming {
+				t.Errorf("Expected request ID %q, got %q", tt.incoming, got)
+			}
+			if !tt.keep && got == tt.incoming {
+				t.Errorf("Expected a generated request ID, got %q", got)
+			}
+		})
+	}
+}
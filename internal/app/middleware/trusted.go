@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// An empty or invalid CIDR denies every request.
+func TrustedSubnetMiddleware(cidr string) func(http.Handler) http.Handler {
+	var subnet *net.IPNet
+	if cidr != "" {
+		_, parsed, err := net.ParseCIDR(cidr)
+		if err != nil {
+			logrus.WithError(err).WithField("cidr", cidr).Error("Invalid trusted subnet, internal endpoints are disabled")
+		} else {
+			subnet = parsed
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := clientIP(r)
+			if subnet == nil || ip == nil || !subnet.Contains(ip) {
+				logrus.WithFields(logrus.Fields{
+					"ip":  ip,
+					"uri": RedactedURI(r),
+				}).Warn("Request from outside the trusted subnet")
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func clientIP(r *http.Request) net.IP {
+	if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); realIP != "" {
+		return net.ParseIP(realIP)
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTrustedSubnetMiddleware(t *testing.T) {
+	tests := []struct {
+		name       string
+		cidr       string
+		remoteAddr string
+		realIP     string
+		want       int
+	}{
+		{"remote addr in range", "10.0.0.0/8", "10.1.2.3:4567", "", http.StatusOK},
+		{"remote addr out of range", "10.0.0.0/8", "192.168.1.1:4567", "", http.StatusForbidden},
+		{"real IP in range", "192.168.0.0/16", "203.0.113.1:4567", "192.168.5.6", http.StatusOK},
+		{"real IP out of range", "192.168.0.0/16", "192.168.0.1:4567", "203.0.113.9", http.StatusForbidden},
+		{"invalid real IP", "0.0.0.0/0", "10.0.0.1:4567", "not-an-ip", http.StatusForbidden},
+		{"ipv6 in range", "::1/128", "[::1]:4567", "", http.StatusOK},
+		{"empty cidr denies all", "", "127.0.0.1:4567", "", http.StatusForbidden},
+		{"invalid cidr denies all", "10.0.0.0/99", "10.0.0.1:4567", "", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := TrustedSubnetMiddleware(tt.cidr)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/api/internal/export", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.realIP != "" {
+				req.Header.Set("X-Real-IP", tt.realIP)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("Expected %d, got %d", tt.want, w.Code)
+			}
+		})
+	}
+}
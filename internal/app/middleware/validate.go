@@ -0,0 +1,169 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/AlenaMolokova/http/internal/app/models"
+	"github.com/AlenaMolokova/http/internal/app/respond"
+	"github.com/sirupsen/logrus"
+)
+
+type FieldType string
+
+const (
+	TypeString FieldType = "string"
+	TypeNumber FieldType = "number"
+	TypeBool   FieldType = "boolean"
+	TypeArray  FieldType = "array"
+	TypeObject FieldType = "object"
+)
+
+type FieldRule struct {
+	Name     string
+	Type     FieldType
+	Required bool
+}
+
+type BodySchema struct {
+	Array  bool
+	Items  FieldType
+	Fields []FieldRule
+	// AnyOf lists alternative shapes; the body is checked against the one
+	// whose top level (array or object) matches it.
+	AnyOf []BodySchema
+}
+
+func ValidateJSON(schema BodySchema) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Body == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			r.Body.Close()
+			if IsBodyTooLarge(err) {
+				http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			if err != nil {
+				logrus.WithError(err).Error("Failed to read request body")
+				http.Error(w, "Failed to read request body", http.StatusBadRequest)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			decoder := json.NewDecoder(bytes.NewReader(body))
+			decoder.UseNumber()
+			var doc interface{}
+			if err := decoder.Decode(&doc); err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if errs := schema.validate(doc); len(errs) > 0 {
+				logrus.WithField("errors", errs).Warn("Request body failed validation")
+				writeValidationError(w, errs)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (s BodySchema) validate(doc interface{}) []models.FieldError {
+	if len(s.AnyOf) > 0 {
+		_, isArray := doc.([]interface{})
+		for _, alt := range s.AnyOf {
+			if alt.Array == isArray {
+				return alt.validate(doc)
+			}
+		}
+		return s.AnyOf[0].validate(doc)
+	}
+
+	if !s.Array {
+		return s.validateObject("", doc)
+	}
+
+	items, ok := doc.([]interface{})
+	if !ok {
+		return []models.FieldError{{Field: "$", Message: "expected array, got " + jsonType(doc)}}
+	}
+
+	var errs []models.FieldError
+	for i, item := range items {
+		path := fmt.Sprintf("[%d]", i)
+		if s.Items != "" {
+			if t := jsonType(item); t != string(s.Items) {
+				errs = append(errs, models.FieldError{Field: path, Message: fmt.Sprintf("expected %s, got %s", s.Items, t)})
+			}
+			continue
+		}
+		errs = append(errs, s.validateObject(path, item)...)
+	}
+	return errs
+}
+
+func (s BodySchema) validateObject(path string, doc interface{}) []models.FieldError {
+	obj, ok := doc.(map[string]interface{})
+	if !ok {
+		field := path
+		if field == "" {
+			field = "$"
+		}
+		return []models.FieldError{{Field: field, Message: "expected object, got " + jsonType(doc)}}
+	}
+
+	var errs []models.FieldError
+	for _, rule := range s.Fields {
+		field := rule.Name
+		if path != "" {
+			field = path + "." + rule.Name
+		}
+
+		value, present := obj[rule.Name]
+		if !present || value == nil {
+			if rule.Required {
+				errs = append(errs, models.FieldError{Field: field, Message: "field is required"})
+			}
+			continue
+		}
+		if t := jsonType(value); t != string(rule.Type) {
+			errs = append(errs, models.FieldError{Field: field, Message: fmt.Sprintf("expected %s, got %s", rule.Type, t)})
+		}
+	}
+	return errs
+}
+
+func jsonType(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return string(TypeString)
+	case json.Number, float64:
+		return string(TypeNumber)
+	case bool:
+		return string(TypeBool)
+	case []interface{}:
+		return string(TypeArray)
+	case map[string]interface{}:
+		return string(TypeObject)
+	}
+	return "unknown"
+}
+
+func writeValidationError(w http.ResponseWriter, errs []models.FieldError) {
+	respond.JSON(w, http.StatusUnprocessableEntity, models.APIError{
+		Code:    "validation_failed",
+		Message: "Request body failed validation",
+		Details: errs,
+	})
+}
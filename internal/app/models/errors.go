@@ -0,0 +1,71 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrSelfLink    = errors.New("URL points back at this service")
+	ErrInvalidTags = errors.New("invalid tags")
+
+	ErrDomainNotAllowed = errors.New("URL domain is not allowed")
+	ErrUnreachable      = errors.New("URL is not reachable")
+
+	ErrURLLimitExceeded = errors.New("URL limit per user exceeded")
+
+	ErrIdempotencyKeyMismatch = errors.New("idempotency key reused with a different request")
+
+	ErrBackupNotSupported = errors.New("storage does not support export and import")
+
+	ErrURLNotFound        = errors.New("short URL not found")
+	ErrURLNotOwned        = errors.New("short URL belongs to another user")
+	ErrUpdateNotSupported = errors.New("storage does not support updating URLs")
+	ErrTagsNotSupported   = errors.New("storage does not support tags")
+
+	ErrAliasTaken        = errors.New("alias is already taken")
+	ErrAliasNotSupported = errors.New("storage does not support custom aliases")
+
+	ErrShortIDTaken = errors.New("short ID is already taken")
+
+	// ErrNoDatabase is returned by Ping of storages that have no database
+	// to check; callers treat it as healthy.
+	ErrNoDatabase = errors.New("storage does not support database connection check")
+)
+
+// BatchError reports a SaveBatch that failed part way. Saved lists the short
+// IDs committed before the failure; the rest of the batch was rolled back.
+type BatchError struct {
+	Saved []string
+	Err   error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("batch failed after saving %d URLs: %v", len(e.Saved), e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// UnreachableError reports why a reachability check failed: either the
+// status the target answered with or the error that prevented an answer.
+type UnreachableError struct {
+	Status int
+	Err    error
+}
+
+func (e *UnreachableError) Error() string {
+	if e.Status != 0 {
+		return fmt.Sprintf("%v: status %d", ErrUnreachable, e.Status)
+	}
+	return fmt.Sprintf("%v: %v", ErrUnreachable, e.Err)
+}
+
+func (e *UnreachableError) Is(target error) bool {
+	return target == ErrUnreachable
+}
+
+func (e *UnreachableError) Unwrap() error {
+	return e.Err
+}
@@ -3,10 +3,14 @@ package models
 import (
 	"context"
 	"encoding/json"
+	"time"
 )
 
 type ShortenRequest struct {
-	URL string `json:"url"`
+	URL      string   `json:"url"`
+	Password string   `json:"password,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	Alias    string   `json:"alias,omitempty"`
 }
 
 type ShortenResponse struct {
@@ -18,16 +22,76 @@ type BatchShortenRequest struct {
 	OriginalURL   string `json:"original_url"`
 }
 
+type UpdateURLRequest struct {
+	URL string `json:"url"`
+}
+
+type SetDeletedRequest struct {
+	IsDeleted *bool `json:"is_deleted"`
+}
+
+type DeleteURLsRequest struct {
+	IDs    []string `json:"ids"`
+	Reason string   `json:"reason,omitempty"`
+}
+
 type BatchShortenResponse struct {
 	CorrelationID string `json:"correlation_id"`
 	ShortURL      string `json:"short_url"`
 }
 
 type UserURL struct {
-	ShortURL    string `json:"short_url"`
-	OriginalURL string `json:"original_url"`
-	UserID      string `json:"user_id"`
-	IsDeleted   bool   `json:"is_deleted,omitempty"`
+	ShortURL     string    `json:"short_url"`
+	OriginalURL  string    `json:"original_url"`
+	UserID       string    `json:"user_id"`
+	IsDeleted    bool      `json:"is_deleted,omitempty"`
+	PasswordHash string    `json:"password_hash,omitempty"`
+	Hits         int64     `json:"hits"`
+	CreatedAt    time.Time `json:"created_at"`
+	Tags         []string  `json:"tags,omitempty"`
+}
+
+type TokenResponse struct {
+	Token  string `json:"token"`
+	UserID string `json:"user_id"`
+}
+
+type HealthResponse struct {
+	Status        string `json:"status"`
+	StorageKind   string `json:"storage_kind"`
+	UptimeSeconds int64  `json:"uptime_seconds"`
+	PendingWrites int    `json:"pending_writes,omitempty"`
+}
+
+type AdminStats struct {
+	URLs    int `json:"urls"`
+	Deleted int `json:"deleted"`
+	Users   int `json:"users"`
+}
+
+type AdminRestoreRequest struct {
+	IDs []string `json:"ids"`
+}
+
+type AdminRestoreResponse struct {
+	Restored int `json:"restored"`
+}
+
+type AvailabilityResponse struct {
+	Available bool `json:"available"`
+}
+
+type Counters struct {
+	Shortened int64 `json:"shortened"`
+	Redirects int64 `json:"redirects"`
+	Gone      int64 `json:"gone"`
+	Deleted   int64 `json:"deleted"`
+}
+
+type ServerTimeResponse struct {
+	Time   time.Time `json:"time"`
+	UnixMs int64     `json:"unix_ms"`
+	Clock  string    `json:"clock"`
 }
 
 type URLWithUser struct {
@@ -36,6 +100,21 @@ type URLWithUser struct {
 	UserID      string
 }
 
+type APIError struct {
+	Code    string       `json:"code"`
+	Message string       `json:"message"`
+	Details []FieldError `json:"details,omitempty"`
+	// Saved lists the items a partially failed batch did store.
+	Saved []BatchShortenResponse `json:"saved,omitempty"`
+	// Result is the short URL a request created before it failed.
+	Result string `json:"result,omitempty"`
+}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
 type ShortenResult struct {
 	ShortURL string `json:"short_url"`
 	IsNew    bool   `json:"is_new"`
@@ -43,31 +122,195 @@ type ShortenResult struct {
 
 type URLShortener interface {
 	ShortenURL(ctx context.Context, originalURL, userID string) (ShortenResult, error)
+	ShortenURLWithBase(ctx context.Context, originalURL, userID, baseURL string) (ShortenResult, error)
+}
+
+type IdempotentResponse struct {
+	StatusCode  int
+	ContentType string
+	Location    string
+	Body        []byte
+}
+
+type IdempotencyStore interface {
+	LookupIdempotent(ctx context.Context, userID, key, fingerprint string) (IdempotentResponse, bool, error)
+	SaveIdempotent(ctx context.Context, userID, key, fingerprint string, resp IdempotentResponse)
 }
 
 type BatchURLShortener interface {
 	ShortenBatch(ctx context.Context, items []BatchShortenRequest, userID string) ([]BatchShortenResponse, error)
+	ShortenBatchWithBase(ctx context.Context, items []BatchShortenRequest, userID, baseURL string) ([]BatchShortenResponse, error)
 }
 
 type URLGetter interface {
 	Get(ctx context.Context, shortID string) (string, bool)
+	GetBatch(ctx context.Context, shortIDs []string) (map[string]string, error)
 }
 
 type URLFetcher interface {
 	GetURLsByUserID(ctx context.Context, userID string) ([]UserURL, error)
 }
 
+// UserURLStreamer passes the URLs of a user to fn one at a time as they are
+// read, so large listings are never held in memory. An error from fn stops
+// the walk and is returned.
+type UserURLStreamer interface {
+	GetURLsByUserIDStream(ctx context.Context, userID string, fn func(UserURL) error) error
+}
+
 type URLDeleter interface {
 	DeleteURLs(ctx context.Context, shortIDs []string, userID string) error
+	RestoreURLs(ctx context.Context, shortIDs []string, userID string) error
+}
+
+type URLLookuper interface {
+	LookupURL(ctx context.Context, originalURL, userID string) (string, bool, error)
+}
+
+type AliasClaimer interface {
+	ClaimAlias(ctx context.Context, alias, originalURL, userID string) (bool, error)
+}
+
+type AliasShortener interface {
+	ShortenWithAlias(ctx context.Context, originalURL, userID, baseURL, alias string) (ShortenResult, error)
+}
+
+type URLCounter interface {
+	CountByUserID(ctx context.Context, userID string) (int, error)
+}
+
+type URLPurger interface {
+	PurgeURLs(ctx context.Context, shortIDs []string, userID string) error
+}
+
+// OwnerLookup returns the user owning a short ID, deleted or not, or
+// ErrURLNotFound when it does not exist.
+type OwnerLookup interface {
+	OwnerOf(ctx context.Context, shortID string) (string, error)
+}
+
+type DeletionToggler interface {
+	SetDeleted(ctx context.Context, shortID string, deleted bool, userID string) error
+}
+
+// URLTagger attaches tags to a short ID owned by userID, replacing any it had.
+type URLTagger interface {
+	SetTags(ctx context.Context, shortID string, tags []string, userID string) error
+}
+
+// TagValidator rejects tag lists that break the configured limits with
+// ErrInvalidTags.
+type TagValidator interface {
+	ValidateTags(tags []string) error
+}
+
+type TaggedURLFetcher interface {
+	GetURLsByTag(ctx context.Context, userID, tag string) ([]UserURL, error)
+}
+
+type URLUpdater interface {
+	UpdateURL(ctx context.Context, shortID, newURL, userID string) error
+}
+
+type BackupStore interface {
+	Export(ctx context.Context) ([]UserURL, error)
+	Import(ctx context.Context, urls []UserURL) error
+}
+
+type AdminStatsReader interface {
+	Stats(ctx context.Context) (AdminStats, error)
+}
+
+type AdminExporter interface {
+	Export(ctx context.Context) ([]UserURL, error)
+}
+
+type AdminImporter interface {
+	Import(ctx context.Context, urls []UserURL) error
+}
+
+type AdminRestorer interface {
+	Restore(ctx context.Context, shortIDs []string) (int, error)
+}
+
+type Admin interface {
+	AdminStatsReader
+	AdminExporter
+	AdminImporter
+	AdminRestorer
 }
 
 type Pinger interface {
 	Ping(ctx context.Context) error
 }
 
+type Flusher interface {
+	Flush(ctx context.Context) error
+}
+
+type WriteBacklog interface {
+	PendingWrites() int
+	LastSaveError() error
+}
+
 type URLSaver interface {
 	Save(ctx context.Context, shortID, originalURL, userID string) error
-	FindByOriginalURL(ctx context.Context, originalURL string) (string, error)
+	// FindByOriginalURL returns a live, unprotected short ID of tenantName
+	// for originalURL, or an empty string.
+	FindByOriginalURL(ctx context.Context, originalURL, tenantName string) (string, error)
+}
+
+// ProtectedURLSaver stores password-protected URLs. SaveWithPassword never
+// overwrites: a short ID in use yields ErrShortIDTaken.
+type ProtectedURLSaver interface {
+	SaveWithPassword(ctx context.Context, shortID, originalURL, userID, passwordHash string) error
+	GetPasswordHash(ctx context.Context, shortID string) (string, error)
+}
+
+type ProtectedURLShortener interface {
+	ShortenProtectedURL(ctx context.Context, originalURL, userID, baseURL, password string) (ShortenResult, error)
+}
+
+// DeletionChecker reports whether a short ID exists but was soft-deleted.
+type DeletionChecker interface {
+	IsDeleted(ctx context.Context, shortID string) (bool, error)
+}
+
+// RecentURLLister lists up to limit active URLs, newest first, so a cache
+// can be warmed on startup.
+type RecentURLLister interface {
+	RecentURLs(ctx context.Context, limit int) ([]UserURL, error)
+}
+
+type CounterReader interface {
+	Counters() Counters
+}
+
+// GoneRecorder is told about redirects answered with 410 Gone.
+type GoneRecorder interface {
+	RecordGone(ctx context.Context, shortID string)
+}
+
+type HitRecorder interface {
+	RecordHit(ctx context.Context, shortID string) error
+}
+
+type TimeSource interface {
+	Now() time.Time
+	ClockSource() string
+}
+
+// ShortIDChecker reports whether a short ID is stored, soft-deleted or not.
+type ShortIDChecker interface {
+	ShortIDExists(ctx context.Context, shortID string) (bool, error)
+}
+
+type PasswordChecker interface {
+	CheckPassword(ctx context.Context, shortID, password string) (bool, error)
+}
+
+type ReachabilityChecker interface {
+	Check(ctx context.Context, rawURL string) error
 }
 
 type URLBatchSaver interface {
@@ -84,11 +327,17 @@ func (r ShortenResponse) MarshalJSON() ([]byte, error) {
 
 func (r *ShortenRequest) UnmarshalJSON(data []byte) error {
 	var req struct {
-		URL string `json:"url"`
+		URL      string   `json:"url"`
+		Password string   `json:"password"`
+		Tags     []string `json:"tags"`
+		Alias    string   `json:"alias"`
 	}
 	if err := json.Unmarshal(data, &req); err != nil {
 		return err
 	}
 	r.URL = req.URL
+	r.Password = req.Password
+	r.Tags = req.Tags
+	r.Alias = req.Alias
 	return nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,61 @@
+package reachability
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/AlenaMolokova/http/internal/app/models"
+	"github.com/AlenaMolokova/http/internal/app/safehttp"
+)
+
+const DefaultTimeout = 5 * time.Second
+
+// Checker verifies that a URL answers a HEAD request with a 2xx or 3xx
+// status. Connections go through a safehttp.Dialer so the check cannot be
+// used to probe internal services.
+type Checker struct {
+	client *http.Client
+}
+
+// NewChecker builds a Checker; a nil dialer uses the default blocklist.
+func NewChecker(timeout time.Duration, dialer *safehttp.Dialer) *Checker {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	if dialer == nil {
+		dialer = safehttp.NewDialer()
+	}
+	client := dialer.Client(timeout)
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	return &Checker{client: client}
+}
+
+func (c *Checker) Check(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return &models.UnreachableError{Err: err}
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return &models.UnreachableError{Err: fmt.Errorf("unsupported scheme %q", u.Scheme)}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, rawURL, nil)
+	if err != nil {
+		return &models.UnreachableError{Err: err}
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return &models.UnreachableError{Err: err}
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return &models.UnreachableError{Status: resp.StatusCode}
+	}
+	return nil
+}
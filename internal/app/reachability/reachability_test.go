@@ -0,0 +1,78 @@
+package reachability
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/AlenaMolokova/http/internal/app/models"
+	"github.com/AlenaMolokova/http/internal/app/safehttp"
+)
+
+func TestCheckerReachableTargets(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			t.Errorf("Expected HEAD, got %s", r.Method)
+		}
+		switch r.URL.Path {
+		case "/ok":
+			w.WriteHeader(http.StatusOK)
+		case "/moved":
+			http.Redirect(w, r, "/ok", http.StatusFound)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	checker := NewChecker(0, safehttp.NewDialer(safehttp.WithBlockedNetworks()))
+
+	for _, path := range []string{"/ok", "/moved"} {
+		if err := checker.Check(context.Background(), server.URL+path); err != nil {
+			t.Errorf("Expected %s to be reachable, got %v", path, err)
+		}
+	}
+
+	err := checker.Check(context.Background(), server.URL+"/missing")
+	var unreachable *models.UnreachableError
+	if !errors.As(err, &unreachable) || unreachable.Status != http.StatusNotFound {
+		t.Fatalf("Expected unreachable error with status 404, got %v", err)
+	}
+	if !errors.Is(err, models.ErrUnreachable) {
+		t.Errorf("Expected error to match ErrUnreachable, got %v", err)
+	}
+}
+
+func TestCheckerUnreachableHost(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	target := server.URL
+	server.Close()
+
+	checker := NewChecker(0, safehttp.NewDialer(safehttp.WithBlockedNetworks()))
+	if err := checker.Check(context.Background(), target); !errors.Is(err, models.ErrUnreachable) {
+		t.Errorf("Expected ErrUnreachable for a closed server, got %v", err)
+	}
+}
+
+func TestCheckerRefusesPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Private address must not be contacted")
+	}))
+	defer server.Close()
+
+	checker := NewChecker(0, nil)
+	for _, target := range []string{
+		server.URL,
+		"http://10.1.2.3/",
+		"http://192.168.0.1/",
+		"http://169.254.169.254/latest/meta-data",
+		"http://[::1]/",
+	} {
+		err := checker.Check(context.Background(), target)
+		if !errors.Is(err, safehttp.ErrBlockedAddress) || !errors.Is(err, models.ErrUnreachable) {
+			t.Errorf("Expected %s to be refused as private, got %v", target, err)
+		}
+	}
+}
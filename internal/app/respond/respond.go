@@ -0,0 +1,38 @@
+// Package respond writes HTTP responses with their headers in place before
+// the status line, after which header changes are silently dropped.
+package respond
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+)
+
+// JSON writes v as an application/json response with status.
+func JSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logrus.WithError(err).Error("Failed to encode response")
+	}
+}
+
+// Text writes s as a text/plain response with status.
+func Text(w http.ResponseWriter, status int, s string) {
+	write(w, status, "text/plain", s)
+}
+
+// HTML writes s as a text/html response with status.
+func HTML(w http.ResponseWriter, status int, s string) {
+	write(w, status, "text/html; charset=utf-8", s)
+}
+
+func write(w http.ResponseWriter, status int, contentType, s string) {
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	if _, err := io.WriteString(w, s); err != nil {
+		logrus.WithError(err).Error("Failed to write response")
+	}
+}
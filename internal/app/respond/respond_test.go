@@ -0,0 +1,40 @@
+package respond
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// The recorder snapshots headers when the status is written, so a header set
+// afterwards would be missing from Result just as it would on the wire.
+func TestHeadersAreSetBeforeStatus(t *testing.T) {
+	tests := []struct {
+		name        string
+		write       func(w http.ResponseWriter)
+		status      int
+		contentType string
+		body        string
+	}{
+		{"json", func(w http.ResponseWriter) { JSON(w, http.StatusCreated, map[string]string{"a": "b"}) }, http.StatusCreated, "application/json", "{\"a\":\"b\"}\n"},
+		{"text", func(w http.ResponseWriter) { Text(w, http.StatusConflict, "hello") }, http.StatusConflict, "text/plain", "hello"},
+		{"html", func(w http.ResponseWriter) { HTML(w, http.StatusUnauthorized, "<p>") }, http.StatusUnauthorized, "text/html; charset=utf-8", "<p>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			tt.write(rec)
+
+			res := rec.Result()
+			if res.StatusCode != tt.status {
+				t.Errorf("Expected status %d, got %d", tt.status, res.StatusCode)
+			}
+			if ct := res.Header.Get("Content-Type"); ct != tt.contentType {
+				t.Errorf("Expected Content-Type %q, got %q", tt.contentType, ct)
+			}
+			if rec.Body.String() != tt.body {
+				t.Errorf("Expected body %q, got %q", tt.body, rec.Body.String())
+			}
+		})
+	}
+}
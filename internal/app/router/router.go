@@ -2,52 +2,210 @@ package router
 
 import (
 	"net/http"
+	"strings"
+	"time"
 
+	"github.com/AlenaMolokova/http/internal/app/auth"
 	"github.com/AlenaMolokova/http/internal/app/handler"
 	"github.com/AlenaMolokova/http/internal/app/middleware"
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
 )
 
+var (
+	shortenSchema = middleware.BodySchema{
+		Fields: []middleware.FieldRule{
+			{Name: "url", Type: middleware.TypeString, Required: true},
+			{Name: "password", Type: middleware.TypeString},
+			{Name: "tags", Type: middleware.TypeArray},
+			{Name: "alias", Type: middleware.TypeString},
+		},
+	}
+
+	batchShortenSchema = middleware.BodySchema{
+		Array: true,
+		Fields: []middleware.FieldRule{
+			{Name: "correlation_id", Type: middleware.TypeString, Required: true},
+			{Name: "original_url", Type: middleware.TypeString, Required: true},
+		},
+	}
+
+	updateURLSchema = middleware.BodySchema{
+		Fields: []middleware.FieldRule{
+			{Name: "url", Type: middleware.TypeString, Required: true},
+		},
+	}
+
+	setDeletedSchema = middleware.BodySchema{
+		Fields: []middleware.FieldRule{
+			{Name: "is_deleted", Type: middleware.TypeBool, Required: true},
+		},
+	}
+
+	deleteURLsSchema = middleware.BodySchema{
+		AnyOf: []middleware.BodySchema{
+			{Array: true, Items: middleware.TypeString},
+			{Fields: []middleware.FieldRule{
+				{Name: "ids", Type: middleware.TypeArray, Required: true},
+				{Name: "reason", Type: middleware.TypeString},
+			}},
+		},
+	}
+)
+
+const (
+	DefaultMaxBodyBytes      = 1 << 20
+	DefaultMaxBatchBodyBytes = 10 << 20
+)
+
 type Router struct {
 	handler *handler.URLHandler 
+	admin   *handler.AdminHandler
+
+	maxBodyBytes      int64
+	maxBatchBodyBytes int64
+	basePath          string
+	trustedSubnet     string
+	requestTimeout    time.Duration
+	slowRequest       time.Duration
+	maxConcurrent     int
+}
+
+type Option func(*Router)
+
+func WithMaxBodyBytes(single, batch int64) Option {
+	return func(r *Router) {
+		r.maxBodyBytes = single
+		r.maxBatchBodyBytes = batch
+	}
+}
+
+func WithBasePath(path string) Option {
+	return func(r *Router) {
+		r.basePath = strings.TrimSuffix(path, "/")
+	}
 }
 
-func NewRouter(handler *handler.URLHandler) *Router {
-	return &Router{
-		handler: handler,
+func WithTrustedSubnet(cidr string) Option {
+	return func(r *Router) {
+		r.trustedSubnet = cidr
 	}
 }
 
+func WithRequestTimeout(d time.Duration) Option {
+	return func(r *Router) {
+		r.requestTimeout = d
+	}
+}
+
+// WithSlowRequestThreshold logs requests slower than d at warn level in
+// addition to the usual access log entry.
+func WithSlowRequestThreshold(d time.Duration) Option {
+	return func(r *Router) {
+		r.slowRequest = d
+	}
+}
+
+// WithMaxConcurrentRequests answers requests beyond n in flight with 503.
+func WithMaxConcurrentRequests(n int) Option {
+	return func(r *Router) {
+		r.maxConcurrent = n
+	}
+}
+
+// WithAdmin mounts the internal admin endpoints. Without it they are not
+// routed at all.
+func WithAdmin(admin *handler.AdminHandler) Option {
+	return func(r *Router) {
+		r.admin = admin
+	}
+}
+
+func NewRouter(handler *handler.URLHandler, opts ...Option) *Router {
+	r := &Router{
+		handler:           handler,
+		maxBodyBytes:      DefaultMaxBodyBytes,
+		maxBatchBodyBytes: DefaultMaxBatchBodyBytes,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
 func (r *Router) InitRoutes() *mux.Router {
-	router := mux.NewRouter()
+	root := mux.NewRouter()
+	router := root
+	if r.basePath != "" {
+		router = root.PathPrefix(r.basePath).Subrouter()
+	}
+
+	// Decoded bodies are capped at the largest per-route limit; each route's
+	// own limit still applies on top. Any disabled limit disables the cap.
+	decompressLimit := r.maxBodyBytes
+	if r.maxBatchBodyBytes <= 0 || decompressLimit <= 0 {
+		decompressLimit = 0
+	} else if r.maxBatchBodyBytes > decompressLimit {
+		decompressLimit = r.maxBatchBodyBytes
+	}
 
-	router.Use(middleware.GzipMiddleware)
-	router.Use(middleware.LoggingMiddleware)
+	router.Use(mux.MiddlewareFunc(middleware.Chain(
+		middleware.LimitedCompressMiddleware(decompressLimit, middleware.DefaultCompressors...),
+		middleware.SlowLoggingMiddleware(r.slowRequest),
+		middleware.RecoveryMiddleware,
+		middleware.ConcurrencyLimitMiddleware(r.maxConcurrent),
+		middleware.RequestIDMiddleware,
+		middleware.TimeoutMiddleware(r.requestTimeout),
+		auth.AuthMiddleware,
+	)))
 
-	router.HandleFunc("/", r.handler.HandleShortenURL).Methods(http.MethodPost)
-	router.HandleFunc("/api/shorten", r.handler.HandleShortenURLJSON).Methods(http.MethodPost)
-	router.HandleFunc("/api/shorten/batch", r.handler.HandleBatchShortenURL).Methods(http.MethodPost)
+	limit := middleware.MaxBodyBytes(r.maxBodyBytes)
+	batchLimit := middleware.MaxBodyBytes(r.maxBatchBodyBytes)
+	trusted := middleware.TrustedSubnetMiddleware(r.trustedSubnet)
+	requireJSON := middleware.RequireContentType("application/json")
+
+	router.Handle("/", limit(http.HandlerFunc(r.handler.HandleShortenURL))).Methods(http.MethodPost)
+	router.Handle("/api/shorten", middleware.Chain(limit, requireJSON, middleware.ValidateJSON(shortenSchema))(http.HandlerFunc(r.handler.HandleShortenURLJSON))).Methods(http.MethodPost)
+	router.Handle("/api/shorten/batch", middleware.Chain(batchLimit, requireJSON, middleware.ValidateJSON(batchShortenSchema))(http.HandlerFunc(r.handler.HandleBatchShortenURL))).Methods(http.MethodPost)
+	router.Handle("/api/shorten/batch/text", batchLimit(http.HandlerFunc(r.handler.HandleBatchShortenText))).Methods(http.MethodPost)
+	router.HandleFunc("/api/auth/token", r.handler.HandleIssueToken).Methods(http.MethodPost)
+	router.HandleFunc("/api/lookup", r.handler.HandleLookup).Methods(http.MethodGet)
+	router.HandleFunc("/api/available", r.handler.HandleAvailable).Methods(http.MethodGet)
+	router.Handle("/api/resolve", batchLimit(http.HandlerFunc(r.handler.HandleResolve))).Methods(http.MethodPost)
 	router.HandleFunc("/api/user/urls", r.handler.HandleGetUserURLs).Methods(http.MethodGet)
-	router.HandleFunc("/api/user/urls", r.handler.HandleDeleteURLs).Methods(http.MethodDelete)
+	router.Handle("/api/user/urls", middleware.Chain(batchLimit, middleware.ValidateJSON(deleteURLsSchema))(http.HandlerFunc(r.handler.HandleDeleteURLs))).Methods(http.MethodDelete)
+	router.Handle("/api/user/urls/{id}", middleware.Chain(limit, middleware.ValidateJSON(updateURLSchema))(http.HandlerFunc(r.handler.HandleUpdateURL))).Methods(http.MethodPut)
+	router.Handle("/api/user/urls/{id}", middleware.Chain(limit, middleware.ValidateJSON(setDeletedSchema))(http.HandlerFunc(r.handler.HandleSetDeleted))).Methods(http.MethodPatch)
+	router.Handle("/api/user/urls/restore", batchLimit(http.HandlerFunc(r.handler.HandleRestoreURLs))).Methods(http.MethodPost)
+	router.HandleFunc("/api/internal/time", r.handler.HandleServerTime).Methods(http.MethodGet)
+	router.Handle("/api/internal/counters", trusted(http.HandlerFunc(r.handler.HandleCounters))).Methods(http.MethodGet)
+	if r.admin != nil {
+		router.Handle("/api/internal/stats", trusted(http.HandlerFunc(r.admin.HandleStats))).Methods(http.MethodGet)
+		router.Handle("/api/internal/export", trusted(http.HandlerFunc(r.admin.HandleExport))).Methods(http.MethodGet)
+		router.Handle("/api/internal/import", middleware.Chain(trusted, batchLimit)(http.HandlerFunc(r.admin.HandleImport))).Methods(http.MethodPost)
+		router.Handle("/api/internal/restore", middleware.Chain(trusted, batchLimit)(http.HandlerFunc(r.admin.HandleRestore))).Methods(http.MethodPost)
+	}
 	router.HandleFunc("/ping", r.handler.HandlePing).Methods(http.MethodGet)
-	router.HandleFunc("/{id}", r.handler.HandleRedirect).Methods(http.MethodGet)
+	router.HandleFunc("/healthz", r.handler.HandleHealthz).Methods(http.MethodGet)
+	router.HandleFunc("/livez", r.handler.HandleLivez).Methods(http.MethodGet)
+	router.HandleFunc("/{id}", r.handler.HandleRedirect).Methods(http.MethodGet, http.MethodPost)
+	router.HandleFunc("/{id}/", r.handler.HandleRedirect).Methods(http.MethodGet, http.MethodPost)
 
-	router.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	root.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		logrus.WithFields(logrus.Fields{
-			"uri":    r.RequestURI,
+			"uri":    middleware.RedactedURI(r),
 			"method": r.Method,
 		}).Info("Route not found")
 		http.Error(w, "Not Found", http.StatusBadRequest)
 	})
 
-	router.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	root.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		logrus.WithFields(logrus.Fields{
-			"uri":    r.RequestURI,
+			"uri":    middleware.RedactedURI(r),
 			"method": r.Method,
 		}).Info("Method not allowed")
 		http.Error(w, "Method not allowed", http.StatusBadRequest)
 	})
 
-	return router
+	return root
 }
\ No newline at end of file
@@ -0,0 +1,552 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AlenaMolokova/http/internal/app/auth"
+	"github.com/AlenaMolokova/http/internal/app/generator"
+	"github.com/AlenaMolokova/http/internal/app/handler"
+	"github.com/AlenaMolokova/http/internal/app/models"
+	"github.com/AlenaMolokova/http/internal/app/service"
+	"github.com/AlenaMolokova/http/internal/app/storage"
+	"github.com/sirupsen/logrus"
+	logrustest "github.com/sirupsen/logrus/hooks/test"
+)
+
+const testBaseURL = "http://localhost:8080"
+
+func newTestRouter(t *testing.T, opts ...Option) http.Handler {
+	t.Helper()
+	urlStorage, err := storage.NewStorage("", "")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	serviceImpl := service.NewService(
+		urlStorage.AsURLSaver(),
+		urlStorage.AsURLBatchSaver(),
+		urlStorage.AsURLGetter(),
+		urlStorage.AsURLFetcher(),
+		urlStorage.AsURLDeleter(),
+		urlStorage.AsPinger(),
+		generator.NewGenerator(8),
+		testBaseURL,
+	)
+	h := handler.NewURLHandler(serviceImpl, serviceImpl, serviceImpl, serviceImpl, serviceImpl, serviceImpl, testBaseURL)
+	admin := handler.NewAdminHandler(service.NewAdminService(serviceImpl, serviceImpl))
+	return NewRouter(h, append([]Option{WithAdmin(admin)}, opts...)...).InitRoutes()
+}
+
+func TestValidationRejectsStructurallyInvalidBodies(t *testing.T) {
+	r := newTestRouter(t)
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		fields []string
+	}{
+		{"shorten missing url", http.MethodPost, "/api/shorten", `{"link":"https://example.com"}`, []string{"url"}},
+		{"shorten url wrong type", http.MethodPost, "/api/shorten", `{"url":42}`, []string{"url"}},
+		{"shorten not an object", http.MethodPost, "/api/shorten", `["https://example.com"]`, []string{"$"}},
+		{"batch not an array", http.MethodPost, "/api/shorten/batch", `{"original_url":"https://example.com"}`, []string{"$"}},
+		{"batch item fields", http.MethodPost, "/api/shorten/batch", `[{"correlation_id":"1","original_url":"https://a.example"},{"correlation_id":2}]`, []string{"[1].correlation_id", "[1].original_url"}},
+		{"delete non-string ids", http.MethodDelete, "/api/user/urls", `["abc", 1, null]`, []string{"[1]", "[2]"}},
+		{"delete object without ids", http.MethodDelete, "/api/user/urls", `{"reason":"spam"}`, []string{"ids"}},
+		{"delete object reason wrong type", http.MethodDelete, "/api/user/urls", `{"ids":["abc"],"reason":1}`, []string{"reason"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			if w.Code != http.StatusUnprocessableEntity {
+				t.Fatalf("Expected 422, got %d: %s", w.Code, w.Body.String())
+			}
+			var apiErr models.APIError
+			if err := json.NewDecoder(w.Body).Decode(&apiErr); err != nil {
+				t.Fatalf("Failed to decode error: %v", err)
+			}
+			if apiErr.Code != "validation_failed" {
+				t.Errorf("Expected validation_failed, got %q", apiErr.Code)
+			}
+			if len(apiErr.Details) != len(tt.fields) {
+				t.Fatalf("Expected %d field errors, got %+v", len(tt.fields), apiErr.Details)
+			}
+			for i, field := range tt.fields {
+				if apiErr.Details[i].Field != field {
+					t.Errorf("Expected error for %s, got %s", field, apiErr.Details[i].Field)
+				}
+			}
+		})
+	}
+}
+
+func TestValidationPassesValidBodies(t *testing.T) {
+	r := newTestRouter(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(`{"url":"https://example.com"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Errorf("Expected 201, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/shorten/batch", strings.NewReader(`[{"correlation_id":"1","original_url":"https://a.example"}]`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Errorf("Expected 201, got %d", w.Code)
+	}
+}
+
+func TestAuthMiddlewareSetsCookiesOnce(t *testing.T) {
+	r := newTestRouter(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(`{"url":"https://example.com"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d", w.Code)
+	}
+
+	cookies := w.Result().Cookies()
+	if len(cookies) == 0 {
+		t.Fatal("Expected a new user to receive cookies")
+	}
+	seen := make(map[string]int)
+	for _, c := range cookies {
+		seen[c.Name]++
+	}
+	for name, n := range seen {
+		if n != 1 {
+			t.Errorf("Expected cookie %s to be set once, got %d", name, n)
+		}
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/user/urls", nil)
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 for the same user, got %d", w.Code)
+	}
+	if got := w.Result().Cookies(); len(got) != 0 {
+		t.Errorf("Expected no Set-Cookie for an authenticated user, got %d", len(got))
+	}
+}
+
+func TestDeleteRequiresExistingUserBehindAuthMiddleware(t *testing.T) {
+	r := newTestRouter(t)
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/user/urls", strings.NewReader(`["abc"]`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for a user created by this request, got %d", w.Code)
+	}
+}
+
+func TestAccessLogIncludesUserAndShortID(t *testing.T) {
+	r := newTestRouter(t)
+	hook := logrustest.NewGlobal()
+	defer hook.Reset()
+
+	rec := httptest.NewRecorder()
+	auth.SetUserIDCookie(rec, "logged-user")
+	cookies := rec.Result().Cookies()
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("https://logged.example.com"))
+	req.Header.Set("Content-Type", "text/plain")
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d", w.Code)
+	}
+	shortID := strings.TrimPrefix(w.Body.String(), testBaseURL+"/")
+
+	var entry *logrus.Entry
+	for _, e := range hook.AllEntries() {
+		if e.Message == "Request processed" {
+			entry = e
+		}
+	}
+	if entry == nil {
+		t.Fatal("Expected an access log entry")
+	}
+	if got := entry.Data["user_id"]; got != "logged-user" {
+		t.Errorf("Expected user_id=logged-user, got %v", got)
+	}
+	if got := entry.Data["short_id"]; got != shortID {
+		t.Errorf("Expected short_id=%s, got %v", shortID, got)
+	}
+}
+
+func TestOversizedBodiesAreRejected(t *testing.T) {
+	r := newTestRouter(t, WithMaxBodyBytes(64, 256))
+	longURL := "https://example.com/" + strings.Repeat("a", 100)
+
+	tests := []struct {
+		name    string
+		target  string
+		body    string
+		chunked bool
+		want    int
+	}{
+		{"text with content length", "/", longURL, false, http.StatusRequestEntityTooLarge},
+		{"text chunked", "/", longURL, true, http.StatusRequestEntityTooLarge},
+		{"json", "/api/shorten", `{"url":"` + longURL + `"}`, false, http.StatusRequestEntityTooLarge},
+		{"json chunked", "/api/shorten", `{"url":"` + longURL + `"}`, true, http.StatusRequestEntityTooLarge},
+		{"batch within batch limit", "/api/shorten/batch", `[{"correlation_id":"1","original_url":"` + longURL + `"}]`, false, http.StatusCreated},
+		{"batch over batch limit", "/api/shorten/batch", `[{"correlation_id":"1","original_url":"` + strings.Repeat(longURL, 3) + `"}]`, true, http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body io.Reader = strings.NewReader(tt.body)
+			if tt.chunked {
+				body = io.MultiReader(body)
+			}
+			req := httptest.NewRequest(http.MethodPost, tt.target, body)
+			if tt.target != "/" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			if tt.chunked {
+				req.ContentLength = -1
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Errorf("Expected %d, got %d: %s", tt.want, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestJSONEndpointsRequireJSONContentType(t *testing.T) {
+	r := newTestRouter(t)
+
+	tests := []struct {
+		name        string
+		target      string
+		body        string
+		contentType string
+		want        int
+	}{
+		{"shorten json", "/api/shorten", `{"url":"https://ct.example/1"}`, "application/json", http.StatusCreated},
+		{"shorten json with charset", "/api/shorten", `{"url":"https://ct.example/2"}`, "application/json; charset=utf-8", http.StatusCreated},
+		{"shorten text plain", "/api/shorten", `{"url":"https://ct.example/3"}`, "text/plain", http.StatusUnsupportedMediaType},
+		{"shorten form", "/api/shorten", "url=https://ct.example/4", "application/x-www-form-urlencoded", http.StatusUnsupportedMediaType},
+		{"shorten missing type", "/api/shorten", `{"url":"https://ct.example/5"}`, "", http.StatusUnsupportedMediaType},
+		{"batch json", "/api/shorten/batch", `[{"correlation_id":"1","original_url":"https://ct.example/6"}]`, "Application/JSON", http.StatusCreated},
+		{"batch text plain", "/api/shorten/batch", `[{"correlation_id":"1","original_url":"https://ct.example/7"}]`, "text/plain", http.StatusUnsupportedMediaType},
+		{"batch missing type", "/api/shorten/batch", `[{"correlation_id":"1","original_url":"https://ct.example/8"}]`, "", http.StatusUnsupportedMediaType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.target, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Errorf("Expected %d, got %d: %s", tt.want, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestRoutesUnderBasePath(t *testing.T) {
+	const basePath = "/shortener"
+	urlStorage, err := storage.NewStorage("", "")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	serviceImpl := service.NewService(
+		urlStorage.AsURLSaver(),
+		urlStorage.AsURLBatchSaver(),
+		urlStorage.AsURLGetter(),
+		urlStorage.AsURLFetcher(),
+		urlStorage.AsURLDeleter(),
+		urlStorage.AsPinger(),
+		generator.NewGenerator(8),
+		testBaseURL+basePath,
+	)
+	h := handler.NewURLHandler(serviceImpl, serviceImpl, serviceImpl, serviceImpl, serviceImpl, serviceImpl, testBaseURL+basePath)
+	r := NewRouter(h, WithBasePath(basePath+"/")).InitRoutes()
+
+	req := httptest.NewRequest(http.MethodPost, basePath+"/api/shorten", strings.NewReader(`{"url":"https://mounted.example.com"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp models.ShortenResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !strings.HasPrefix(resp.Result, testBaseURL+basePath+"/") {
+		t.Fatalf("Expected short URL under the base path, got %q", resp.Result)
+	}
+
+	path := strings.TrimPrefix(resp.Result, testBaseURL)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	if w.Code != http.StatusTemporaryRedirect {
+		t.Fatalf("Expected 307 for %s, got %d", path, w.Code)
+	}
+	if location := w.Header().Get("Location"); location != "https://mounted.example.com" {
+		t.Errorf("Unexpected Location %q", location)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, strings.TrimPrefix(path, basePath), nil))
+	if w.Code == http.StatusTemporaryRedirect {
+		t.Error("Expected routes outside the base path not to redirect")
+	}
+}
+
+func TestRedirectTrailingSlashAndCase(t *testing.T) {
+	tests := []struct {
+		name            string
+		caseInsensitive bool
+		path            string
+		status          int
+	}{
+		{"exact", false, "/abc123", http.StatusTemporaryRedirect},
+		{"trailing slash", false, "/abc123/", http.StatusTemporaryRedirect},
+		{"double trailing slash", false, "/abc123//", http.StatusMovedPermanently},
+		{"case variant when sensitive", false, "/ABC123", http.StatusGone},
+		{"case variant when insensitive", true, "/ABC123", http.StatusTemporaryRedirect},
+		{"case variant with trailing slash", true, "/Abc123/", http.StatusTemporaryRedirect},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			urlStorage, err := storage.NewStorage("", "")
+			if err != nil {
+				t.Fatalf("Failed to create storage: %v", err)
+			}
+			if err := urlStorage.AsURLSaver().Save(context.Background(), "abc123", "https://example.com", "user"); err != nil {
+				t.Fatalf("Save failed: %v", err)
+			}
+			serviceImpl := service.NewService(
+				urlStorage.AsURLSaver(),
+				urlStorage.AsURLBatchSaver(),
+				urlStorage.AsURLGetter(),
+				urlStorage.AsURLFetcher(),
+				urlStorage.AsURLDeleter(),
+				urlStorage.AsPinger(),
+				generator.NewGenerator(8),
+				testBaseURL,
+			)
+			h := handler.NewURLHandler(serviceImpl, serviceImpl, serviceImpl, serviceImpl, serviceImpl, serviceImpl, testBaseURL,
+				handler.WithCaseInsensitiveIDs(tt.caseInsensitive))
+			r := NewRouter(h).InitRoutes()
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if w.Code != tt.status {
+				t.Fatalf("Expected %d for %s, got %d", tt.status, tt.path, w.Code)
+			}
+			if tt.status == http.StatusTemporaryRedirect {
+				if location := w.Header().Get("Location"); location != "https://example.com" {
+					t.Errorf("Unexpected Location %q", location)
+				}
+			}
+		})
+	}
+}
+
+func TestInternalEndpointsRequireTrustedSubnet(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   []Option
+		realIP string
+		want   int
+	}{
+		{"no subnet configured", nil, "127.0.0.1", http.StatusForbidden},
+		{"outside subnet", []Option{WithTrustedSubnet("10.0.0.0/8")}, "192.168.0.1", http.StatusForbidden},
+		{"inside subnet", []Option{WithTrustedSubnet("10.0.0.0/8")}, "10.20.30.40", http.StatusOK},
+		{"admin disabled", []Option{WithTrustedSubnet("10.0.0.0/8"), WithAdmin(nil)}, "10.20.30.40", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRouter(t, tt.opts...)
+			req := httptest.NewRequest(http.MethodGet, "/api/internal/export", nil)
+			req.Header.Set("X-Real-IP", tt.realIP)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("Expected %d, got %d", tt.want, w.Code)
+			}
+		})
+	}
+}
+
+func TestCountersRequireTrustedSubnet(t *testing.T) {
+	tests := []struct {
+		name   string
+		realIP string
+		want   int
+	}{
+		{"outside subnet", "192.168.0.1", http.StatusForbidden},
+		{"inside subnet", "10.20.30.40", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRouter(t, WithTrustedSubnet("10.0.0.0/8"))
+			req := httptest.NewRequest(http.MethodGet, "/api/internal/counters", nil)
+			req.Header.Set("X-Real-IP", tt.realIP)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("Expected %d, got %d", tt.want, w.Code)
+			}
+		})
+	}
+}
+
+// flushRecorder snapshots the body length at every flush that sent new bytes.
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+	chunks []int
+}
+
+func (f *flushRecorder) Flush() {
+	if n := f.Body.Len(); len(f.chunks) == 0 || f.chunks[len(f.chunks)-1] != n {
+		f.chunks = append(f.chunks, n)
+	}
+	f.ResponseRecorder.Flush()
+}
+
+func TestUserURLsStreamThroughDefaultMiddleware(t *testing.T) {
+	r := newTestRouter(t, WithRequestTimeout(30*time.Second))
+
+	var batch []models.BatchShortenRequest
+	for i := 0; i < 250; i++ {
+		batch = append(batch, models.BatchShortenRequest{
+			CorrelationID: fmt.Sprint(i),
+			OriginalURL:   fmt.Sprintf("https://example.com/%d", i),
+		})
+	}
+	body, err := json.Marshal(batch)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/api/shorten/batch", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201 for the batch, got %d: %s", w.Code, w.Body.String())
+	}
+	cookies := w.Result().Cookies()
+
+	for _, encoding := range []string{"", "gzip"} {
+		t.Run("encoding="+encoding, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/user/urls", nil)
+			for _, c := range cookies {
+				req.AddCookie(c)
+			}
+			if encoding != "" {
+				req.Header.Set("Accept-Encoding", encoding)
+			}
+			rec := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+			r.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("Expected 200, got %d", rec.Code)
+			}
+			if len(rec.chunks) < 2 {
+				t.Fatalf("Expected the list to arrive in several flushed chunks, got %v", rec.chunks)
+			}
+			if last := rec.chunks[len(rec.chunks)-1]; last >= rec.Body.Len() {
+				t.Errorf("Expected chunks before the end of the body, got %v of %d bytes", rec.chunks, rec.Body.Len())
+			}
+		})
+	}
+}
+
+// stallingFetcher holds user listings until the request context is done, like
+// a database that is too slow to answer within the request timeout.
+type stallingFetcher struct {
+	models.URLFetcher
+}
+
+func (f stallingFetcher) GetURLsByUserID(ctx context.Context, userID string) ([]models.UserURL, error) {
+	<-ctx.Done()
+	return nil, fmt.Errorf("failed to get user URLs: %w", ctx.Err())
+}
+
+func TestRequestTimeoutAnswers503FromStorage(t *testing.T) {
+	urlStorage, err := storage.NewStorage("", "")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	serviceImpl := service.NewService(
+		urlStorage.AsURLSaver(),
+		urlStorage.AsURLBatchSaver(),
+		urlStorage.AsURLGetter(),
+		stallingFetcher{urlStorage.AsURLFetcher()},
+		urlStorage.AsURLDeleter(),
+		urlStorage.AsPinger(),
+		generator.NewGenerator(8),
+		testBaseURL,
+	)
+	h := handler.NewURLHandler(serviceImpl, serviceImpl, serviceImpl, serviceImpl, serviceImpl, serviceImpl, testBaseURL)
+	r := NewRouter(h, WithRequestTimeout(50*time.Millisecond)).InitRoutes()
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("https://example.com"))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201 for the shorten, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/user/urls", nil)
+	for _, c := range w.Result().Cookies() {
+		req.AddCookie(c)
+	}
+	start := time.Now()
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503, got %d: %s", w.Code, w.Body.String())
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the request to end near the deadline, took %s", elapsed)
+	}
+}
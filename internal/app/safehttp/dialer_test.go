@@ -0,0 +1,76 @@
+package safehttp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDialerRefusesBlockedAddresses(t *testing.T) {
+	d := NewDialer()
+	for _, addr := range []string{
+		"169.254.169.254:80",
+		"127.0.0.1:80",
+		"10.0.0.1:80",
+		"172.16.5.4:443",
+		"192.168.1.1:80",
+		"[::1]:80",
+		"localhost:80",
+	} {
+		conn, err := d.DialContext(context.Background(), "tcp", addr)
+		if conn != nil {
+			conn.Close()
+		}
+		if !errors.Is(err, ErrBlockedAddress) {
+			t.Errorf("Expected %s to be refused, got %v", addr, err)
+		}
+	}
+}
+
+func TestDialerAllowsPublicAddresses(t *testing.T) {
+	d := NewDialer()
+	for _, ip := range []string{"93.184.216.34", "8.8.8.8", "2606:4700:4700::1111", "172.32.0.1"} {
+		if !d.Allowed(net.ParseIP(ip)) {
+			t.Errorf("Expected %s to be allowed", ip)
+		}
+	}
+}
+
+func TestDialerCustomBlocklist(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	blocked, err := ParseNetworks([]string{"169.254.0.0/16"})
+	if err != nil {
+		t.Fatalf("ParseNetworks failed: %v", err)
+	}
+	client := NewDialer(WithBlockedNetworks(blocked...)).Client(0)
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected loopback to be reachable with a custom blocklist, got %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected 204, got %d", resp.StatusCode)
+	}
+
+	if _, err := client.Get("http://169.254.169.254/latest/meta-data"); !errors.Is(err, ErrBlockedAddress) {
+		t.Errorf("Expected metadata address to stay blocked, got %v", err)
+	}
+
+	if _, err := NewDialer().Client(0).Get(server.URL); !errors.Is(err, ErrBlockedAddress) {
+		t.Errorf("Expected the default client to refuse loopback, got %v", err)
+	}
+}
+
+func TestParseNetworksRejectsInvalid(t *testing.T) {
+	if _, err := ParseNetworks([]string{"10.0.0.0/99"}); err == nil {
+		t.Error("Expected an invalid CIDR to be rejected")
+	}
+}
@@ -0,0 +1,55 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/AlenaMolokova/http/internal/app/generator"
+	"github.com/AlenaMolokova/http/internal/app/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+const selfTestUserID = "selftest"
+
+// SelfTest saves, reads back and deletes a URL directly in storage. It skips
+// the service so shortening policy such as allowlists or reachability checks
+// cannot fail it on a healthy backend.
+func SelfTest(ctx context.Context, saver models.URLSaver, getter models.URLGetter, deleter models.URLDeleter, pinger models.Pinger) error {
+	purger, ok := deleter.(models.URLPurger)
+	if !ok {
+		return fmt.Errorf("delete: storage does not support purging URLs")
+	}
+
+	testURL := fmt.Sprintf("https://selftest.invalid/%s", uuid.NewString())
+	shortID := generator.NewGenerator(8).Generate()
+
+	if err := saver.Save(ctx, shortID, testURL, selfTestUserID); err != nil {
+		return fmt.Errorf("save: %w", err)
+	}
+	logrus.WithField("shortID", shortID).Info("Self-test: URL saved")
+
+	originalURL, ok := getter.Get(ctx, shortID)
+	if !ok {
+		purger.PurgeURLs(ctx, []string{shortID}, selfTestUserID)
+		return fmt.Errorf("read back: short ID %s not found", shortID)
+	}
+	if originalURL != testURL {
+		purger.PurgeURLs(ctx, []string{shortID}, selfTestUserID)
+		return fmt.Errorf("read back: got %s, want %s", originalURL, testURL)
+	}
+
+	if err := purger.PurgeURLs(ctx, []string{shortID}, selfTestUserID); err != nil {
+		return fmt.Errorf("delete: %w", err)
+	}
+	if _, ok := getter.Get(ctx, shortID); ok {
+		return fmt.Errorf("delete: short ID %s is still readable", shortID)
+	}
+
+	if err := pinger.Ping(ctx); err != nil && !errors.Is(err, models.ErrNoDatabase) {
+		return fmt.Errorf("ping: %w", err)
+	}
+
+	return nil
+}
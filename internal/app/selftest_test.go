@@ -0,0 +1,72 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/AlenaMolokova/http/internal/app/config"
+	"github.com/AlenaMolokova/http/internal/app/storage/memory"
+	"github.com/caarlos0/env/v9"
+)
+
+type failingGetStorage struct {
+	*memory.MemoryStorage
+}
+
+func (s failingGetStorage) GetBatch(ctx context.Context, shortIDs []string) (map[string]string, error) {
+	return nil, errors.New("storage unavailable")
+}
+
+func (s failingGetStorage) Get(ctx context.Context, shortID string) (string, bool) {
+	return "", false
+}
+
+func TestSelfTestPassesWithMemoryStorage(t *testing.T) {
+	store := memory.NewMemoryStorage()
+
+	if err := SelfTest(context.Background(), store, store, store, store); err != nil {
+		t.Fatalf("Expected self-test to pass, got %v", err)
+	}
+
+	urls, err := store.GetURLsByUserID(context.Background(), selfTestUserID)
+	if err != nil {
+		t.Fatalf("Failed to list URLs: %v", err)
+	}
+	if len(urls) != 0 {
+		t.Errorf("Expected self-test data to be removed, found %d URLs", len(urls))
+	}
+}
+
+func TestSelfTestFailsWhenStorageErrors(t *testing.T) {
+	store := memory.NewMemoryStorage()
+	broken := failingGetStorage{store}
+
+	if err := SelfTest(context.Background(), store, broken, store, store); err == nil {
+		t.Fatal("Expected self-test to fail")
+	}
+
+	urls, _ := store.GetURLsByUserID(context.Background(), selfTestUserID)
+	if len(urls) != 0 {
+		t.Errorf("Expected self-test data to be removed after failure, found %d URLs", len(urls))
+	}
+}
+
+func TestSelfTestIgnoresShortenPolicy(t *testing.T) {
+	cfg := &config.Config{}
+	if err := env.Parse(cfg); err != nil {
+		t.Fatalf("Failed to load default config: %v", err)
+	}
+	cfg.FileStoragePath = ""
+	cfg.VerifyReachable = true
+	cfg.ShortenAllowlist = []string{"example.com"}
+	a, err := NewApp(cfg)
+	if err != nil {
+		t.Fatalf("NewApp failed: %v", err)
+	}
+	defer a.Close()
+
+	if err := a.SelfTest(context.Background()); err != nil {
+		t.Fatalf("Expected self-test to pass under a strict shortening policy, got %v", err)
+	}
+}
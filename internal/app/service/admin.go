@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+
+	"github.com/AlenaMolokova/http/internal/app/models"
+	"github.com/sirupsen/logrus"
+)
+
+// AdminService backs the internal endpoints. It works across all users, so
+// it is kept apart from Service and wired only when admin routes are enabled.
+type AdminService struct {
+	backup  models.BackupStore
+	deleter models.URLDeleter
+}
+
+func NewAdminService(backup models.BackupStore, deleter models.URLDeleter) *AdminService {
+	return &AdminService{backup: backup, deleter: deleter}
+}
+
+func (a *AdminService) Stats(ctx context.Context) (models.AdminStats, error) {
+	urls, err := a.backup.Export(ctx)
+	if err != nil {
+		return models.AdminStats{}, err
+	}
+
+	var stats models.AdminStats
+	users := make(map[string]struct{})
+	for _, url := range urls {
+		if url.IsDeleted {
+			stats.Deleted++
+			continue
+		}
+		stats.URLs++
+		users[url.UserID] = struct{}{}
+	}
+	stats.Users = len(users)
+	return stats, nil
+}
+
+func (a *AdminService) Export(ctx context.Context) ([]models.UserURL, error) {
+	return a.backup.Export(ctx)
+}
+
+func (a *AdminService) Import(ctx context.Context, urls []models.UserURL) error {
+	return a.backup.Import(ctx, urls)
+}
+
+// Restore undeletes the given short URLs whoever owns them and reports how
+// many were deleted before the call. Unknown IDs are skipped.
+func (a *AdminService) Restore(ctx context.Context, shortIDs []string) (int, error) {
+	urls, err := a.backup.Export(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	wanted := make(map[string]struct{}, len(shortIDs))
+	for _, id := range shortIDs {
+		wanted[id] = struct{}{}
+	}
+	byOwner := make(map[string][]string)
+	for _, url := range urls {
+		if _, ok := wanted[url.ShortURL]; ok && url.IsDeleted {
+			byOwner[url.UserID] = append(byOwner[url.UserID], url.ShortURL)
+		}
+	}
+
+	restored := 0
+	for userID, ids := range byOwner {
+		if err := a.deleter.RestoreURLs(ctx, ids, userID); err != nil {
+			return restored, err
+		}
+		restored += len(ids)
+	}
+	logrus.WithField("count", restored).Info("Admin restored URLs")
+	return restored, nil
+}
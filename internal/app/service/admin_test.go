@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/AlenaMolokova/http/internal/app/models"
+)
+
+type mockBackup struct {
+	urls     []models.UserURL
+	imported []models.UserURL
+	err      error
+}
+
+func (m *mockBackup) Export(ctx context.Context) ([]models.UserURL, error) {
+	return m.urls, m.err
+}
+
+func (m *mockBackup) Import(ctx context.Context, urls []models.UserURL) error {
+	m.imported = append(m.imported, urls...)
+	return m.err
+}
+
+type mockRestorer struct {
+	restored map[string][]string
+}
+
+func (m *mockRestorer) DeleteURLs(ctx context.Context, shortIDs []string, userID string) error {
+	return nil
+}
+
+func (m *mockRestorer) RestoreURLs(ctx context.Context, shortIDs []string, userID string) error {
+	m.restored[userID] = append(m.restored[userID], shortIDs...)
+	return nil
+}
+
+func newMockAdmin() (*AdminService, *mockBackup, *mockRestorer) {
+	backup := &mockBackup{urls: []models.UserURL{
+		{ShortURL: "a1", OriginalURL: "https://a.example/1", UserID: "alice"},
+		{ShortURL: "a2", OriginalURL: "https://a.example/2", UserID: "alice", IsDeleted: true},
+		{ShortURL: "b1", OriginalURL: "https://b.example/1", UserID: "bob", IsDeleted: true},
+		{ShortURL: "c1", OriginalURL: "https://c.example/1", UserID: "carol"},
+	}}
+	restorer := &mockRestorer{restored: make(map[string][]string)}
+	return NewAdminService(backup, restorer), backup, restorer
+}
+
+func TestAdminServiceStats(t *testing.T) {
+	admin, _, _ := newMockAdmin()
+
+	stats, err := admin.Stats(context.Background())
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	if want := (models.AdminStats{URLs: 2, Deleted: 2, Users: 2}); stats != want {
+		t.Errorf("Expected %+v, got %+v", want, stats)
+	}
+}
+
+func TestAdminServiceExportImport(t *testing.T) {
+	admin, backup, _ := newMockAdmin()
+	ctx := context.Background()
+
+	urls, err := admin.Export(ctx)
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if len(urls) != len(backup.urls) {
+		t.Errorf("Expected %d exported URLs, got %d", len(backup.urls), len(urls))
+	}
+
+	if err := admin.Import(ctx, urls[:1]); err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if len(backup.imported) != 1 || backup.imported[0].ShortURL != "a1" {
+		t.Errorf("Unexpected imported URLs: %+v", backup.imported)
+	}
+}
+
+func TestAdminServiceRestoreGroupsByOwner(t *testing.T) {
+	admin, _, restorer := newMockAdmin()
+
+	restored, err := admin.Restore(context.Background(), []string{"a1", "a2", "b1", "missing"})
+	if err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if restored != 2 {
+		t.Errorf("Expected 2 restored URLs, got %d", restored)
+	}
+
+	owners := make([]string, 0, len(restorer.restored))
+	for owner := range restorer.restored {
+		owners = append(owners, owner)
+	}
+	sort.Strings(owners)
+	if len(owners) != 2 || owners[0] != "alice" || owners[1] != "bob" {
+		t.Fatalf("Expected restores for alice and bob, got %v", restorer.restored)
+	}
+	if ids := restorer.restored["alice"]; len(ids) != 1 || ids[0] != "a2" {
+		t.Errorf("Expected only a2 restored for alice, got %v", ids)
+	}
+}
+
+func TestAdminServicePropagatesExportError(t *testing.T) {
+	admin, backup, _ := newMockAdmin()
+	backup.err = errors.New("storage unavailable")
+
+	if _, err := admin.Stats(context.Background()); err == nil {
+		t.Error("Expected Stats to fail")
+	}
+	if _, err := admin.Restore(context.Background(), []string{"a2"}); err == nil {
+		t.Error("Expected Restore to fail")
+	}
+}
@@ -0,0 +1,173 @@
+package service
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/AlenaMolokova/http/internal/app/models"
+)
+
+type cacheInvalidator interface {
+	Invalidate(shortIDs ...string)
+}
+
+// invalidateCache drops shortIDs from the redirect cache, if there is one,
+// including entries that remember them as missing.
+func (s *Service) invalidateCache(shortIDs ...string) {
+	if cache, ok := s.getter.(cacheInvalidator); ok {
+		cache.Invalidate(shortIDs...)
+	}
+}
+
+type cacheEntry struct {
+	shortID     string
+	originalURL string
+	found       bool
+	expiresAt   time.Time
+}
+
+// CachingGetter keeps redirect targets in an LRU in front of a URLGetter. It
+// is the only read cache in the service; user URL listings always go to
+// storage.
+type CachingGetter struct {
+	// TTL bounds how long a found URL is served from the cache. Writes made by
+	// other instances are only seen once it elapses; zero caches until evicted.
+	TTL time.Duration
+
+	getter      models.URLGetter
+	size        int
+	negativeTTL time.Duration
+	now         func() time.Time
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+func NewCachingGetter(getter models.URLGetter, size int, negativeTTL time.Duration) *CachingGetter {
+	return &CachingGetter{
+		getter:      getter,
+		size:        size,
+		negativeTTL: negativeTTL,
+		now:         time.Now,
+		order:       list.New(),
+		entries:     make(map[string]*list.Element),
+	}
+}
+
+func (c *CachingGetter) Get(ctx context.Context, shortID string) (string, bool) {
+	if entry, ok := c.lookup(shortID); ok {
+		return entry.originalURL, entry.found
+	}
+
+	originalURL, found := c.getter.Get(ctx, shortID)
+	if found || c.negativeTTL > 0 {
+		c.store(shortID, originalURL, found)
+	}
+	return originalURL, found
+}
+
+func (c *CachingGetter) GetBatch(ctx context.Context, shortIDs []string) (map[string]string, error) {
+	result := make(map[string]string, len(shortIDs))
+	var misses []string
+	for _, shortID := range shortIDs {
+		entry, ok := c.lookup(shortID)
+		if !ok {
+			misses = append(misses, shortID)
+			continue
+		}
+		if entry.found {
+			result[shortID] = entry.originalURL
+		}
+	}
+	if len(misses) == 0 {
+		return result, nil
+	}
+
+	fetched, err := c.getter.GetBatch(ctx, misses)
+	if err != nil {
+		return nil, err
+	}
+	for _, shortID := range misses {
+		originalURL, found := fetched[shortID]
+		if found {
+			result[shortID] = originalURL
+		}
+		if found || c.negativeTTL > 0 {
+			c.store(shortID, originalURL, found)
+		}
+	}
+	return result, nil
+}
+
+// Warm loads up to the cache size of the newest URLs from source, so the
+// first redirects after startup do not reach the storage. It returns how
+// many entries were loaded.
+func (c *CachingGetter) Warm(ctx context.Context, source models.RecentURLLister) (int, error) {
+	urls, err := source.RecentURLs(ctx, c.size)
+	if err != nil {
+		return 0, err
+	}
+	// Oldest first, so the newest end up at the front of the LRU order.
+	for i := len(urls) - 1; i >= 0; i-- {
+		c.store(urls[i].ShortURL, urls[i].OriginalURL, true)
+	}
+	return len(urls), nil
+}
+
+func (c *CachingGetter) Invalidate(shortIDs ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, shortID := range shortIDs {
+		if el, ok := c.entries[shortID]; ok {
+			c.order.Remove(el)
+			delete(c.entries, shortID)
+		}
+	}
+}
+
+func (c *CachingGetter) lookup(shortID string) (cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[shortID]
+	if !ok {
+		return cacheEntry{}, false
+	}
+	entry := el.Value.(*cacheEntry)
+	if !entry.expiresAt.IsZero() && c.now().After(entry.expiresAt) {
+		c.order.Remove(el)
+		delete(c.entries, shortID)
+		return cacheEntry{}, false
+	}
+	c.order.MoveToFront(el)
+	return *entry, true
+}
+
+func (c *CachingGetter) store(shortID, originalURL string, found bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &cacheEntry{shortID: shortID, originalURL: originalURL, found: found}
+	if !found {
+		entry.expiresAt = c.now().Add(c.negativeTTL)
+	} else if c.TTL > 0 {
+		entry.expiresAt = c.now().Add(c.TTL)
+	}
+
+	if el, ok := c.entries[shortID]; ok {
+		el.Value = entry
+		c.order.MoveToFront(el)
+		return
+	}
+
+	c.entries[shortID] = c.order.PushFront(entry)
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).shortID)
+	}
+}
@@ -0,0 +1,302 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/AlenaMolokova/http/internal/app/generator"
+	"github.com/AlenaMolokova/http/internal/app/models"
+	"github.com/AlenaMolokova/http/internal/app/storage/memory"
+)
+
+type countingGetter struct {
+	urls  map[string]string
+	calls atomic.Int64
+}
+
+func (g *countingGetter) Get(ctx context.Context, shortID string) (string, bool) {
+	g.calls.Add(1)
+	originalURL, ok := g.urls[shortID]
+	return originalURL, ok
+}
+
+func (g *countingGetter) GetBatch(ctx context.Context, shortIDs []string) (map[string]string, error) {
+	g.calls.Add(1)
+	result := make(map[string]string)
+	for _, shortID := range shortIDs {
+		if originalURL, ok := g.urls[shortID]; ok {
+			result[shortID] = originalURL
+		}
+	}
+	return result, nil
+}
+
+// recentGetter lists its URLs in the order given, newest first.
+type recentGetter struct {
+	countingGetter
+	recent []models.UserURL
+}
+
+func (g *recentGetter) RecentURLs(ctx context.Context, limit int) ([]models.UserURL, error) {
+	if len(g.recent) > limit {
+		return g.recent[:limit], nil
+	}
+	return g.recent, nil
+}
+
+func TestCachingGetterWarmServesWithoutRequery(t *testing.T) {
+	getter := &recentGetter{
+		countingGetter: countingGetter{urls: map[string]string{
+			"new": "https://example.com/new",
+			"mid": "https://example.com/mid",
+			"old": "https://example.com/old",
+		}},
+		recent: []models.UserURL{
+			{ShortURL: "new", OriginalURL: "https://example.com/new"},
+			{ShortURL: "mid", OriginalURL: "https://example.com/mid"},
+			{ShortURL: "old", OriginalURL: "https://example.com/old"},
+		},
+	}
+	cache := NewCachingGetter(getter, 2, time.Minute)
+
+	n, err := cache.Warm(context.Background(), getter)
+	if err != nil {
+		t.Fatalf("Warm failed: %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("Expected 2 warmed entries, got %d", n)
+	}
+
+	for _, id := range []string{"new", "mid"} {
+		if originalURL, found := cache.Get(context.Background(), id); !found || originalURL != getter.urls[id] {
+			t.Errorf("Expected warmed URL for %q, got %q (found=%v)", id, originalURL, found)
+		}
+	}
+	if calls := getter.calls.Load(); calls != 0 {
+		t.Errorf("Expected warmed redirects to skip the getter, got %d calls", calls)
+	}
+
+	cache.Get(context.Background(), "old")
+	if calls := getter.calls.Load(); calls != 1 {
+		t.Errorf("Expected an entry beyond the cache size to be fetched, got %d calls", calls)
+	}
+}
+
+func TestCachingGetterHitDoesNotRequery(t *testing.T) {
+	getter := &countingGetter{urls: map[string]string{"abc": "https://example.com"}}
+	cache := NewCachingGetter(getter, 10, time.Minute)
+
+	for i := 0; i < 5; i++ {
+		originalURL, found := cache.Get(context.Background(), "abc")
+		if !found || originalURL != "https://example.com" {
+			t.Fatalf("Expected cached URL, got %q (found=%v)", originalURL, found)
+		}
+	}
+	if calls := getter.calls.Load(); calls != 1 {
+		t.Errorf("Expected 1 underlying call, got %d", calls)
+	}
+}
+
+func TestCachingGetterNegativeEntryExpires(t *testing.T) {
+	getter := &countingGetter{urls: map[string]string{}}
+	cache := NewCachingGetter(getter, 10, time.Second)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	cache.Get(context.Background(), "missing")
+	cache.Get(context.Background(), "missing")
+	if calls := getter.calls.Load(); calls != 1 {
+		t.Fatalf("Expected negative result to be cached, got %d calls", calls)
+	}
+
+	now = now.Add(2 * time.Second)
+	cache.Get(context.Background(), "missing")
+	if calls := getter.calls.Load(); calls != 2 {
+		t.Errorf("Expected expired negative entry to re-query, got %d calls", calls)
+	}
+}
+
+func TestCachingGetterEntryExpiresAfterTTL(t *testing.T) {
+	getter := &countingGetter{urls: map[string]string{"abc": "https://example.com"}}
+	cache := NewCachingGetter(getter, 10, time.Second)
+	cache.TTL = time.Minute
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	cache.Get(context.Background(), "abc")
+	now = now.Add(30 * time.Second)
+	cache.Get(context.Background(), "abc")
+	if calls := getter.calls.Load(); calls != 1 {
+		t.Fatalf("Expected URL to be cached within the TTL, got %d calls", calls)
+	}
+
+	// Another instance changes the target; the cache catches up after the TTL.
+	getter.urls["abc"] = "https://example.org"
+	now = now.Add(31 * time.Second)
+	originalURL, found := cache.Get(context.Background(), "abc")
+	if calls := getter.calls.Load(); calls != 2 {
+		t.Errorf("Expected expired entry to re-query, got %d calls", calls)
+	}
+	if !found || originalURL != "https://example.org" {
+		t.Errorf("Expected refreshed URL, got %q (found=%v)", originalURL, found)
+	}
+
+	cache.GetBatch(context.Background(), []string{"abc"})
+	if calls := getter.calls.Load(); calls != 2 {
+		t.Errorf("Expected refreshed entry to be cached again, got %d calls", calls)
+	}
+}
+
+func TestCachingGetterEvictsLeastRecentlyUsed(t *testing.T) {
+	getter := &countingGetter{urls: map[string]string{"a": "https://a.example", "b": "https://b.example", "c": "https://c.example"}}
+	cache := NewCachingGetter(getter, 2, time.Minute)
+	ctx := context.Background()
+
+	cache.Get(ctx, "a")
+	cache.Get(ctx, "b")
+	cache.Get(ctx, "a")
+	cache.Get(ctx, "c")
+
+	getter.calls.Store(0)
+	cache.Get(ctx, "a")
+	if calls := getter.calls.Load(); calls != 0 {
+		t.Errorf("Expected recently used entry to stay cached, got %d calls", calls)
+	}
+	cache.Get(ctx, "b")
+	if calls := getter.calls.Load(); calls != 1 {
+		t.Errorf("Expected least recently used entry to be evicted, got %d calls", calls)
+	}
+}
+
+func TestServiceDeleteInvalidatesCache(t *testing.T) {
+	store := memory.NewMemoryStorage()
+	cache := NewCachingGetter(store, 10, time.Minute)
+	svc := NewService(store, store, cache, store, store, store, generator.NewGenerator(8), "http://localhost:8080")
+	ctx := context.Background()
+
+	if err := store.Save(ctx, "abc", "https://example.com", "user"); err != nil {
+		t.Fatalf("Failed to save URL: %v", err)
+	}
+	if _, found := svc.Get(ctx, "abc"); !found {
+		t.Fatal("Expected URL to be found")
+	}
+
+	if err := svc.DeleteURLs(ctx, []string{"abc"}, "user"); err != nil {
+		t.Fatalf("Failed to delete URLs: %v", err)
+	}
+	if _, found := svc.Get(ctx, "abc"); found {
+		t.Error("Expected deleted URL to be evicted from cache")
+	}
+}
+
+func BenchmarkCachingGetter(b *testing.B) {
+	urls := make(map[string]string, 100)
+	for i := 0; i < 100; i++ {
+		urls[fmt.Sprintf("id%d", i)] = fmt.Sprintf("https://example.com/%d", i)
+	}
+
+	b.Run("uncached", func(b *testing.B) {
+		getter := &countingGetter{urls: urls}
+		for i := 0; i < b.N; i++ {
+			getter.Get(context.Background(), fmt.Sprintf("id%d", i%100))
+		}
+		b.ReportMetric(float64(getter.calls.Load())/float64(b.N), "getter-calls/op")
+	})
+
+	b.Run("cached", func(b *testing.B) {
+		getter := &countingGetter{urls: urls}
+		cache := NewCachingGetter(getter, 100, time.Minute)
+		for i := 0; i < b.N; i++ {
+			cache.Get(context.Background(), fmt.Sprintf("id%d", i%100))
+		}
+		b.ReportMetric(float64(getter.calls.Load())/float64(b.N), "getter-calls/op")
+	})
+}
+
+func TestCachingGetterGetBatch(t *testing.T) {
+	getter := &countingGetter{urls: map[string]string{"a": "https://a.example", "b": "https://b.example"}}
+	cache := NewCachingGetter(getter, 10, time.Minute)
+	ctx := context.Background()
+
+	cache.Get(ctx, "a")
+	getter.calls.Store(0)
+
+	urls, err := cache.GetBatch(ctx, []string{"a", "b", "missing"})
+	if err != nil {
+		t.Fatalf("GetBatch failed: %v", err)
+	}
+	if len(urls) != 2 || urls["a"] != "https://a.example" || urls["b"] != "https://b.example" {
+		t.Errorf("Unexpected batch result: %v", urls)
+	}
+	if calls := getter.calls.Load(); calls != 1 {
+		t.Errorf("Expected a single underlying batch call, got %d", calls)
+	}
+
+	if _, err := cache.GetBatch(ctx, []string{"a", "b", "missing"}); err != nil {
+		t.Fatalf("GetBatch failed: %v", err)
+	}
+	if calls := getter.calls.Load(); calls != 1 {
+		t.Errorf("Expected fully cached batch, got %d calls", calls)
+	}
+}
+
+func TestShortenInvalidatesCachedMiss(t *testing.T) {
+	store := memory.NewMemoryStorage()
+	getter := &countingGetter{urls: map[string]string{}}
+	cache := NewCachingGetter(getter, 10, time.Minute)
+	svc := NewService(store, store, cache, store, store, store, &sequenceGenerator{ids: []string{"fresh", "later"}}, "http://localhost:8080")
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if _, found := svc.Get(ctx, "fresh"); found {
+			t.Fatal("Expected a miss before the ID exists")
+		}
+	}
+	if calls := getter.calls.Load(); calls != 1 {
+		t.Fatalf("Expected the miss to be cached after one lookup, got %d lookups", calls)
+	}
+
+	if _, err := svc.ShortenURL(ctx, "https://example.com/fresh", "user"); err != nil {
+		t.Fatalf("ShortenURL failed: %v", err)
+	}
+	getter.urls["fresh"] = "https://example.com/fresh"
+
+	if originalURL, found := svc.Get(ctx, "fresh"); !found || originalURL != "https://example.com/fresh" {
+		t.Errorf("Expected the new ID not to be shadowed by the cached miss, got %q, %v", originalURL, found)
+	}
+}
+
+func TestImportInvalidatesCache(t *testing.T) {
+	store := memory.NewMemoryStorage()
+	cache := NewCachingGetter(store, 10, time.Minute)
+	svc := NewService(store, store, cache, store, store, store, generator.NewGenerator(8), "http://localhost:8080")
+	ctx := context.Background()
+
+	if err := store.Save(ctx, "imp1", "https://old.example.com", "owner"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if got, _ := svc.Get(ctx, "imp1"); got != "https://old.example.com" {
+		t.Fatalf("Expected the old URL to be cached, got %q", got)
+	}
+	if _, found := svc.Get(ctx, "imp2"); found {
+		t.Fatal("Expected imp2 to be missing before the import")
+	}
+
+	err := svc.Import(ctx, []models.UserURL{
+		{ShortURL: "imp1", OriginalURL: "https://new.example.com", UserID: "owner"},
+		{ShortURL: "imp2", OriginalURL: "https://added.example.com", UserID: "owner"},
+	})
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+
+	if got, _ := svc.Get(ctx, "imp1"); got != "https://new.example.com" {
+		t.Errorf("Expected the imported URL, got %q", got)
+	}
+	if got, _ := svc.Get(ctx, "imp2"); got != "https://added.example.com" {
+		t.Errorf("Expected the cached miss to be dropped, got %q", got)
+	}
+}
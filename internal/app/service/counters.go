@@ -0,0 +1,30 @@
+package service
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/AlenaMolokova/http/internal/app/models"
+)
+
+// counters are process-local totals served by /api/internal/counters for
+// deployments without a metrics stack.
+type counters struct {
+	shortened atomic.Int64
+	redirects atomic.Int64
+	gone      atomic.Int64
+	deleted   atomic.Int64
+}
+
+func (s *Service) Counters() models.Counters {
+	return models.Counters{
+		Shortened: s.counters.shortened.Load(),
+		Redirects: s.counters.redirects.Load(),
+		Gone:      s.counters.gone.Load(),
+		Deleted:   s.counters.deleted.Load(),
+	}
+}
+
+func (s *Service) RecordGone(ctx context.Context, shortID string) {
+	s.counters.gone.Add(1)
+}
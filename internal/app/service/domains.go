@@ -0,0 +1,45 @@
+package service
+
+import (
+	"net/url"
+	"strings"
+)
+
+// domainAllowed checks the host of rawURL against the block and allow lists.
+// Entries match a host exactly; a "*." prefix matches any subdomain of the
+// rest. A non-empty allowlist rejects every host it does not match.
+func (s *Service) domainAllowed(rawURL string) bool {
+	if len(s.DomainBlocklist) == 0 && len(s.DomainAllowlist) == 0 {
+		return true
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+
+	if matchDomain(s.DomainBlocklist, host) {
+		return false
+	}
+	return len(s.DomainAllowlist) == 0 || matchDomain(s.DomainAllowlist, host)
+}
+
+func matchDomain(patterns []string, host string) bool {
+	if host == "" {
+		return false
+	}
+	for _, pattern := range patterns {
+		pattern = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(pattern)), ".")
+		if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+			continue
+		}
+		if pattern != "" && host == pattern {
+			return true
+		}
+	}
+	return false
+}
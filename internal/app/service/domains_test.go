@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/AlenaMolokova/http/internal/app/models"
+)
+
+func TestShortenDomainLists(t *testing.T) {
+	tests := []struct {
+		name      string
+		blocklist []string
+		allowlist []string
+		allowed   map[string]bool
+	}{
+		{
+			name: "no lists",
+			allowed: map[string]bool{
+				"https://evil.example/login": true,
+				"https://example.com":        true,
+			},
+		},
+		{
+			name:      "blocklist",
+			blocklist: []string{"evil.example", "*.phish.example"},
+			allowed: map[string]bool{
+				"https://evil.example/login":    false,
+				"https://EVIL.example./login":   false,
+				"https://login.phish.example/a": false,
+				"https://a.b.phish.example/a":   false,
+				"https://phish.example/a":       true,
+				"https://sub.evil.example/a":    true,
+				"https://example.com":           true,
+			},
+		},
+		{
+			name:      "allowlist",
+			allowlist: []string{"example.com", "*.corp.example"},
+			allowed: map[string]bool{
+				"https://example.com/page":     true,
+				"https://docs.corp.example/a":  true,
+				"https://corp.example/a":       false,
+				"https://www.example.com/page": false,
+				"https://other.example":        false,
+			},
+		},
+		{
+			name:      "blocklist wins over allowlist",
+			blocklist: []string{"bad.corp.example"},
+			allowlist: []string{"*.corp.example"},
+			allowed: map[string]bool{
+				"https://good.corp.example": true,
+				"https://bad.corp.example":  false,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, _ := newTestService()
+			svc.DomainBlocklist = tt.blocklist
+			svc.DomainAllowlist = tt.allowlist
+
+			for target, allowed := range tt.allowed {
+				_, err := svc.ShortenURL(context.Background(), target, "user")
+				if allowed && err != nil {
+					t.Errorf("Expected %s to be accepted, got %v", target, err)
+				}
+				if !allowed && !errors.Is(err, models.ErrDomainNotAllowed) {
+					t.Errorf("Expected ErrDomainNotAllowed for %s, got %v", target, err)
+				}
+			}
+		})
+	}
+}
+
+func TestShortenBatchRejectsBlockedDomain(t *testing.T) {
+	svc, _ := newTestService()
+	svc.DomainBlocklist = []string{"*.phish.example"}
+
+	_, err := svc.ShortenBatch(context.Background(), []models.BatchShortenRequest{
+		{CorrelationID: "1", OriginalURL: "https://example.com"},
+		{CorrelationID: "2", OriginalURL: "https://login.phish.example"},
+	}, "user")
+	if !errors.Is(err, models.ErrDomainNotAllowed) {
+		t.Fatalf("Expected ErrDomainNotAllowed, got %v", err)
+	}
+
+	if _, err := svc.ShortenBatch(context.Background(), []models.BatchShortenRequest{
+		{CorrelationID: "1", OriginalURL: "https://example.com"},
+	}, "user"); err != nil {
+		t.Errorf("Expected neutral batch to be accepted, got %v", err)
+	}
+}
@@ -0,0 +1,39 @@
+package service
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+)
+
+type Hooks interface {
+	OnShorten(ctx context.Context, userID, shortID, originalURL string)
+	OnDelete(ctx context.Context, userID string, shortIDs []string)
+	OnRedirect(ctx context.Context, shortID string)
+}
+
+func (s *Service) fireHook(ctx context.Context, name string, call func(ctx context.Context, hooks Hooks)) {
+	hooks := s.Hooks
+	if hooks == nil {
+		return
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	s.hooksWG.Add(1)
+	go func() {
+		defer s.hooksWG.Done()
+		defer func() {
+			if r := recover(); r != nil {
+				logrus.WithFields(logrus.Fields{
+					"hook":  name,
+					"panic": r,
+				}).Error("Service hook panicked")
+			}
+		}()
+		call(ctx, hooks)
+	}()
+}
+
+func (s *Service) waitHooks() {
+	s.hooksWG.Wait()
+}
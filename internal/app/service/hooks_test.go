@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type recordingHooks struct {
+	mu        sync.Mutex
+	shortened []string
+	deleted   []string
+	redirects []string
+	userIDs   []string
+}
+
+func (h *recordingHooks) OnShorten(ctx context.Context, userID, shortID, originalURL string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.shortened = append(h.shortened, shortID+"="+originalURL)
+	h.userIDs = append(h.userIDs, userID)
+}
+
+func (h *recordingHooks) OnDelete(ctx context.Context, userID string, shortIDs []string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.deleted = append(h.deleted, shortIDs...)
+	h.userIDs = append(h.userIDs, userID)
+}
+
+func (h *recordingHooks) OnRedirect(ctx context.Context, shortID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.redirects = append(h.redirects, shortID)
+}
+
+type panickingHooks struct{}
+
+func (panickingHooks) OnShorten(ctx context.Context, userID, shortID, originalURL string) {
+	panic("boom")
+}
+
+func (panickingHooks) OnDelete(ctx context.Context, userID string, shortIDs []string) {
+	panic("boom")
+}
+
+func (panickingHooks) OnRedirect(ctx context.Context, shortID string) {
+	panic("boom")
+}
+
+func TestHooksFireOnShortenDeleteAndRedirect(t *testing.T) {
+	svc, _ := newTestService()
+	hooks := &recordingHooks{}
+	svc.Hooks = hooks
+	ctx := context.Background()
+
+	result, err := svc.ShortenURL(ctx, "https://hooked.example.com", "user")
+	if err != nil {
+		t.Fatalf("Shorten failed: %v", err)
+	}
+	shortID := strings.TrimPrefix(result.ShortURL, svc.BaseURL+"/")
+
+	if err := svc.RecordHit(ctx, shortID); err != nil {
+		t.Fatalf("RecordHit failed: %v", err)
+	}
+	if err := svc.DeleteURLs(ctx, []string{shortID}, "user"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	svc.waitHooks()
+
+	hooks.mu.Lock()
+	defer hooks.mu.Unlock()
+	if len(hooks.shortened) != 1 || hooks.shortened[0] != shortID+"=https://hooked.example.com" {
+		t.Errorf("Unexpected OnShorten calls: %v", hooks.shortened)
+	}
+	if len(hooks.deleted) != 1 || hooks.deleted[0] != shortID {
+		t.Errorf("Unexpected OnDelete calls: %v", hooks.deleted)
+	}
+	if len(hooks.redirects) != 1 || hooks.redirects[0] != shortID {
+		t.Errorf("Unexpected OnRedirect calls: %v", hooks.redirects)
+	}
+	for _, id := range hooks.userIDs {
+		if id != "user" {
+			t.Errorf("Expected hooks to receive user, got %s", id)
+		}
+	}
+}
+
+func TestHooksNotFiredForExistingURL(t *testing.T) {
+	svc, _ := newTestService()
+	hooks := &recordingHooks{}
+	svc.Hooks = hooks
+	ctx := context.Background()
+
+	svc.ShortenURL(ctx, "https://once.example.com", "user")
+	svc.ShortenURL(ctx, "https://once.example.com", "user")
+	svc.waitHooks()
+
+	if len(hooks.shortened) != 1 {
+		t.Errorf("Expected OnShorten only for the new URL, got %v", hooks.shortened)
+	}
+}
+
+func TestPanickingHookDoesNotBreakOperation(t *testing.T) {
+	svc, store := newTestService()
+	svc.Hooks = panickingHooks{}
+	ctx := context.Background()
+
+	result, err := svc.ShortenURL(ctx, "https://panic.example.com", "user")
+	if err != nil {
+		t.Fatalf("Expected shorten to succeed despite the hook, got %v", err)
+	}
+	shortID := strings.TrimPrefix(result.ShortURL, svc.BaseURL+"/")
+
+	if err := svc.DeleteURLs(ctx, []string{shortID}, "user"); err != nil {
+		t.Fatalf("Expected delete to succeed despite the hook, got %v", err)
+	}
+	svc.waitHooks()
+
+	if _, ok := store.Get(ctx, shortID); ok {
+		t.Error("Expected the URL to be deleted")
+	}
+}
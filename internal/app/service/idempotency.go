@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/AlenaMolokova/http/internal/app/models"
+)
+
+const (
+	defaultIdempotencyTTL = 24 * time.Hour
+	maxIdempotencyKeys    = 10000
+)
+
+type idempotencyEntry struct {
+	fingerprint string
+	response    models.IdempotentResponse
+	expiresAt   time.Time
+}
+
+type idempotencyCache struct {
+	mu         sync.Mutex
+	entries    map[string]idempotencyEntry
+	maxEntries int
+}
+
+func newIdempotencyCache(maxEntries int) *idempotencyCache {
+	return &idempotencyCache{
+		entries:    make(map[string]idempotencyEntry),
+		maxEntries: maxEntries,
+	}
+}
+
+func (c *idempotencyCache) get(key string, now time.Time) (idempotencyEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return idempotencyEntry{}, false
+	}
+	if !now.Before(entry.expiresAt) {
+		delete(c.entries, key)
+		return idempotencyEntry{}, false
+	}
+	return entry, true
+}
+
+func (c *idempotencyCache) put(key string, entry idempotencyEntry, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, exists := c.entries[key]; !exists && len(c.entries) >= c.maxEntries {
+		c.evict(now)
+	}
+	c.entries[key] = entry
+}
+
+func (c *idempotencyCache) evict(now time.Time) {
+	var oldestKey string
+	var oldest time.Time
+	for key, entry := range c.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(c.entries, key)
+			continue
+		}
+		if oldestKey == "" || entry.expiresAt.Before(oldest) {
+			oldestKey, oldest = key, entry.expiresAt
+		}
+	}
+	if len(c.entries) >= c.maxEntries && oldestKey != "" {
+		delete(c.entries, oldestKey)
+	}
+}
+
+func (s *Service) LookupIdempotent(ctx context.Context, userID, key, fingerprint string) (models.IdempotentResponse, bool, error) {
+	if s.IdempotencyTTL <= 0 {
+		return models.IdempotentResponse{}, false, nil
+	}
+
+	entry, ok := s.idempotency.get(userID+"\x00"+key, s.Clock.Now())
+	if !ok {
+		return models.IdempotentResponse{}, false, nil
+	}
+	if entry.fingerprint != fingerprint {
+		return models.IdempotentResponse{}, false, models.ErrIdempotencyKeyMismatch
+	}
+	return entry.response, true, nil
+}
+
+func (s *Service) SaveIdempotent(ctx context.Context, userID, key, fingerprint string, resp models.IdempotentResponse) {
+	if s.IdempotencyTTL <= 0 {
+		return
+	}
+
+	now := s.Clock.Now()
+	s.idempotency.put(userID+"\x00"+key, idempotencyEntry{
+		fingerprint: fingerprint,
+		response:    resp,
+		expiresAt:   now.Add(s.IdempotencyTTL),
+	}, now)
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/AlenaMolokova/http/internal/app/clock"
+	"github.com/AlenaMolokova/http/internal/app/models"
+)
+
+func TestIdempotencyLookupAndExpiry(t *testing.T) {
+	svc, _ := newTestService()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	svc.Clock = clock.Func(func() time.Time { return now })
+	svc.IdempotencyTTL = time.Minute
+	ctx := context.Background()
+
+	resp := models.IdempotentResponse{StatusCode: 201, ContentType: "application/json", Body: []byte(`{"result":"x"}`)}
+	svc.SaveIdempotent(ctx, "user", "key", "fp", resp)
+
+	got, found, err := svc.LookupIdempotent(ctx, "user", "key", "fp")
+	if err != nil || !found || string(got.Body) != string(resp.Body) {
+		t.Fatalf("Expected cached response, got %+v, %v, %v", got, found, err)
+	}
+
+	if _, _, err := svc.LookupIdempotent(ctx, "user", "key", "other"); !errors.Is(err, models.ErrIdempotencyKeyMismatch) {
+		t.Errorf("Expected ErrIdempotencyKeyMismatch, got %v", err)
+	}
+
+	if _, found, _ := svc.LookupIdempotent(ctx, "someone-else", "key", "fp"); found {
+		t.Error("Expected keys to be scoped per user")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, found, err := svc.LookupIdempotent(ctx, "user", "key", "fp"); found || err != nil {
+		t.Errorf("Expected expired entry to be gone, got %v, %v", found, err)
+	}
+}
+
+func TestIdempotencyCacheEvictsWhenFull(t *testing.T) {
+	cache := newIdempotencyCache(2)
+	now := time.Now()
+
+	cache.put("a", idempotencyEntry{expiresAt: now.Add(time.Minute)}, now)
+	cache.put("b", idempotencyEntry{expiresAt: now.Add(2 * time.Minute)}, now)
+	cache.put("c", idempotencyEntry{expiresAt: now.Add(3 * time.Minute)}, now)
+
+	if _, ok := cache.get("a", now); ok {
+		t.Error("Expected the entry closest to expiry to be evicted")
+	}
+	for _, key := range []string{"b", "c"} {
+		if _, ok := cache.get(key, now); !ok {
+			t.Errorf("Expected %s to be kept", key)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"testing"
+)
+
+func TestNormalizeURL(t *testing.T) {
+	tests := map[string]string{
+		"https://Example.com/path":      "https://example.com/path",
+		"https://example.com/path/":     "https://example.com/path",
+		"HTTPS://EXAMPLE.COM:443/path":  "https://example.com/path",
+		"http://example.com:80/":        "http://example.com",
+		"http://example.com:8080/a/":    "http://example.com:8080/a",
+		"https://example.com/Path?q=A/": "https://example.com/Path?q=A/",
+		"http://[::1]:80/x/":            "http://[::1]/x",
+	}
+	for in, want := range tests {
+		if got := normalizeURL(in); got != want {
+			t.Errorf("normalizeURL(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+var equivalentURLs = []string{
+	"https://Example.com/path",
+	"https://example.com/path/",
+	"https://EXAMPLE.com:443/path",
+}
+
+func TestShortenURLNormalizationEnabled(t *testing.T) {
+	svc, _ := newTestService()
+	svc.NormalizeURLs = true
+
+	shortURLs := make(map[string]bool)
+	for _, u := range equivalentURLs {
+		result, err := svc.ShortenURL(context.Background(), u, "user")
+		if err != nil {
+			t.Fatalf("Failed to shorten %s: %v", u, err)
+		}
+		shortURLs[result.ShortURL] = true
+	}
+	if len(shortURLs) != 1 {
+		t.Errorf("Expected equivalent URLs to share one short URL, got %v", shortURLs)
+	}
+}
+
+func TestShortenURLNormalizationDisabled(t *testing.T) {
+	svc, _ := newTestService()
+
+	shortURLs := make(map[string]bool)
+	for _, u := range equivalentURLs {
+		result, err := svc.ShortenURL(context.Background(), u, "user")
+		if err != nil {
+			t.Fatalf("Failed to shorten %s: %v", u, err)
+		}
+		shortURLs[result.ShortURL] = true
+	}
+	if len(shortURLs) != len(equivalentURLs) {
+		t.Errorf("Expected distinct short URLs without normalization, got %v", shortURLs)
+	}
+}
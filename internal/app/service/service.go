@@ -2,13 +2,25 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/AlenaMolokova/http/internal/app/audit"
+	"github.com/AlenaMolokova/http/internal/app/clock"
 	"github.com/AlenaMolokova/http/internal/app/generator"
 	"github.com/AlenaMolokova/http/internal/app/models"
+	"github.com/AlenaMolokova/http/internal/app/tenant"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
 )
 
+const maxGenerateAttempts = 10
+
 type Service struct {
 	saver     models.URLSaver
 	batch     models.URLBatchSaver
@@ -18,6 +30,27 @@ type Service struct {
 	pinger    models.Pinger
 	generator generator.Generator 
 	BaseURL   string
+	// BaseURLs are the other bases the service answers on; with
+	// RejectSelfLinks, links to them are refused as well.
+	BaseURLs []string
+
+	RejectSelfLinks bool
+	Clock           clock.Clock
+	MaxTagsPerURL   int
+	MaxTagLength    int
+	NormalizeURLs   bool
+	MaxURLsPerUser  int
+	IdempotencyTTL  time.Duration
+	Hooks           Hooks
+	LookupPerUser   bool
+	DomainBlocklist []string
+	DomainAllowlist []string
+	Audit           audit.Logger
+	Reachability    models.ReachabilityChecker
+
+	idempotency *idempotencyCache
+	hooksWG     sync.WaitGroup
+	counters    counters
 }
 
 func NewService(saver models.URLSaver, batch models.URLBatchSaver, getter models.URLGetter, fetcher models.URLFetcher, deleter models.URLDeleter, pinger models.Pinger, generator generator.Generator, baseURL string) *Service {
@@ -30,76 +63,471 @@ func NewService(saver models.URLSaver, batch models.URLBatchSaver, getter models
 		pinger:    pinger,
 		generator: generator,
 		BaseURL:   baseURL,
+		Clock:     clock.System,
+		Audit:     audit.Nop{},
+
+		IdempotencyTTL: defaultIdempotencyTTL,
+		idempotency:    newIdempotencyCache(maxIdempotencyKeys),
 	}
 }
 
 func (s *Service) ShortenURL(ctx context.Context, originalURL, userID string) (models.ShortenResult, error) {
+	return s.ShortenURLWithBase(ctx, originalURL, userID, s.BaseURL)
+}
+
+func (s *Service) ShortenURLWithBase(ctx context.Context, originalURL, userID, baseURL string) (models.ShortenResult, error) {
+	return s.shorten(ctx, originalURL, userID, baseURL, "")
+}
+
+func (s *Service) ShortenProtectedURL(ctx context.Context, originalURL, userID, baseURL, password string) (models.ShortenResult, error) {
+	if _, ok := s.saver.(models.ProtectedURLSaver); !ok {
+		return models.ShortenResult{}, fmt.Errorf("storage does not support password-protected URLs")
+	}
+
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return models.ShortenResult{}, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	return s.shorten(ctx, originalURL, userID, baseURL, string(passwordHash))
+}
+
+func (s *Service) shorten(ctx context.Context, originalURL, userID, baseURL, passwordHash string) (models.ShortenResult, error) {
 	logrus.WithFields(logrus.Fields{
-        "originalURL": originalURL,
-        "userID":      userID,
-    }).Debug("Shortening URL")
-    
-    existingShortID, err := s.saver.FindByOriginalURL(ctx, originalURL)
-    if err != nil {
-        logrus.WithError(err).Error("Error finding URL")
-        return models.ShortenResult{}, fmt.Errorf("error finding URL: %w", err)
-    }
-    if existingShortID != "" {
-        logrus.WithField("shortID", existingShortID).Info("URL already exists")
-        return models.ShortenResult{
-            ShortURL: fmt.Sprintf("%s/%s", s.BaseURL, existingShortID),
-            IsNew:    false,
-        }, nil
-    }
+		"originalURL": originalURL,
+		"userID":      userID,
+	}).Debug("Shortening URL")
 
-    shortID := s.generator.Generate()
-    if shortID == "" {
-        logrus.Error("Generated short ID is empty")
-        return models.ShortenResult{}, fmt.Errorf("failed to generate short ID")
-    }
+	if baseURL == "" {
+		baseURL = s.BaseURL
+	}
 
-    if err := s.saver.Save(ctx, shortID, originalURL, userID); err != nil {
-        logrus.WithError(err).Error("Error saving URL")
-        return models.ShortenResult{}, fmt.Errorf("error saving URL: %w", err)
-    }
+	originalURL, err := s.checkTarget(originalURL, baseURL)
+	if err != nil {
+		return models.ShortenResult{}, err
+	}
+
+	if passwordHash == "" {
+		existingShortID, err := s.saver.FindByOriginalURL(ctx, originalURL, tenant.FromContext(ctx))
+		if err != nil {
+			logrus.WithError(err).Error("Error finding URL")
+			return models.ShortenResult{}, fmt.Errorf("error finding URL: %w", err)
+		}
+		if existingShortID != "" {
+			logrus.WithField("shortID", existingShortID).Info("URL already exists")
+			return models.ShortenResult{
+				ShortURL: fmt.Sprintf("%s/%s", baseURL, existingShortID),
+				IsNew:    false,
+			}, nil
+		}
+	}
+
+	if err := s.checkURLLimit(ctx, userID, 1); err != nil {
+		return models.ShortenResult{}, err
+	}
+
+	if err := s.checkReachable(ctx, originalURL); err != nil {
+		return models.ShortenResult{}, err
+	}
 
-    logrus.WithField("shortID", shortID).Info("URL shortened successfully")
-    return models.ShortenResult{
-        ShortURL: fmt.Sprintf("%s/%s", s.BaseURL, shortID),
-        IsNew:    true,
-    }, nil
+	shortID, err := s.saveGenerated(ctx, originalURL, userID, passwordHash)
+	if err != nil {
+		return models.ShortenResult{}, err
+	}
+	// A redirect attempted before the ID existed may have cached it as missing.
+	s.invalidateCache(shortID)
+
+	logrus.WithField("shortID", shortID).Info("URL shortened successfully")
+	s.counters.shortened.Add(1)
+	s.audit(audit.ActionShorten, userID, shortID)
+	s.fireHook(ctx, "OnShorten", func(ctx context.Context, hooks Hooks) {
+		hooks.OnShorten(ctx, userID, shortID, originalURL)
+	})
+	return models.ShortenResult{
+		ShortURL: fmt.Sprintf("%s/%s", baseURL, shortID),
+		IsNew:    true,
+	}, nil
+}
+
+// ShortenWithAlias stores originalURL under a caller-chosen alias. Unlike
+// generated IDs it never reuses an existing short ID for the same URL, and an
+// alias taken by anyone, including a concurrent request, yields ErrAliasTaken.
+func (s *Service) ShortenWithAlias(ctx context.Context, originalURL, userID, baseURL, alias string) (models.ShortenResult, error) {
+	claimer, ok := s.saver.(models.AliasClaimer)
+	if !ok {
+		return models.ShortenResult{}, models.ErrAliasNotSupported
+	}
+	if baseURL == "" {
+		baseURL = s.BaseURL
+	}
+
+	originalURL, err := s.checkTarget(originalURL, baseURL)
+	if err != nil {
+		return models.ShortenResult{}, err
+	}
+	if err := s.checkURLLimit(ctx, userID, 1); err != nil {
+		return models.ShortenResult{}, err
+	}
+	if err := s.checkReachable(ctx, originalURL); err != nil {
+		return models.ShortenResult{}, err
+	}
+
+	shortID := tenant.Join(tenant.FromContext(ctx), alias)
+	claimed, err := claimer.ClaimAlias(ctx, shortID, originalURL, userID)
+	if err != nil {
+		logrus.WithError(err).Error("Error claiming alias")
+		return models.ShortenResult{}, fmt.Errorf("error claiming alias: %w", err)
+	}
+	if !claimed {
+		logrus.WithField("alias", shortID).Info("Alias already taken")
+		return models.ShortenResult{}, models.ErrAliasTaken
+	}
+	// An availability check may have cached the alias as missing.
+	s.invalidateCache(shortID)
+
+	logrus.WithField("shortID", shortID).Info("Alias claimed successfully")
+	s.counters.shortened.Add(1)
+	s.audit(audit.ActionShorten, userID, shortID)
+	s.fireHook(ctx, "OnShorten", func(ctx context.Context, hooks Hooks) {
+		hooks.OnShorten(ctx, userID, shortID, originalURL)
+	})
+	return models.ShortenResult{
+		ShortURL: fmt.Sprintf("%s/%s", baseURL, shortID),
+		IsNew:    true,
+	}, nil
+}
+
+// checkTarget normalizes originalURL and rejects targets that point back at
+// this service or at a disallowed domain.
+func (s *Service) checkTarget(originalURL, baseURL string) (string, error) {
+	if s.NormalizeURLs {
+		originalURL = normalizeURL(originalURL)
+	}
+
+	if s.RejectSelfLinks && s.pointsHere(originalURL, baseURL) {
+		logrus.WithField("originalURL", originalURL).Warn("Refusing to shorten a link to this service")
+		return "", models.ErrSelfLink
+	}
+
+	if !s.domainAllowed(originalURL) {
+		logrus.WithField("originalURL", originalURL).Warn("Refusing to shorten a URL on a disallowed domain")
+		return "", models.ErrDomainNotAllowed
+	}
+	return originalURL, nil
+}
+
+func (s *Service) IsDeleted(ctx context.Context, shortID string) (bool, error) {
+	checker, ok := s.saver.(models.DeletionChecker)
+	if !ok {
+		return false, nil
+	}
+	return checker.IsDeleted(ctx, shortID)
+}
+
+// ShortIDExists counts soft-deleted rows as existing, since their IDs can
+// never be saved again. Storages without owner lookup only see live rows.
+func (s *Service) ShortIDExists(ctx context.Context, shortID string) (bool, error) {
+	lookup, ok := s.saver.(models.OwnerLookup)
+	if !ok {
+		_, found := s.getter.Get(ctx, shortID)
+		return found, nil
+	}
+	_, err := lookup.OwnerOf(ctx, shortID)
+	if errors.Is(err, models.ErrURLNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check short ID: %w", err)
+	}
+	return true, nil
+}
+
+func (s *Service) CheckPassword(ctx context.Context, shortID, password string) (bool, error) {
+	protector, ok := s.saver.(models.ProtectedURLSaver)
+	if !ok {
+		return true, nil
+	}
+
+	passwordHash, err := protector.GetPasswordHash(ctx, shortID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get password hash: %w", err)
+	}
+	if passwordHash == "" {
+		return true, nil
+	}
+
+	return bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(password)) == nil, nil
 }
 
 func (s *Service) ShortenBatch(ctx context.Context, items []models.BatchShortenRequest, userID string) ([]models.BatchShortenResponse, error) {
-	batch := make(map[string]string)
+	return s.ShortenBatchWithBase(ctx, items, userID, s.BaseURL)
+}
+
+// ShortenBatchWithBase stores items under fresh short IDs and returns short
+// URLs under baseURL. When storage saved only part of the batch the error
+// wraps a *models.BatchError and the response still lists the items that
+// were saved.
+func (s *Service) ShortenBatchWithBase(ctx context.Context, items []models.BatchShortenRequest, userID, baseURL string) ([]models.BatchShortenResponse, error) {
+	if baseURL == "" {
+		baseURL = s.BaseURL
+	}
+
+	checked := make([]models.BatchShortenRequest, len(items))
+	for i, item := range items {
+		originalURL, err := s.checkTarget(item.OriginalURL, baseURL)
+		if err != nil {
+			return nil, err
+		}
+		checked[i] = models.BatchShortenRequest{CorrelationID: item.CorrelationID, OriginalURL: originalURL}
+	}
+	items = checked
+
+	if err := s.checkURLLimit(ctx, userID, len(items)); err != nil {
+		return nil, err
+	}
+
 	for _, item := range items {
-		shortID := s.generator.Generate()
+		if err := s.checkReachable(ctx, item.OriginalURL); err != nil {
+			return nil, err
+		}
+	}
+
+	batch := make(map[string]string, len(items))
+	shortIDs := make([]string, len(items))
+	for i, item := range items {
+		shortID, err := s.generateUniqueID(ctx, tenant.FromContext(ctx), batch)
+		if err != nil {
+			return nil, err
+		}
 		batch[shortID] = item.OriginalURL
+		shortIDs[i] = shortID
 	}
 
 	if err := s.batch.SaveBatch(ctx, batch, userID); err != nil {
-		return nil, fmt.Errorf("ошибка сохранения пакета URL: %w", err)
-	}
-
-	var resp []models.BatchShortenResponse
-	for shortID, originalURL := range batch {
-		for _, item := range items {
-			if item.OriginalURL == originalURL {
-				resp = append(resp, models.BatchShortenResponse{
-					CorrelationID: item.CorrelationID,
-					ShortURL:      fmt.Sprintf("%s/%s", s.BaseURL, shortID),
-				})
-				break
+		var batchErr *models.BatchError
+		if !errors.As(err, &batchErr) || len(batchErr.Saved) == 0 {
+			return nil, fmt.Errorf("ошибка сохранения пакета URL: %w", err)
+		}
+		saved := make(map[string]bool, len(batchErr.Saved))
+		for _, shortID := range batchErr.Saved {
+			saved[shortID] = true
+		}
+		s.recordBatch(ctx, userID, batch, batchErr.Saved)
+		logrus.WithError(err).WithField("saved", len(saved)).Error("Batch saved only partially")
+		return s.batchResponse(items, shortIDs, baseURL, saved), fmt.Errorf("ошибка сохранения пакета URL: %w", err)
+	}
+	s.recordBatch(ctx, userID, batch, shortIDs)
+
+	return s.batchResponse(items, shortIDs, baseURL, nil), nil
+}
+
+// recordBatch does the bookkeeping for the short IDs of batch that storage
+// saved.
+func (s *Service) recordBatch(ctx context.Context, userID string, batch map[string]string, shortIDs []string) {
+	s.invalidateCache(shortIDs...)
+
+	s.counters.shortened.Add(int64(len(shortIDs)))
+	s.audit(audit.ActionShorten, userID, shortIDs...)
+	for _, shortID := range shortIDs {
+		shortID, originalURL := shortID, batch[shortID]
+		s.fireHook(ctx, "OnShorten", func(ctx context.Context, hooks Hooks) {
+			hooks.OnShorten(ctx, userID, shortID, originalURL)
+		})
+	}
+}
+
+// batchResponse pairs items with their short URLs, keeping only the saved
+// ones when saved is not nil.
+func (s *Service) batchResponse(items []models.BatchShortenRequest, shortIDs []string, baseURL string, saved map[string]bool) []models.BatchShortenResponse {
+	resp := make([]models.BatchShortenResponse, 0, len(items))
+	for i, item := range items {
+		if saved != nil && !saved[shortIDs[i]] {
+			continue
+		}
+		resp = append(resp, models.BatchShortenResponse{
+			CorrelationID: item.CorrelationID,
+			ShortURL:      fmt.Sprintf("%s/%s", baseURL, shortIDs[i]),
+		})
+	}
+	return resp
+}
+
+func (s *Service) checkURLLimit(ctx context.Context, userID string, adding int) error {
+	if s.MaxURLsPerUser <= 0 || userID == "" {
+		return nil
+	}
+
+	var count int
+	if counter, ok := s.fetcher.(models.URLCounter); ok {
+		n, err := counter.CountByUserID(ctx, userID)
+		if err != nil {
+			return fmt.Errorf("error counting user URLs: %w", err)
+		}
+		count = n
+	} else {
+		urls, err := s.fetcher.GetURLsByUserID(ctx, userID)
+		if err != nil {
+			return fmt.Errorf("error counting user URLs: %w", err)
+		}
+		count = len(urls)
+	}
+
+	if count+adding > s.MaxURLsPerUser {
+		logrus.WithFields(logrus.Fields{
+			"userID": userID,
+			"count":  count,
+			"adding": adding,
+		}).Warn("User URL limit exceeded")
+		return models.ErrURLLimitExceeded
+	}
+	return nil
+}
+
+func (s *Service) checkReachable(ctx context.Context, originalURL string) error {
+	if s.Reachability == nil {
+		return nil
+	}
+	if err := s.Reachability.Check(ctx, originalURL); err != nil {
+		logrus.WithError(err).WithField("originalURL", originalURL).Warn("Refusing to shorten an unreachable URL")
+		return err
+	}
+	return nil
+}
+
+// generate returns a fresh short ID, going through the context-aware path of
+// generators backed by storage. IDs that collide with a route are skipped.
+func (s *Service) generate(ctx context.Context) (string, error) {
+	for attempt := 0; attempt < maxGenerateAttempts; attempt++ {
+		var shortID string
+		if g, ok := s.generator.(generator.ContextGenerator); ok {
+			var err error
+			if shortID, err = g.GenerateContext(ctx); err != nil {
+				return "", fmt.Errorf("failed to generate short ID: %w", err)
 			}
+		} else {
+			shortID = s.generator.Generate()
+		}
+		if shortID == "" {
+			logrus.Error("Generated short ID is empty")
+			return "", fmt.Errorf("failed to generate short ID")
+		}
+		if !generator.Reserved(shortID) {
+			return shortID, nil
 		}
+		logrus.WithField("shortID", shortID).Debug("Skipping reserved short ID")
 	}
-	return resp, nil
+	return "", fmt.Errorf("failed to generate unreserved short ID after %d attempts", maxGenerateAttempts)
+}
+
+// saveGenerated stores originalURL under a fresh short ID. The insert checks
+// for an existing row, so an ID already in use, e.g. an alias a counter has
+// caught up with, is skipped for the next one rather than overwritten.
+func (s *Service) saveGenerated(ctx context.Context, originalURL, userID, passwordHash string) (string, error) {
+	claimer, canClaim := s.saver.(models.AliasClaimer)
+	for attempt := 0; attempt < maxGenerateAttempts; attempt++ {
+		generated, err := s.generate(ctx)
+		if err != nil {
+			return "", err
+		}
+		shortID := tenant.Join(tenant.FromContext(ctx), generated)
+
+		saved := true
+		switch {
+		case passwordHash != "":
+			err = s.saver.(models.ProtectedURLSaver).SaveWithPassword(ctx, shortID, originalURL, userID, passwordHash)
+			if errors.Is(err, models.ErrShortIDTaken) {
+				saved, err = false, nil
+			}
+		case canClaim:
+			saved, err = claimer.ClaimAlias(ctx, shortID, originalURL, userID)
+		default:
+			err = s.saver.Save(ctx, shortID, originalURL, userID)
+		}
+		if err != nil {
+			logrus.WithError(err).Error("Error saving URL")
+			return "", fmt.Errorf("error saving URL: %w", err)
+		}
+		if saved {
+			return shortID, nil
+		}
+		logrus.WithField("shortID", shortID).Warn("Generated short ID is taken, regenerating")
+	}
+	return "", fmt.Errorf("failed to generate unique short ID after %d attempts", maxGenerateAttempts)
+}
+
+// generateUniqueID returns a short ID unused in taken and, where storage can
+// tell, unused in storage, deleted rows included.
+func (s *Service) generateUniqueID(ctx context.Context, tenantName string, taken map[string]string) (string, error) {
+	lookup, canLookup := s.saver.(models.OwnerLookup)
+	for attempt := 0; attempt < maxGenerateAttempts; attempt++ {
+		generated, err := s.generate(ctx)
+		if err != nil {
+			return "", err
+		}
+		shortID := tenant.Join(tenantName, generated)
+		if _, exists := taken[shortID]; exists {
+			logrus.WithField("shortID", shortID).Warn("Duplicate short ID generated in batch, regenerating")
+			continue
+		}
+		if canLookup {
+			_, err := lookup.OwnerOf(ctx, shortID)
+			if err == nil {
+				logrus.WithField("shortID", shortID).Warn("Generated short ID is taken, regenerating")
+				continue
+			}
+			if !errors.Is(err, models.ErrURLNotFound) {
+				return "", fmt.Errorf("failed to check short ID: %w", err)
+			}
+		}
+		return shortID, nil
+	}
+	return "", fmt.Errorf("failed to generate unique short ID after %d attempts", maxGenerateAttempts)
 }
 
 func (s *Service) Get(ctx context.Context, shortID string) (string, bool) {
 	return s.getter.Get(ctx, shortID)
 }
 
+func (s *Service) RecordHit(ctx context.Context, shortID string) error {
+	s.counters.redirects.Add(1)
+	s.fireHook(ctx, "OnRedirect", func(ctx context.Context, hooks Hooks) {
+		hooks.OnRedirect(ctx, shortID)
+	})
+
+	recorder, ok := s.saver.(models.HitRecorder)
+	if !ok {
+		return nil
+	}
+	return recorder.RecordHit(ctx, shortID)
+}
+
+// GetBatch resolves shortIDs, leaving out password-protected ones as if they
+// did not exist, since a batch lookup cannot carry their passwords.
+func (s *Service) GetBatch(ctx context.Context, shortIDs []string) (map[string]string, error) {
+	urls, err := s.getter.GetBatch(ctx, shortIDs)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения пакета URL: %w", err)
+	}
+
+	protector, ok := s.saver.(models.ProtectedURLSaver)
+	if !ok {
+		return urls, nil
+	}
+	for shortID := range urls {
+		passwordHash, err := protector.GetPasswordHash(ctx, shortID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get password hash: %w", err)
+		}
+		if passwordHash != "" {
+			delete(urls, shortID)
+		}
+	}
+	return urls, nil
+}
+
+// GetURLsByUserID returns the URLs of userID with full short URLs. The
+// slice is built fresh from storage on every call and its tags are cloned,
+// so callers may modify the result freely.
 func (s *Service) GetURLsByUserID(ctx context.Context, userID string) ([]models.UserURL, error) {
 	urls, err := s.fetcher.GetURLsByUserID(ctx, userID)
 	if err != nil {
@@ -107,19 +535,291 @@ func (s *Service) GetURLsByUserID(ctx context.Context, userID string) ([]models.
 	}
 	for i := range urls {
 		urls[i].ShortURL = fmt.Sprintf("%s/%s", s.BaseURL, urls[i].ShortURL)
+		urls[i].PasswordHash = ""
+		// In-memory storages hand out their own tag slices.
+		urls[i].Tags = slices.Clone(urls[i].Tags)
 	}
 	return urls, nil
 }
 
+// GetURLsByUserIDStream passes the URLs of userID to fn as storage reads
+// them. Storages that cannot stream are listed in full first.
+func (s *Service) GetURLsByUserIDStream(ctx context.Context, userID string, fn func(models.UserURL) error) error {
+	streamer, ok := s.fetcher.(models.UserURLStreamer)
+	if !ok {
+		urls, err := s.GetURLsByUserID(ctx, userID)
+		if err != nil {
+			return err
+		}
+		for _, url := range urls {
+			if err := fn(url); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	err := streamer.GetURLsByUserIDStream(ctx, userID, func(url models.UserURL) error {
+		url.ShortURL = fmt.Sprintf("%s/%s", s.BaseURL, url.ShortURL)
+		url.PasswordHash = ""
+		return fn(url)
+	})
+	if err != nil {
+		return fmt.Errorf("ошибка получения URL пользователя: %w", err)
+	}
+	return nil
+}
+
+func (s *Service) GetShortURLsForOriginals(ctx context.Context, urls []string, userID string) map[string]string {
+	result := make(map[string]string)
+	if len(urls) == 0 {
+		return result
+	}
+
+	userURLs, err := s.fetcher.GetURLsByUserID(ctx, userID)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to get user URLs for lookup")
+		return result
+	}
+
+	shortIDs := make(map[string]string, len(userURLs))
+	for _, u := range userURLs {
+		if _, ok := shortIDs[u.OriginalURL]; !ok {
+			shortIDs[u.OriginalURL] = u.ShortURL
+		}
+	}
+
+	for _, original := range urls {
+		key := original
+		if s.NormalizeURLs {
+			key = normalizeURL(original)
+		}
+		if shortID, ok := shortIDs[key]; ok {
+			result[original] = fmt.Sprintf("%s/%s", s.BaseURL, shortID)
+		}
+	}
+	return result
+}
+
+func (s *Service) LookupURL(ctx context.Context, originalURL, userID string) (string, bool, error) {
+	if s.LookupPerUser {
+		shortURL, ok := s.GetShortURLsForOriginals(ctx, []string{originalURL}, userID)[originalURL]
+		return shortURL, ok, nil
+	}
+
+	if s.NormalizeURLs {
+		originalURL = normalizeURL(originalURL)
+	}
+	shortID, err := s.saver.FindByOriginalURL(ctx, originalURL, tenant.FromContext(ctx))
+	if err != nil {
+		return "", false, fmt.Errorf("error finding URL: %w", err)
+	}
+	if shortID == "" {
+		return "", false, nil
+	}
+	return fmt.Sprintf("%s/%s", s.BaseURL, shortID), true, nil
+}
+
 func (s *Service) DeleteURLs(ctx context.Context, shortIDs []string, userID string) error {
 	err := s.deleter.DeleteURLs(ctx, shortIDs, userID)
     if err != nil {
         logrus.WithError(err).Error("Failed to delete URLs")
         return err
     }
+	s.invalidateCache(shortIDs...)
+	s.counters.deleted.Add(int64(len(shortIDs)))
+	s.audit(audit.ActionDelete, userID, shortIDs...)
+	s.fireHook(ctx, "OnDelete", func(ctx context.Context, hooks Hooks) {
+		hooks.OnDelete(ctx, userID, shortIDs)
+	})
     return nil
 }
 
+func (s *Service) RestoreURLs(ctx context.Context, shortIDs []string, userID string) error {
+	if err := s.deleter.RestoreURLs(ctx, shortIDs, userID); err != nil {
+		logrus.WithError(err).Error("Failed to restore URLs")
+		return err
+	}
+	s.invalidateCache(shortIDs...)
+	s.audit(audit.ActionRestore, userID, shortIDs...)
+	return nil
+}
+
+// SetDeleted soft-deletes or restores a single short ID after checking that
+// userID owns it.
+func (s *Service) SetDeleted(ctx context.Context, shortID string, deleted bool, userID string) error {
+	lookup, ok := s.saver.(models.OwnerLookup)
+	if !ok {
+		return models.ErrUpdateNotSupported
+	}
+	owner, err := lookup.OwnerOf(ctx, shortID)
+	if err != nil {
+		return err
+	}
+	if owner != userID {
+		return models.ErrURLNotOwned
+	}
+
+	if deleted {
+		return s.DeleteURLs(ctx, []string{shortID}, userID)
+	}
+	return s.RestoreURLs(ctx, []string{shortID}, userID)
+}
+
+func (s *Service) UpdateURL(ctx context.Context, shortID, newURL, userID string) error {
+	updater, ok := s.saver.(models.URLUpdater)
+	if !ok {
+		return models.ErrUpdateNotSupported
+	}
+
+	if s.NormalizeURLs {
+		newURL = normalizeURL(newURL)
+	}
+	if s.RejectSelfLinks && s.pointsHere(newURL) {
+		logrus.WithField("originalURL", newURL).Warn("Refusing to point a link at this service")
+		return models.ErrSelfLink
+	}
+	if !s.domainAllowed(newURL) {
+		logrus.WithField("originalURL", newURL).Warn("Refusing to point a link at a disallowed domain")
+		return models.ErrDomainNotAllowed
+	}
+	if err := s.checkReachable(ctx, newURL); err != nil {
+		return err
+	}
+
+	if err := updater.UpdateURL(ctx, shortID, newURL, userID); err != nil {
+		return err
+	}
+	s.invalidateCache(shortID)
+	s.audit(audit.ActionUpdate, userID, shortID)
+	logrus.WithFields(logrus.Fields{"shortID": shortID, "userID": userID}).Info("URL target updated")
+	return nil
+}
+
+func (s *Service) PurgeURLs(ctx context.Context, shortIDs []string, userID string) error {
+	purger, ok := s.deleter.(models.URLPurger)
+	if !ok {
+		return fmt.Errorf("storage does not support purging URLs")
+	}
+	if err := purger.PurgeURLs(ctx, shortIDs, userID); err != nil {
+		logrus.WithError(err).Error("Failed to purge URLs")
+		return err
+	}
+	s.invalidateCache(shortIDs...)
+	s.audit(audit.ActionPurge, userID, shortIDs...)
+	return nil
+}
+
+func (s *Service) Export(ctx context.Context) ([]models.UserURL, error) {
+	store, ok := s.fetcher.(models.BackupStore)
+	if !ok {
+		return nil, models.ErrBackupNotSupported
+	}
+	urls, err := store.Export(ctx)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to export URLs")
+		return nil, err
+	}
+	return urls, nil
+}
+
+func (s *Service) Import(ctx context.Context, urls []models.UserURL) error {
+	store, ok := s.saver.(models.BackupStore)
+	if !ok {
+		return models.ErrBackupNotSupported
+	}
+	if err := store.Import(ctx, urls); err != nil {
+		logrus.WithError(err).Error("Failed to import URLs")
+		return err
+	}
+	shortIDs := make([]string, 0, len(urls))
+	for _, url := range urls {
+		shortIDs = append(shortIDs, url.ShortURL)
+	}
+	s.invalidateCache(shortIDs...)
+	logrus.WithField("count", len(urls)).Info("Imported URLs")
+	return nil
+}
+
+func (s *Service) audit(action, userID string, shortIDs ...string) {
+	if s.Audit == nil {
+		return
+	}
+	now := s.Clock.Now()
+	for _, shortID := range shortIDs {
+		s.Audit.Log(audit.Entry{Time: now, Action: action, UserID: userID, ShortID: shortID})
+	}
+}
+
+func (s *Service) Now() time.Time {
+	return s.Clock.Now()
+}
+
+func (s *Service) ClockSource() string {
+	if s.Clock == clock.System {
+		return "system"
+	}
+	return "injected"
+}
+
 func (s *Service) Ping(ctx context.Context) error {
 	return s.pinger.Ping(ctx)
-}
\ No newline at end of file
+}
+
+func (s *Service) PendingWrites() int {
+	if backlog, ok := s.pinger.(models.WriteBacklog); ok {
+		return backlog.PendingWrites()
+	}
+	return 0
+}
+
+func (s *Service) LastSaveError() error {
+	if backlog, ok := s.pinger.(models.WriteBacklog); ok {
+		return backlog.LastSaveError()
+	}
+	return nil
+}
+
+// pointsHere reports whether rawURL is a link to BaseURL, one of BaseURLs or
+// one of extra.
+func (s *Service) pointsHere(rawURL string, extra ...string) bool {
+	for _, bases := range [][]string{{s.BaseURL}, s.BaseURLs, extra} {
+		for _, base := range bases {
+			if s.isSelfLink(rawURL, base) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *Service) isSelfLink(rawURL, baseURL string) bool {
+	target, err := url.Parse(rawURL)
+	if err != nil || target.Host == "" {
+		return false
+	}
+	base, err := url.Parse(baseURL)
+	if err != nil || base.Host == "" {
+		return false
+	}
+	if !strings.EqualFold(target.Hostname(), base.Hostname()) || effectivePort(target) != effectivePort(base) {
+		return false
+	}
+	// Only paths under the base path can reach a short code; other paths on a
+	// shared host belong to someone else.
+	basePath := strings.TrimSuffix(base.Path, "/")
+	return basePath == "" || target.Path == basePath || strings.HasPrefix(target.Path, basePath+"/")
+}
+
+func effectivePort(u *url.URL) string {
+	if port := u.Port(); port != "" {
+		return port
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "https":
+		return "443"
+	case "http":
+		return "80"
+	}
+	return ""
+}
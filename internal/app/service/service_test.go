@@ -0,0 +1,678 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AlenaMolokova/http/internal/app/audit"
+	"github.com/AlenaMolokova/http/internal/app/clock"
+	"github.com/AlenaMolokova/http/internal/app/generator"
+	"github.com/AlenaMolokova/http/internal/app/models"
+	"github.com/AlenaMolokova/http/internal/app/storage/memory"
+	"github.com/AlenaMolokova/http/internal/app/tenant"
+)
+
+func newTestService() (*Service, *memory.MemoryStorage) {
+	store := memory.NewMemoryStorage()
+	svc := NewService(store, store, store, store, store, store, generator.NewGenerator(8), "http://localhost:8080")
+	return svc, store
+}
+
+func TestShortenURLRejectsSelfLinks(t *testing.T) {
+	svc, _ := newTestService()
+	svc.RejectSelfLinks = true
+
+	for _, target := range []string{
+		"http://localhost:8080/abc",
+		"http://LOCALHOST:8080/",
+	} {
+		if _, err := svc.ShortenURL(context.Background(), target, "user"); !errors.Is(err, models.ErrSelfLink) {
+			t.Errorf("Expected ErrSelfLink for %s, got %v", target, err)
+		}
+	}
+
+	if _, err := svc.ShortenURL(context.Background(), "http://localhost:9090/abc", "user"); err != nil {
+		t.Errorf("Expected URL on another port to be accepted, got %v", err)
+	}
+	if _, err := svc.ShortenURL(context.Background(), "https://example.com", "user"); err != nil {
+		t.Errorf("Expected external URL to be accepted, got %v", err)
+	}
+}
+
+func TestShortenBatchChecksTargetsAgainstRequestBase(t *testing.T) {
+	svc, _ := newTestService()
+	svc.RejectSelfLinks = true
+	svc.NormalizeURLs = true
+	ctx := context.Background()
+
+	items := []models.BatchShortenRequest{{CorrelationID: "1", OriginalURL: "https://ex.am/abc"}}
+	if _, err := svc.ShortenBatchWithBase(ctx, items, "user", "https://ex.am"); !errors.Is(err, models.ErrSelfLink) {
+		t.Fatalf("Expected ErrSelfLink for a link to the request base, got %v", err)
+	}
+
+	items = []models.BatchShortenRequest{{CorrelationID: "1", OriginalURL: "HTTPS://Example.com/Page"}}
+	resp, err := svc.ShortenBatchWithBase(ctx, items, "user", "https://ex.am")
+	if err != nil {
+		t.Fatalf("ShortenBatchWithBase failed: %v", err)
+	}
+	if len(resp) != 1 || !strings.HasPrefix(resp[0].ShortURL, "https://ex.am/") {
+		t.Fatalf("Expected a short URL under https://ex.am, got %v", resp)
+	}
+	found, ok, err := svc.LookupURL(ctx, "https://example.com/Page", "user")
+	if err != nil || !ok {
+		t.Fatalf("Expected the normalized URL to be stored, got %q, %v, %v", found, ok, err)
+	}
+}
+
+func TestSelfLinksCoverConfiguredBaseURLs(t *testing.T) {
+	svc, _ := newTestService()
+	svc.RejectSelfLinks = true
+	svc.BaseURLs = []string{"https://ex.am"}
+	ctx := context.Background()
+
+	if _, err := svc.ShortenURL(ctx, "https://ex.am/abc", "user"); !errors.Is(err, models.ErrSelfLink) {
+		t.Errorf("Expected ErrSelfLink when shortening a link to another base, got %v", err)
+	}
+
+	result, err := svc.ShortenURL(ctx, "https://example.com", "user")
+	if err != nil {
+		t.Fatalf("ShortenURL failed: %v", err)
+	}
+	shortID := strings.TrimPrefix(result.ShortURL, svc.BaseURL+"/")
+	for _, target := range []string{"http://localhost:8080/abc", "https://ex.am/abc"} {
+		if err := svc.UpdateURL(ctx, shortID, target, "user"); !errors.Is(err, models.ErrSelfLink) {
+			t.Errorf("Expected ErrSelfLink when updating to %s, got %v", target, err)
+		}
+	}
+}
+
+func TestShortenURLRejectsSelfLinksUnderBasePath(t *testing.T) {
+	store := memory.NewMemoryStorage()
+	svc := NewService(store, store, store, store, store, store, generator.NewGenerator(8), "http://localhost:8080/s")
+	svc.RejectSelfLinks = true
+
+	for _, target := range []string{
+		"http://localhost:8080/s",
+		"http://localhost:8080/s/abc123",
+	} {
+		if _, err := svc.ShortenURL(context.Background(), target, "user"); !errors.Is(err, models.ErrSelfLink) {
+			t.Errorf("Expected ErrSelfLink for %s, got %v", target, err)
+		}
+	}
+
+	for _, target := range []string{"http://localhost:8080/docs", "http://localhost:8080/short", "http://localhost:80/s/abc123"} {
+		if _, err := svc.ShortenURL(context.Background(), target, "user"); err != nil {
+			t.Errorf("Expected %s to be accepted, got %v", target, err)
+		}
+	}
+}
+
+func TestShortenBatchRejectsSelfLinks(t *testing.T) {
+	svc, store := newTestService()
+	svc.RejectSelfLinks = true
+
+	_, err := svc.ShortenBatch(context.Background(), []models.BatchShortenRequest{
+		{CorrelationID: "1", OriginalURL: "https://example.com"},
+		{CorrelationID: "2", OriginalURL: "http://localhost:8080/abc123"},
+	}, "user")
+	if !errors.Is(err, models.ErrSelfLink) {
+		t.Fatalf("Expected ErrSelfLink, got %v", err)
+	}
+	if id, _ := store.FindByOriginalURL(context.Background(), "https://example.com", ""); id != "" {
+		t.Errorf("Expected no URL from a rejected batch to be saved, got %q", id)
+	}
+}
+
+func TestShortenURLAllowsSelfLinksWhenDisabled(t *testing.T) {
+	svc, _ := newTestService()
+
+	result, err := svc.ShortenURL(context.Background(), "http://localhost:8080/abc", "user")
+	if err != nil {
+		t.Fatalf("Expected self link to be accepted, got %v", err)
+	}
+	if !result.IsNew {
+		t.Error("Expected a new short URL")
+	}
+}
+
+func TestShortenURLWithBase(t *testing.T) {
+	svc, _ := newTestService()
+
+	result, err := svc.ShortenURLWithBase(context.Background(), "https://example.com", "user", "https://sho.rt")
+	if err != nil {
+		t.Fatalf("Failed to shorten URL: %v", err)
+	}
+	if !strings.HasPrefix(result.ShortURL, "https://sho.rt/") {
+		t.Errorf("Expected override base, got %s", result.ShortURL)
+	}
+
+	result, err = svc.ShortenURLWithBase(context.Background(), "https://example.com", "user", "")
+	if err != nil {
+		t.Fatalf("Failed to shorten URL: %v", err)
+	}
+	if !strings.HasPrefix(result.ShortURL, "http://localhost:8080/") {
+		t.Errorf("Expected configured base for empty override, got %s", result.ShortURL)
+	}
+}
+
+func TestShortenProtectedURLStoresOnlyHash(t *testing.T) {
+	svc, store := newTestService()
+	ctx := context.Background()
+
+	if _, err := svc.ShortenURL(ctx, "https://example.com", "user"); err != nil {
+		t.Fatalf("Failed to shorten URL: %v", err)
+	}
+	result, err := svc.ShortenProtectedURL(ctx, "https://example.com", "user", "", "s3cret")
+	if err != nil {
+		t.Fatalf("Failed to shorten protected URL: %v", err)
+	}
+	if !result.IsNew {
+		t.Error("Expected protected URL to get its own short ID")
+	}
+
+	shortID := result.ShortURL[strings.LastIndex(result.ShortURL, "/")+1:]
+	hash, err := store.GetPasswordHash(ctx, shortID)
+	if err != nil {
+		t.Fatalf("Failed to read hash: %v", err)
+	}
+	if hash == "" || hash == "s3cret" {
+		t.Errorf("Expected a password hash to be stored, got %q", hash)
+	}
+
+	if ok, _ := svc.CheckPassword(ctx, shortID, "s3cret"); !ok {
+		t.Error("Expected correct password to be accepted")
+	}
+	if ok, _ := svc.CheckPassword(ctx, shortID, "nope"); ok {
+		t.Error("Expected wrong password to be rejected")
+	}
+
+	urls, err := svc.GetURLsByUserID(ctx, "user")
+	if err != nil {
+		t.Fatalf("Failed to list URLs: %v", err)
+	}
+	for _, u := range urls {
+		if u.PasswordHash != "" {
+			t.Errorf("Expected password hash to be hidden from listing for %s", u.ShortURL)
+		}
+	}
+}
+
+func TestGetShortURLsForOriginals(t *testing.T) {
+	svc, _ := newTestService()
+	ctx := context.Background()
+
+	mine, err := svc.ShortenURL(ctx, "https://mine.example.com", "user1")
+	if err != nil {
+		t.Fatalf("Failed to shorten URL: %v", err)
+	}
+	if _, err := svc.ShortenURL(ctx, "https://theirs.example.com", "user2"); err != nil {
+		t.Fatalf("Failed to shorten URL: %v", err)
+	}
+
+	got := svc.GetShortURLsForOriginals(ctx, []string{
+		"https://mine.example.com",
+		"https://theirs.example.com",
+		"https://unknown.example.com",
+	}, "user1")
+
+	if len(got) != 1 {
+		t.Fatalf("Expected exactly 1 mapping, got %v", got)
+	}
+	if got["https://mine.example.com"] != mine.ShortURL {
+		t.Errorf("Expected %s, got %s", mine.ShortURL, got["https://mine.example.com"])
+	}
+
+	if got := svc.GetShortURLsForOriginals(ctx, []string{"https://mine.example.com"}, "nobody"); len(got) != 0 {
+		t.Errorf("Expected no mappings for a user without URLs, got %v", got)
+	}
+}
+
+type sequenceGenerator struct {
+	ids []string
+	pos int
+}
+
+func (g *sequenceGenerator) Generate() string {
+	id := g.ids[g.pos%len(g.ids)]
+	g.pos++
+	return id
+}
+
+func TestShortenBatchRegeneratesDuplicateIDs(t *testing.T) {
+	store := memory.NewMemoryStorage()
+	gen := &sequenceGenerator{ids: []string{"aaa", "aaa", "bbb", "ccc"}}
+	svc := NewService(store, store, store, store, store, store, gen, "http://localhost:8080")
+
+	items := []models.BatchShortenRequest{
+		{CorrelationID: "1", OriginalURL: "https://one.example.com"},
+		{CorrelationID: "2", OriginalURL: "https://two.example.com"},
+		{CorrelationID: "3", OriginalURL: "https://three.example.com"},
+	}
+
+	resp, err := svc.ShortenBatch(context.Background(), items, "user")
+	if err != nil {
+		t.Fatalf("ShortenBatch failed: %v", err)
+	}
+	if len(resp) != len(items) {
+		t.Fatalf("Expected %d results, got %d", len(items), len(resp))
+	}
+
+	seen := make(map[string]bool)
+	for i, r := range resp {
+		if r.CorrelationID != items[i].CorrelationID {
+			t.Errorf("Expected correlation ID %s, got %s", items[i].CorrelationID, r.CorrelationID)
+		}
+		shortID := strings.TrimPrefix(r.ShortURL, "http://localhost:8080/")
+		if seen[shortID] {
+			t.Errorf("Short ID %s assigned twice", shortID)
+		}
+		seen[shortID] = true
+
+		got, ok := store.Get(context.Background(), shortID)
+		if !ok || got != items[i].OriginalURL {
+			t.Errorf("Expected %s to resolve to %s, got %q", shortID, items[i].OriginalURL, got)
+		}
+	}
+}
+
+// partialBatchSaver saves the first keep IDs of a batch in sorted order and
+// then fails like a chunked SQL batch.
+type partialBatchSaver struct {
+	models.URLBatchSaver
+	keep int
+}
+
+func (p partialBatchSaver) SaveBatch(ctx context.Context, batch map[string]string, userID string) error {
+	shortIDs := make([]string, 0, len(batch))
+	for shortID := range batch {
+		shortIDs = append(shortIDs, shortID)
+	}
+	sort.Strings(shortIDs)
+
+	saved := make(map[string]string, p.keep)
+	for _, shortID := range shortIDs[:p.keep] {
+		saved[shortID] = batch[shortID]
+	}
+	if err := p.URLBatchSaver.SaveBatch(ctx, saved, userID); err != nil {
+		return err
+	}
+	return &models.BatchError{Saved: shortIDs[:p.keep], Err: errors.New("connection lost")}
+}
+
+func TestShortenBatchReportsPartialSave(t *testing.T) {
+	store := memory.NewMemoryStorage()
+	gen := &sequenceGenerator{ids: []string{"ccc", "aaa", "bbb"}}
+	svc := NewService(store, partialBatchSaver{URLBatchSaver: store, keep: 2}, store, store, store, store, gen, "http://localhost:8080")
+	ctx := context.Background()
+
+	if _, found := svc.Get(ctx, "aaa"); found {
+		t.Fatal("Expected aaa to be missing before the batch")
+	}
+
+	items := []models.BatchShortenRequest{
+		{CorrelationID: "1", OriginalURL: "https://one.example.com"},
+		{CorrelationID: "2", OriginalURL: "https://two.example.com"},
+		{CorrelationID: "3", OriginalURL: "https://three.example.com"},
+	}
+	resp, err := svc.ShortenBatch(ctx, items, "user")
+	var batchErr *models.BatchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("Expected a *models.BatchError, got %v", err)
+	}
+
+	want := []models.BatchShortenResponse{
+		{CorrelationID: "2", ShortURL: "http://localhost:8080/aaa"},
+		{CorrelationID: "3", ShortURL: "http://localhost:8080/bbb"},
+	}
+	if fmt.Sprint(resp) != fmt.Sprint(want) {
+		t.Errorf("Expected the saved items %v, got %v", want, resp)
+	}
+	if got := svc.Counters().Shortened; got != 2 {
+		t.Errorf("Expected 2 shortened URLs to be counted, got %d", got)
+	}
+	if got, _ := svc.Get(ctx, "aaa"); got != "https://two.example.com" {
+		t.Errorf("Expected aaa to resolve to the second URL, got %q", got)
+	}
+	if _, found := svc.Get(ctx, "ccc"); found {
+		t.Error("Expected the unsaved ID to stay missing")
+	}
+}
+
+func TestShortenBatchFailsWhenGeneratorIsStuck(t *testing.T) {
+	store := memory.NewMemoryStorage()
+	svc := NewService(store, store, store, store, store, store, &sequenceGenerator{ids: []string{"same"}}, "http://localhost:8080")
+
+	items := []models.BatchShortenRequest{
+		{CorrelationID: "1", OriginalURL: "https://one.example.com"},
+		{CorrelationID: "2", OriginalURL: "https://two.example.com"},
+	}
+	if _, err := svc.ShortenBatch(context.Background(), items, "user"); err == nil {
+		t.Fatal("Expected an error when the generator keeps returning the same ID")
+	}
+	if _, ok := store.Get(context.Background(), "same"); ok {
+		t.Error("Expected nothing to be saved")
+	}
+}
+
+func TestCounterIDsSkipAliasesAndReservedNames(t *testing.T) {
+	store := memory.NewMemoryStorage()
+	gen := generator.NewCounterGenerator(&generator.MemorySequence{}, "")
+	svc := NewService(store, store, store, store, store, store, gen, "http://localhost:8080")
+	ctx := context.Background()
+
+	for _, alias := range []string{"b", "c", "e"} {
+		if _, err := svc.ShortenWithAlias(ctx, "https://alias.example.com/"+alias, "user", "", alias); err != nil {
+			t.Fatalf("ShortenWithAlias(%s) failed: %v", alias, err)
+		}
+	}
+
+	result, err := svc.ShortenURL(ctx, "https://counter.example.com", "user")
+	if err != nil {
+		t.Fatalf("ShortenURL failed: %v", err)
+	}
+	if result.ShortURL != "http://localhost:8080/d" {
+		t.Errorf("Expected the first free counter ID d, got %s", result.ShortURL)
+	}
+
+	result, err = svc.ShortenProtectedURL(ctx, "https://protected.example.com", "user", "", "secret")
+	if err != nil {
+		t.Fatalf("ShortenProtectedURL failed: %v", err)
+	}
+	if result.ShortURL != "http://localhost:8080/f" {
+		t.Errorf("Expected the protected URL to skip alias e, got %s", result.ShortURL)
+	}
+
+	resp, err := svc.ShortenBatch(ctx, []models.BatchShortenRequest{{CorrelationID: "1", OriginalURL: "https://batch.example.com"}}, "user")
+	if err != nil {
+		t.Fatalf("ShortenBatch failed: %v", err)
+	}
+	if resp[0].ShortURL != "http://localhost:8080/g" {
+		t.Errorf("Expected the batch to get g, got %s", resp[0].ShortURL)
+	}
+
+	for _, alias := range []string{"b", "c", "e"} {
+		if got, _ := store.Get(ctx, alias); got != "https://alias.example.com/"+alias {
+			t.Errorf("Expected alias %s to keep its URL, got %q", alias, got)
+		}
+	}
+}
+
+func TestShortenSkipsReservedIDs(t *testing.T) {
+	store := memory.NewMemoryStorage()
+	gen := &sequenceGenerator{ids: []string{"api", "healthz", "ok1"}}
+	svc := NewService(store, store, store, store, store, store, gen, "http://localhost:8080")
+
+	result, err := svc.ShortenURL(context.Background(), "https://example.com", "user")
+	if err != nil {
+		t.Fatalf("ShortenURL failed: %v", err)
+	}
+	if result.ShortURL != "http://localhost:8080/ok1" {
+		t.Errorf("Expected reserved IDs to be skipped, got %s", result.ShortURL)
+	}
+}
+
+func TestShortenDeduplicatesWithinTenant(t *testing.T) {
+	svc, _ := newTestService()
+	const original = "https://shared.example.com"
+
+	first := make(map[string]string)
+	for _, name := range []string{"", "acme", "globex"} {
+		result, err := svc.ShortenURL(tenant.WithTenant(context.Background(), name), original, "user")
+		if err != nil {
+			t.Fatalf("ShortenURL for tenant %q failed: %v", name, err)
+		}
+		if !result.IsNew {
+			t.Fatalf("Expected tenant %q to get its own short URL, got existing %s", name, result.ShortURL)
+		}
+		first[name] = result.ShortURL
+	}
+
+	// Several rounds, since the memory index iterates in random order.
+	for i := 0; i < 10; i++ {
+		for name, want := range first {
+			ctx := tenant.WithTenant(context.Background(), name)
+			result, err := svc.ShortenURL(ctx, original, "user")
+			if err != nil {
+				t.Fatalf("ShortenURL for tenant %q failed: %v", name, err)
+			}
+			if result.IsNew || result.ShortURL != want {
+				t.Fatalf("Expected tenant %q to get %s back, got %s (new=%v)", name, want, result.ShortURL, result.IsNew)
+			}
+			if got, found, _ := svc.LookupURL(ctx, original, "user"); !found || got != want {
+				t.Fatalf("Expected lookup for tenant %q to find %s, got %q", name, want, got)
+			}
+		}
+	}
+}
+
+func TestShortenURLEnforcesPerUserLimit(t *testing.T) {
+	svc, _ := newTestService()
+	svc.MaxURLsPerUser = 2
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := svc.ShortenURL(ctx, fmt.Sprintf("https://example.com/%d", i), "user"); err != nil {
+			t.Fatalf("Shorten %d failed: %v", i, err)
+		}
+	}
+
+	if _, err := svc.ShortenURL(ctx, "https://example.com/extra", "user"); !errors.Is(err, models.ErrURLLimitExceeded) {
+		t.Fatalf("Expected ErrURLLimitExceeded, got %v", err)
+	}
+	if _, err := svc.ShortenURL(ctx, "https://example.com/0", "user"); err != nil {
+		t.Errorf("Expected an already shortened URL to be returned, got %v", err)
+	}
+	if _, err := svc.ShortenURL(ctx, "https://example.com/extra", "other"); err != nil {
+		t.Errorf("Expected another user to be unaffected, got %v", err)
+	}
+}
+
+func TestShortenBatchRejectsCrossingLimitAtomically(t *testing.T) {
+	svc, store := newTestService()
+	svc.MaxURLsPerUser = 3
+	ctx := context.Background()
+
+	if _, err := svc.ShortenURL(ctx, "https://example.com/first", "user"); err != nil {
+		t.Fatalf("Shorten failed: %v", err)
+	}
+
+	items := []models.BatchShortenRequest{
+		{CorrelationID: "1", OriginalURL: "https://example.com/1"},
+		{CorrelationID: "2", OriginalURL: "https://example.com/2"},
+		{CorrelationID: "3", OriginalURL: "https://example.com/3"},
+	}
+	if _, err := svc.ShortenBatch(ctx, items, "user"); !errors.Is(err, models.ErrURLLimitExceeded) {
+		t.Fatalf("Expected ErrURLLimitExceeded, got %v", err)
+	}
+
+	count, err := store.CountByUserID(ctx, "user")
+	if err != nil {
+		t.Fatalf("CountByUserID failed: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected no batch item to be saved, user has %d URLs", count)
+	}
+
+	if _, err := svc.ShortenBatch(ctx, items[:2], "user"); err != nil {
+		t.Errorf("Expected batch within the limit to succeed, got %v", err)
+	}
+}
+
+func TestLookupURLPerUserScoping(t *testing.T) {
+	svc, _ := newTestService()
+	ctx := context.Background()
+
+	result, err := svc.ShortenURL(ctx, "https://scoped.example.com", "owner")
+	if err != nil {
+		t.Fatalf("Shorten failed: %v", err)
+	}
+
+	if got, found, err := svc.LookupURL(ctx, "https://scoped.example.com", "stranger"); err != nil || !found || got != result.ShortURL {
+		t.Errorf("Expected global lookup to find %s, got %q, %v, %v", result.ShortURL, got, found, err)
+	}
+
+	svc.LookupPerUser = true
+	if _, found, _ := svc.LookupURL(ctx, "https://scoped.example.com", "stranger"); found {
+		t.Error("Expected per-user lookup to hide another user's URL")
+	}
+	if got, found, _ := svc.LookupURL(ctx, "https://scoped.example.com", "owner"); !found || got != result.ShortURL {
+		t.Errorf("Expected owner to find %s, got %q", result.ShortURL, got)
+	}
+}
+
+func TestGetURLsByUserIDConcurrentWithShorten(t *testing.T) {
+	svc, _ := newTestService()
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		if _, err := svc.ShortenURL(ctx, fmt.Sprintf("https://seed.example.com/%d", i), "user"); err != nil {
+			t.Fatalf("Shorten failed: %v", err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			if _, err := svc.ShortenURL(ctx, fmt.Sprintf("https://race.example.com/%d", i), "user"); err != nil {
+				t.Errorf("Shorten failed: %v", err)
+				return
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			urls, err := svc.GetURLsByUserID(ctx, "user")
+			if err != nil {
+				t.Errorf("GetURLsByUserID failed: %v", err)
+				return
+			}
+			for j := range urls {
+				urls[j].OriginalURL = "mutated"
+			}
+			_ = append(urls, models.UserURL{ShortURL: "extra"})
+		}
+	}()
+	wg.Wait()
+
+	urls, err := svc.GetURLsByUserID(ctx, "user")
+	if err != nil {
+		t.Fatalf("GetURLsByUserID failed: %v", err)
+	}
+	if len(urls) != 210 {
+		t.Errorf("Expected 210 URLs, got %d", len(urls))
+	}
+	for _, u := range urls {
+		if u.OriginalURL == "mutated" || u.ShortURL == "extra" {
+			t.Fatalf("Caller mutation leaked into storage: %+v", u)
+		}
+	}
+}
+
+func TestGetURLsByUserIDTagsAreCallerOwned(t *testing.T) {
+	svc, store := newTestService()
+	ctx := context.Background()
+	if err := store.Save(ctx, "tag1", "https://tags.example.com", "user"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if err := store.SetTags(ctx, "tag1", []string{"docs"}, "user"); err != nil {
+		t.Fatalf("SetTags failed: %v", err)
+	}
+
+	urls, err := svc.GetURLsByUserID(ctx, "user")
+	if err != nil {
+		t.Fatalf("GetURLsByUserID failed: %v", err)
+	}
+	urls[0].Tags[0] = "mutated"
+
+	urls, err = svc.GetURLsByUserID(ctx, "user")
+	if err != nil {
+		t.Fatalf("GetURLsByUserID failed: %v", err)
+	}
+	if urls[0].Tags[0] != "docs" {
+		t.Errorf("Expected stored tags to be unaffected, got %v", urls[0].Tags)
+	}
+}
+
+func TestUpdateURLInvalidatesCache(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewMemoryStorage()
+	cache := NewCachingGetter(store, 10, time.Minute)
+	svc := NewService(store, store, cache, store, store, store, generator.NewGenerator(8), "http://localhost:8080")
+
+	if err := store.Save(ctx, "upd1", "https://old.example.com", "owner"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if got, _ := cache.Get(ctx, "upd1"); got != "https://old.example.com" {
+		t.Fatalf("Expected cached old URL, got %q", got)
+	}
+
+	if err := svc.UpdateURL(ctx, "upd1", "https://new.example.com", "owner"); err != nil {
+		t.Fatalf("UpdateURL failed: %v", err)
+	}
+	if got, _ := cache.Get(ctx, "upd1"); got != "https://new.example.com" {
+		t.Errorf("Expected cache to serve the new URL, got %q", got)
+	}
+
+	if err := svc.UpdateURL(ctx, "upd1", "https://evil.example.com", "intruder"); !errors.Is(err, models.ErrURLNotOwned) {
+		t.Errorf("Expected ErrURLNotOwned, got %v", err)
+	}
+	if err := svc.UpdateURL(ctx, "missing", "https://x.example.com", "owner"); !errors.Is(err, models.ErrURLNotFound) {
+		t.Errorf("Expected ErrURLNotFound, got %v", err)
+	}
+}
+
+type recordingAuditLogger struct {
+	mu      sync.Mutex
+	entries []audit.Entry
+}
+
+func (l *recordingAuditLogger) Log(entry audit.Entry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, entry)
+}
+
+func (l *recordingAuditLogger) Close() error { return nil }
+
+func TestServiceAuditsShortenAndDelete(t *testing.T) {
+	svc, _ := newTestService()
+	logger := &recordingAuditLogger{}
+	svc.Audit = logger
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	svc.Clock = clock.Func(func() time.Time { return now })
+
+	result, err := svc.ShortenURL(context.Background(), "https://audit.example.com", "user")
+	if err != nil {
+		t.Fatalf("ShortenURL failed: %v", err)
+	}
+	shortID := strings.TrimPrefix(result.ShortURL, svc.BaseURL+"/")
+
+	// Returning an existing short URL changes nothing and is not audited.
+	if _, err := svc.ShortenURL(context.Background(), "https://audit.example.com", "user"); err != nil {
+		t.Fatalf("ShortenURL failed: %v", err)
+	}
+	if err := svc.DeleteURLs(context.Background(), []string{shortID}, "user"); err != nil {
+		t.Fatalf("DeleteURLs failed: %v", err)
+	}
+
+	want := []audit.Entry{
+		{Time: now, Action: audit.ActionShorten, UserID: "user", ShortID: shortID},
+		{Time: now, Action: audit.ActionDelete, UserID: "user", ShortID: shortID},
+	}
+	if len(logger.entries) != len(want) {
+		t.Fatalf("Expected %d audit entries, got %+v", len(want), logger.entries)
+	}
+	for i := range want {
+		if logger.entries[i] != want[i] {
+			t.Errorf("Entry %d: expected %+v, got %+v", i, want[i], logger.entries[i])
+		}
+	}
+}
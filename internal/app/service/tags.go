@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/AlenaMolokova/http/internal/app/models"
+)
+
+func (s *Service) ValidateTags(tags []string) error {
+	if s.MaxTagsPerURL > 0 && len(tags) > s.MaxTagsPerURL {
+		return fmt.Errorf("%w: at most %d tags allowed, got %d", models.ErrInvalidTags, s.MaxTagsPerURL, len(tags))
+	}
+	for _, tag := range tags {
+		if tag == "" {
+			return fmt.Errorf("%w: empty tag", models.ErrInvalidTags)
+		}
+		if s.MaxTagLength > 0 && len(tag) > s.MaxTagLength {
+			return fmt.Errorf("%w: tag %q is longer than %d characters", models.ErrInvalidTags, tag, s.MaxTagLength)
+		}
+		for _, c := range tag {
+			if !isTagChar(c) {
+				return fmt.Errorf("%w: tag %q contains invalid character %q", models.ErrInvalidTags, tag, c)
+			}
+		}
+	}
+	return nil
+}
+
+func isTagChar(c rune) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '-' || c == '_'
+}
+
+// SetTags replaces the tags of a short ID owned by userID.
+func (s *Service) SetTags(ctx context.Context, shortID string, tags []string, userID string) error {
+	if err := s.ValidateTags(tags); err != nil {
+		return err
+	}
+	tagger, ok := s.saver.(models.URLTagger)
+	if !ok {
+		return models.ErrTagsNotSupported
+	}
+	return tagger.SetTags(ctx, shortID, tags, userID)
+}
+
+// GetURLsByTag lists the URLs of userID carrying tag.
+func (s *Service) GetURLsByTag(ctx context.Context, userID, tag string) ([]models.UserURL, error) {
+	urls, err := s.GetURLsByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	tagged := urls[:0]
+	for _, url := range urls {
+		if slices.Contains(url.Tags, tag) {
+			tagged = append(tagged, url)
+		}
+	}
+	return tagged, nil
+}
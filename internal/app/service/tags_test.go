@@ -0,0 +1,41 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/AlenaMolokova/http/internal/app/models"
+)
+
+func TestValidateTags(t *testing.T) {
+	svc, _ := newTestService()
+	svc.MaxTagsPerURL = 3
+	svc.MaxTagLength = 8
+
+	tests := []struct {
+		name  string
+		tags  []string
+		valid bool
+	}{
+		{"no tags", nil, true},
+		{"valid tags", []string{"news", "go-lang", "a_b"}, true},
+		{"too many tags", []string{"a", "b", "c", "d"}, false},
+		{"too long", []string{strings.Repeat("x", 9)}, false},
+		{"invalid charset", []string{"white space"}, false},
+		{"non ascii", []string{"тег"}, false},
+		{"empty tag", []string{""}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := svc.ValidateTags(tt.tags)
+			if tt.valid && err != nil {
+				t.Errorf("Expected tags to be accepted, got %v", err)
+			}
+			if !tt.valid && !errors.Is(err, models.ErrInvalidTags) {
+				t.Errorf("Expected ErrInvalidTags, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+//go:build integration
+
+package database
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func integrationStorage(tb testing.TB, opts ...Option) *DatabaseStorage {
+	tb.Helper()
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		tb.Skip("TEST_DATABASE_DSN is not set")
+	}
+
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		tb.Fatalf("connect: %v", err)
+	}
+	if err := Migrate(ctx, pool); err != nil {
+		pool.Close()
+		tb.Fatalf("migrate: %v", err)
+	}
+	db := newStorage(pool, opts...)
+	tb.Cleanup(func() { db.Close() })
+	return db
+}
+
+func prefixedBatch(prefix string, n int) map[string]string {
+	batch := make(map[string]string, n)
+	for i := 0; i < n; i++ {
+		batch[fmt.Sprintf("%s%06d", prefix, i)] = fmt.Sprintf("https://%s.example.com/%d", prefix, i)
+	}
+	return batch
+}
+
+func TestSaveBatchCopyMatchesPerRowInsert(t *testing.T) {
+	ctx := context.Background()
+	for name, threshold := range map[string]int{"rows": 1 << 30, "copy": 1} {
+		t.Run(name, func(t *testing.T) {
+			db := integrationStorage(t, WithCopyThreshold(threshold), WithBatchChunkSize(50))
+			prefix := fmt.Sprintf("it%s", name)
+			batch := prefixedBatch(prefix, 120)
+			t.Cleanup(func() {
+				db.pool.Exec(ctx, `DELETE FROM urls WHERE short_id LIKE $1`, prefix+"%")
+			})
+
+			if err := db.SaveBatch(ctx, batch, "user"); err != nil {
+				t.Fatalf("SaveBatch failed: %v", err)
+			}
+			// Saving again must skip the existing short IDs rather than fail.
+			if err := db.SaveBatch(ctx, batch, "other"); err != nil {
+				t.Fatalf("Repeated SaveBatch failed: %v", err)
+			}
+
+			shortIDs := make([]string, 0, len(batch))
+			for shortID := range batch {
+				shortIDs = append(shortIDs, shortID)
+			}
+			got, err := db.GetBatch(ctx, shortIDs)
+			if err != nil {
+				t.Fatalf("GetBatch failed: %v", err)
+			}
+			if len(got) != len(batch) {
+				t.Fatalf("Expected %d URLs, got %d", len(batch), len(got))
+			}
+			for shortID, originalURL := range batch {
+				if got[shortID] != originalURL {
+					t.Errorf("%s: expected %q, got %q", shortID, originalURL, got[shortID])
+				}
+			}
+			if count, err := db.CountByUserID(ctx, "other"); err != nil || count != 0 {
+				t.Errorf("Expected conflicting rows to keep their owner, got %d (%v)", count, err)
+			}
+		})
+	}
+}
+
+func BenchmarkSaveBatch(b *testing.B) {
+	ctx := context.Background()
+	for name, threshold := range map[string]int{"rows": 1 << 30, "copy": 1} {
+		b.Run(name, func(b *testing.B) {
+			db := integrationStorage(b, WithCopyThreshold(threshold), WithBatchChunkSize(DefaultBatchChunkSize))
+			prefix := "bench" + name
+			b.Cleanup(func() {
+				db.pool.Exec(ctx, `DELETE FROM urls WHERE short_id LIKE $1`, prefix+"%")
+			})
+
+			for i := 0; i < b.N; i++ {
+				batch := prefixedBatch(fmt.Sprintf("%s%d_", prefix, i), 5000)
+				if err := db.SaveBatch(ctx, batch, "bench"); err != nil {
+					b.Fatalf("SaveBatch failed: %v", err)
+				}
+			}
+		})
+	}
+}
@@ -3,44 +3,179 @@ package database
 import (
 	"context"
 	"fmt"
+	"sort"
+	"time"
 
+	"github.com/AlenaMolokova/http/internal/app/logging"
 	"github.com/AlenaMolokova/http/internal/app/models"
+	"github.com/AlenaMolokova/http/internal/app/storage/hitbatch"
+	"github.com/AlenaMolokova/http/internal/app/tenant"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/sirupsen/logrus"
 )
 
+// pgxPool is the part of *pgxpool.Pool the storage uses.
+type pgxPool interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+	Ping(ctx context.Context) error
+	Close()
+}
+
 type DatabaseStorage struct {
-	pool *pgxpool.Pool
+	pool  pgxPool
+	hits  *hitbatch.Batcher
+	retry retryPolicy
+
+	hitFlushInterval time.Duration
+	batchChunkSize   int
+	copyThreshold    int
+}
+
+const (
+	DefaultHitFlushInterval = time.Second
+	DefaultBatchChunkSize   = 1000
+	DefaultCopyThreshold    = 100
+)
+
+type Option func(*DatabaseStorage)
+
+// WithRetry sets how many times transient query errors are retried and the
+// initial backoff, which doubles after each attempt.
+func WithRetry(retries int, backoff time.Duration) Option {
+	return func(db *DatabaseStorage) {
+		db.retry = retryPolicy{retries: retries, backoff: backoff}
+	}
 }
 
-func NewPostgresStorage(dsn string) (*DatabaseStorage, error) {
+// WithHitFlushInterval sets how long hit increments are buffered before they
+// are written. Non-positive values keep the default.
+func WithHitFlushInterval(d time.Duration) Option {
+	return func(db *DatabaseStorage) {
+		if d > 0 {
+			db.hitFlushInterval = d
+		}
+	}
+}
+
+// WithBatchChunkSize sets how many URLs SaveBatch commits per transaction.
+// Non-positive values keep the default.
+func WithBatchChunkSize(size int) Option {
+	return func(db *DatabaseStorage) {
+		if size > 0 {
+			db.batchChunkSize = size
+		}
+	}
+}
+
+// WithCopyThreshold sets the chunk size from which SaveBatch loads rows with
+// COPY instead of one INSERT per row. Non-positive values keep the default.
+func WithCopyThreshold(n int) Option {
+	return func(db *DatabaseStorage) {
+		if n > 0 {
+			db.copyThreshold = n
+		}
+	}
+}
+
+func NewPostgresStorage(dsn string, opts ...Option) (*DatabaseStorage, error) {
 	pool, err := pgxpool.New(context.Background(), dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
-	_, err = pool.Exec(context.Background(), CreateURLsTable)
-	if err != nil {
+	if err = Migrate(context.Background(), pool); err != nil {
 		pool.Close()
-		return nil, fmt.Errorf("failed to create urls table: %w", err)
+		return nil, err
 	}
 
+	db := newStorage(pool, opts...)
 	logrus.Info("Database storage initialized successfully")
-	return &DatabaseStorage{pool: pool}, nil
+	return db, nil
+}
+
+func newStorage(pool pgxPool, opts ...Option) *DatabaseStorage {
+	db := &DatabaseStorage{
+		pool:             pool,
+		retry:            retryPolicy{retries: DefaultRetries, backoff: DefaultBackoff},
+		hitFlushInterval: DefaultHitFlushInterval,
+		batchChunkSize:   DefaultBatchChunkSize,
+		copyThreshold:    DefaultCopyThreshold,
+	}
+	for _, opt := range opts {
+		opt(db)
+	}
+	db.hits = hitbatch.New(db.hitFlushInterval, db.flushHits)
+	return db
 }
 
 func (db *DatabaseStorage) Save(ctx context.Context, shortID, originalURL, userID string) error {
-	_, err := db.pool.Exec(ctx, InsertURL, shortID, originalURL, userID)
+	_, err := db.exec(ctx, InsertURL, shortID, originalURL, userID)
 	if err != nil {
+		logging.FromContext(ctx).WithError(err).WithField("short_id", shortID).Error("Failed to save URL")
 		return fmt.Errorf("failed to save URL: %w", err)
 	}
 	return nil
 }
 
-func (db *DatabaseStorage) FindByOriginalURL(ctx context.Context, originalURL string) (string, error) {
+// ClaimAlias inserts alias unless it is taken. The insert returns the row it
+// wrote, so a request that lost a race sees no row rather than success.
+func (db *DatabaseStorage) ClaimAlias(ctx context.Context, alias, originalURL, userID string) (bool, error) {
+	var inserted string
+	err := db.queryRow(ctx, InsertAlias, []any{alias, originalURL, userID}, &inserted)
+	if err == pgx.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to claim alias: %w", err)
+	}
+	return true, nil
+}
+
+func (db *DatabaseStorage) SaveWithPassword(ctx context.Context, shortID, originalURL, userID, passwordHash string) error {
+	var inserted string
+	err := db.queryRow(ctx, InsertProtectedURL, []any{shortID, originalURL, userID, passwordHash}, &inserted)
+	if err == pgx.ErrNoRows {
+		return models.ErrShortIDTaken
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save protected URL: %w", err)
+	}
+	return nil
+}
+
+func (db *DatabaseStorage) GetPasswordHash(ctx context.Context, shortID string) (string, error) {
+	var passwordHash string
+	err := db.queryRow(ctx, SelectPasswordHash, []any{shortID}, &passwordHash)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get password hash: %w", err)
+	}
+	return passwordHash, nil
+}
+
+func (db *DatabaseStorage) IsDeleted(ctx context.Context, shortID string) (bool, error) {
+	var deleted bool
+	err := db.queryRow(ctx, SelectIsDeleted, []any{shortID}, &deleted)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to check deletion: %w", err)
+	}
+	return deleted, nil
+}
+
+func (db *DatabaseStorage) FindByOriginalURL(ctx context.Context, originalURL, tenantName string) (string, error) {
 	var shortID string
-	err := db.pool.QueryRow(ctx, SelectByOriginalURL, originalURL).Scan(&shortID)
+	err := db.queryRow(ctx, SelectByOriginalURL, []any{originalURL, tenant.Prefix(tenantName)}, &shortID)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return "", nil
@@ -52,52 +187,145 @@ func (db *DatabaseStorage) FindByOriginalURL(ctx context.Context, originalURL st
 
 func (db *DatabaseStorage) Get(ctx context.Context, shortID string) (string, bool) {
 	var originalURL string
-	err := db.pool.QueryRow(ctx, SelectByShortID, shortID).Scan(&originalURL)
+	err := db.queryRow(ctx, SelectByShortID, []any{shortID}, &originalURL)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return "", false
 		}
-		logrus.WithError(err).Error("Failed to get URL")
+		logging.FromContext(ctx).WithError(err).WithField("short_id", shortID).Error("Failed to get URL")
 		return "", false
 	}
 	return originalURL, true
 }
 
-func (db *DatabaseStorage) GetURLsByUserID(ctx context.Context, userID string) ([]models.UserURL, error) {
-	rows, err := db.pool.Query(ctx, SelectByUserID, userID)
+func (db *DatabaseStorage) GetBatch(ctx context.Context, shortIDs []string) (map[string]string, error) {
+	result := make(map[string]string, len(shortIDs))
+	if len(shortIDs) == 0 {
+		return result, nil
+	}
+
+	rows, err := db.query(ctx, SelectByShortIDs, shortIDs)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query URLs: %w", err)
 	}
 	defer rows.Close()
 
-	var urls []models.UserURL
 	for rows.Next() {
-		var shortID, originalURL, userID string
-		var isDeleted bool
-		if err := rows.Scan(&shortID, &originalURL, &userID, &isDeleted); err != nil {
+		var shortID, originalURL string
+		if err := rows.Scan(&shortID, &originalURL); err != nil {
 			return nil, fmt.Errorf("failed to scan row: %w", err)
 		}
-		urls = append(urls, models.UserURL{ShortURL: shortID, OriginalURL: originalURL})
+		result[shortID] = originalURL
 	}
 	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("error iterating rows: %w", err)
 	}
 
+	return result, nil
+}
+
+func (db *DatabaseStorage) GetURLsByUserID(ctx context.Context, userID string) ([]models.UserURL, error) {
+	var urls []models.UserURL
+	err := db.GetURLsByUserIDStream(ctx, userID, func(url models.UserURL) error {
+		urls = append(urls, url)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 	return urls, nil
 }
 
+// GetURLsByUserIDStream hands each row to fn as it is scanned.
+func (db *DatabaseStorage) GetURLsByUserIDStream(ctx context.Context, userID string, fn func(models.UserURL) error) error {
+	rows, err := db.query(ctx, SelectByUserID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to query URLs: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var shortID, originalURL, userID string
+		var isDeleted bool
+		var hits int64
+		var createdAt time.Time
+		var tags []string
+		if err := rows.Scan(&shortID, &originalURL, &userID, &isDeleted, &hits, &createdAt, &tags); err != nil {
+			return fmt.Errorf("failed to scan row: %w", err)
+		}
+		if err := fn(models.UserURL{ShortURL: shortID, OriginalURL: originalURL, Hits: hits, CreatedAt: createdAt, Tags: tags}); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return nil
+}
+
+// NextID advances short_id_seq. Values are never reused, even by
+// transactions that roll back.
+func (db *DatabaseStorage) NextID(ctx context.Context) (uint64, error) {
+	var n int64
+	if err := db.queryRow(ctx, NextShortIDValue, nil, &n); err != nil {
+		return 0, fmt.Errorf("failed to advance short ID sequence: %w", err)
+	}
+	return uint64(n), nil
+}
+
+func (db *DatabaseStorage) CountByUserID(ctx context.Context, userID string) (int, error) {
+	var count int
+	if err := db.queryRow(ctx, CountByUserID, []any{userID}, &count); err != nil {
+		return 0, fmt.Errorf("failed to count URLs: %w", err)
+	}
+	return count, nil
+}
+
+// SaveBatch inserts the batch in transactions of at most batchChunkSize URLs,
+// so large batches do not hold locks for the whole load. A failure returns a
+// *models.BatchError listing the URLs committed before it.
 func (db *DatabaseStorage) SaveBatch(ctx context.Context, batch map[string]string, userID string) error {
+	shortIDs := make([]string, 0, len(batch))
+	for shortID := range batch {
+		shortIDs = append(shortIDs, shortID)
+	}
+	sort.Strings(shortIDs)
+
+	size := db.batchChunkSize
+	if size <= 0 {
+		size = len(shortIDs)
+	}
+
+	saved := make([]string, 0, len(shortIDs))
+	for start := 0; start < len(shortIDs); start += size {
+		chunk := shortIDs[start:min(start+size, len(shortIDs))]
+		if err := db.saveChunk(ctx, chunk, batch, userID); err != nil {
+			logging.FromContext(ctx).WithError(err).WithFields(logrus.Fields{
+				"saved": len(saved),
+				"total": len(shortIDs),
+			}).Error("Failed to save batch chunk")
+			return &models.BatchError{Saved: saved, Err: err}
+		}
+		saved = append(saved, chunk...)
+	}
+	return nil
+}
+
+func (db *DatabaseStorage) saveChunk(ctx context.Context, chunk []string, batch map[string]string, userID string) error {
 	tx, err := db.pool.Begin(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback(ctx)
 
-	for shortID, originalURL := range batch {
-		_, err := tx.Exec(ctx, InsertURLBatch, shortID, originalURL, userID)
-		if err != nil {
-			return fmt.Errorf("failed to save batch URL: %w", err)
-		}
+	if len(chunk) >= db.copyThreshold {
+		err = copyChunk(ctx, tx, chunk, batch, userID)
+	} else {
+		err = insertChunk(ctx, tx, chunk, batch, userID)
+	}
+	if err != nil {
+		return err
 	}
 
 	if err := tx.Commit(ctx); err != nil {
@@ -106,22 +334,225 @@ func (db *DatabaseStorage) SaveBatch(ctx context.Context, batch map[string]strin
 	return nil
 }
 
+func insertChunk(ctx context.Context, tx pgx.Tx, chunk []string, batch map[string]string, userID string) error {
+	b := &pgx.Batch{}
+	for _, shortID := range chunk {
+		b.Queue(InsertURLBatch, shortID, batch[shortID], userID)
+	}
+	if err := tx.SendBatch(ctx, b).Close(); err != nil {
+		return fmt.Errorf("failed to save batch URLs: %w", err)
+	}
+	return nil
+}
+
+// copyChunk streams the chunk into a temporary table with COPY and moves it
+// into urls with one INSERT, since COPY itself cannot skip conflicting rows.
+func copyChunk(ctx context.Context, tx pgx.Tx, chunk []string, batch map[string]string, userID string) error {
+	if _, err := tx.Exec(ctx, CreateBatchTable); err != nil {
+		return fmt.Errorf("failed to create batch table: %w", err)
+	}
+
+	rows := make([][]any, len(chunk))
+	for i, shortID := range chunk {
+		rows[i] = []any{shortID, batch[shortID], userID}
+	}
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"urls_batch"}, []string{"short_id", "original_url", "user_id"}, pgx.CopyFromRows(rows)); err != nil {
+		return fmt.Errorf("failed to copy batch URLs: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, InsertFromBatchTable); err != nil {
+		return fmt.Errorf("failed to save batch URLs: %w", err)
+	}
+	return nil
+}
+
 func (db *DatabaseStorage) DeleteURLs(ctx context.Context, shortIDs []string, userID string) error {
 	if len(shortIDs) == 0 {
 		return nil
 	}
-	_, err := db.pool.Exec(ctx, UpdateDeleteURLs, shortIDs, userID)
+	_, err := db.exec(ctx, UpdateDeleteURLs, shortIDs, userID)
 	if err != nil {
 		return fmt.Errorf("failed to delete URLs: %w", err)
 	}
 	return nil
 }
 
+func (db *DatabaseStorage) RestoreURLs(ctx context.Context, shortIDs []string, userID string) error {
+	if len(shortIDs) == 0 {
+		return nil
+	}
+	_, err := db.exec(ctx, UpdateRestoreURLs, shortIDs, userID)
+	if err != nil {
+		return fmt.Errorf("failed to restore URLs: %w", err)
+	}
+	return nil
+}
+
+func (db *DatabaseStorage) OwnerOf(ctx context.Context, shortID string) (string, error) {
+	var owner string
+	err := db.queryRow(ctx, SelectAnyOwner, []any{shortID}, &owner)
+	if err == pgx.ErrNoRows {
+		return "", models.ErrURLNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to look up URL owner: %w", err)
+	}
+	return owner, nil
+}
+
+func (db *DatabaseStorage) SetTags(ctx context.Context, shortID string, tags []string, userID string) error {
+	if tags == nil {
+		tags = []string{}
+	}
+	tag, err := db.exec(ctx, UpdateTags, tags, shortID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to set tags: %w", err)
+	}
+	if tag.RowsAffected() > 0 {
+		return nil
+	}
+	return db.missingOrNotOwned(ctx, shortID)
+}
+
+// missingOrNotOwned explains why an owner-scoped update matched no row.
+func (db *DatabaseStorage) missingOrNotOwned(ctx context.Context, shortID string) error {
+	var owner string
+	err := db.queryRow(ctx, SelectOwner, []any{shortID}, &owner)
+	if err == pgx.ErrNoRows {
+		return models.ErrURLNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to look up URL owner: %w", err)
+	}
+	return models.ErrURLNotOwned
+}
+
+func (db *DatabaseStorage) UpdateURL(ctx context.Context, shortID, newURL, userID string) error {
+	tag, err := db.exec(ctx, UpdateOriginalURL, newURL, shortID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update URL: %w", err)
+	}
+	if tag.RowsAffected() > 0 {
+		return nil
+	}
+	return db.missingOrNotOwned(ctx, shortID)
+}
+
+func (db *DatabaseStorage) PurgeURLs(ctx context.Context, shortIDs []string, userID string) error {
+	if len(shortIDs) == 0 {
+		return nil
+	}
+	_, err := db.exec(ctx, DeleteURLsByShortIDs, shortIDs, userID)
+	if err != nil {
+		return fmt.Errorf("failed to purge URLs: %w", err)
+	}
+	return nil
+}
+
+func (db *DatabaseStorage) Export(ctx context.Context) ([]models.UserURL, error) {
+	rows, err := db.query(ctx, SelectAllURLs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query URLs: %w", err)
+	}
+	defer rows.Close()
+
+	var urls []models.UserURL
+	for rows.Next() {
+		var url models.UserURL
+		if err := rows.Scan(&url.ShortURL, &url.OriginalURL, &url.UserID, &url.IsDeleted, &url.PasswordHash, &url.Hits, &url.CreatedAt, &url.Tags); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		if len(url.Tags) == 0 {
+			url.Tags = nil
+		}
+		urls = append(urls, url)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	return urls, nil
+}
+
+func (db *DatabaseStorage) RecentURLs(ctx context.Context, limit int) ([]models.UserURL, error) {
+	rows, err := db.query(ctx, SelectRecentURLs, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query recent URLs: %w", err)
+	}
+	defer rows.Close()
+
+	var urls []models.UserURL
+	for rows.Next() {
+		var url models.UserURL
+		if err := rows.Scan(&url.ShortURL, &url.OriginalURL); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		urls = append(urls, url)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	return urls, nil
+}
+
+func (db *DatabaseStorage) Import(ctx context.Context, urls []models.UserURL) error {
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	for _, url := range urls {
+		tags := url.Tags
+		if tags == nil {
+			tags = []string{}
+		}
+		_, err := tx.Exec(ctx, UpsertURL, url.ShortURL, url.OriginalURL, url.UserID, url.IsDeleted, url.PasswordHash, url.Hits, nullTime(url.CreatedAt), tags)
+		if err != nil {
+			return fmt.Errorf("failed to import URL: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func (db *DatabaseStorage) RecordHit(ctx context.Context, shortID string) error {
+	db.hits.Add(shortID)
+	return nil
+}
+
+func (db *DatabaseStorage) flushHits(ctx context.Context, hits map[string]int64) error {
+	batch := &pgx.Batch{}
+	for shortID, n := range hits {
+		batch.Queue(IncrementHits, shortID, n)
+	}
+	if err := db.pool.SendBatch(ctx, batch).Close(); err != nil {
+		return fmt.Errorf("failed to flush hit counters: %w", err)
+	}
+	return nil
+}
+
+// Flush writes any buffered hit increments now instead of waiting for the
+// next flush interval.
+func (db *DatabaseStorage) Flush(ctx context.Context) error {
+	return db.hits.Flush(ctx)
+}
+
 func (db *DatabaseStorage) Ping(ctx context.Context) error {
 	return db.pool.Ping(ctx)
 }
 
 func (db *DatabaseStorage) Close() error {
+	err := db.hits.Close(context.Background())
 	db.pool.Close()
-	return nil
+	return err
 }
@@ -0,0 +1,54 @@
+package database
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadMigrationsEmbedded(t *testing.T) {
+	migrations, err := loadMigrations(migrationFiles)
+	if err != nil {
+		t.Fatalf("loadMigrations: %v", err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("expected embedded migrations")
+	}
+	if migrations[0].version != 1 || migrations[0].name != "0001_create_urls_table" {
+		t.Errorf("expected create urls table as migration 1, got %d %q", migrations[0].version, migrations[0].name)
+	}
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].version <= migrations[i-1].version {
+			t.Errorf("migrations out of order: %d after %d", migrations[i].version, migrations[i-1].version)
+		}
+	}
+}
+
+func TestLoadMigrationsSortsByVersion(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/0010_later.sql":  {Data: []byte("SELECT 10")},
+		"migrations/0002_second.sql": {Data: []byte("SELECT 2")},
+		"migrations/README.md":       {Data: []byte("ignored")},
+	}
+	migrations, err := loadMigrations(fsys)
+	if err != nil {
+		t.Fatalf("loadMigrations: %v", err)
+	}
+	if len(migrations) != 2 || migrations[0].version != 2 || migrations[1].version != 10 {
+		t.Fatalf("unexpected migrations: %+v", migrations)
+	}
+}
+
+func TestLoadMigrationsRejectsBadNames(t *testing.T) {
+	for name, fsys := range map[string]fstest.MapFS{
+		"no prefix":   {"migrations/create.sql": {Data: []byte("SELECT 1")}},
+		"bad version": {"migrations/abc_create.sql": {Data: []byte("SELECT 1")}},
+		"duplicate": {
+			"migrations/0001_a.sql": {Data: []byte("SELECT 1")},
+			"migrations/1_b.sql":    {Data: []byte("SELECT 1")},
+		},
+	} {
+		if _, err := loadMigrations(fsys); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS urls (
+	short_id VARCHAR(255) PRIMARY KEY,
+	original_url TEXT NOT NULL,
+	user_id VARCHAR(255),
+	is_deleted BOOLEAN DEFAULT FALSE
+);
@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS idx_urls_original_url_md5 ON urls (md5(original_url));
@@ -1,23 +1,81 @@
 package database
 
 const (
-	CreateURLsTable = `
-		CREATE TABLE IF NOT EXISTS urls (
-			short_id VARCHAR(255) PRIMARY KEY,
-			original_url TEXT NOT NULL,
-			user_id VARCHAR(255),
-			is_deleted BOOLEAN DEFAULT FALSE
+	CreateMigrationsTable = `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
 		)`
 
+	LockMigrations = `
+		SELECT pg_advisory_xact_lock(72736501)`
+
+	SelectMigrationApplied = `
+		SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)`
+
+	InsertMigration = `
+		INSERT INTO schema_migrations (version, name)
+		VALUES ($1, $2)`
+
+	SelectAllURLs = `
+		SELECT short_id, original_url, COALESCE(user_id, ''), COALESCE(is_deleted, FALSE), COALESCE(password_hash, ''), hits, created_at, tags
+		FROM urls
+		ORDER BY short_id`
+
+	UpsertURL = `
+		INSERT INTO urls (short_id, original_url, user_id, is_deleted, password_hash, hits, created_at, tags)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, COALESCE($7, now()), $8)
+		ON CONFLICT (short_id) DO UPDATE SET
+			original_url = EXCLUDED.original_url,
+			user_id = EXCLUDED.user_id,
+			is_deleted = EXCLUDED.is_deleted,
+			password_hash = EXCLUDED.password_hash,
+			hits = EXCLUDED.hits,
+			created_at = EXCLUDED.created_at,
+			tags = EXCLUDED.tags`
+
+	UpdateOriginalURL = `
+		UPDATE urls
+		SET original_url = $1
+		WHERE short_id = $2 AND user_id = $3 AND is_deleted = FALSE`
+
+	UpdateTags = `
+		UPDATE urls
+		SET tags = $1
+		WHERE short_id = $2 AND user_id = $3 AND is_deleted = FALSE`
+
+	SelectAnyOwner = `
+		SELECT COALESCE(user_id, '')
+		FROM urls
+		WHERE short_id = $1`
+
+	SelectOwner = `
+		SELECT COALESCE(user_id, '')
+		FROM urls
+		WHERE short_id = $1 AND is_deleted = FALSE`
+
+	IncrementHits = `
+		UPDATE urls
+		SET hits = hits + $2
+		WHERE short_id = $1`
+
 	InsertURL = `
 		INSERT INTO urls (short_id, original_url, user_id)
 		VALUES ($1, $2, $3)
 		ON CONFLICT (short_id) DO NOTHING`
 
+	InsertAlias = `
+		INSERT INTO urls (short_id, original_url, user_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (short_id) DO NOTHING
+		RETURNING short_id`
+
 	SelectByOriginalURL = `
 		SELECT short_id
 		FROM urls
-		WHERE original_url = $1 AND is_deleted = FALSE
+		WHERE md5(original_url) = md5($1) AND original_url = $1 AND is_deleted = FALSE AND password_hash IS NULL
+			AND CASE WHEN $2::text = '' THEN strpos(short_id, '_') = 0 ELSE left(short_id, length($2::text)) = $2::text END
 		LIMIT 1`
 
 	InsertURLBatch = `
@@ -25,13 +83,61 @@ const (
 		VALUES ($1, $2, $3)
 		ON CONFLICT (short_id) DO NOTHING`
 
+	CreateBatchTable = `
+		CREATE TEMP TABLE urls_batch (
+			short_id TEXT NOT NULL,
+			original_url TEXT NOT NULL,
+			user_id TEXT
+		) ON COMMIT DROP`
+
+	InsertFromBatchTable = `
+		INSERT INTO urls (short_id, original_url, user_id)
+		SELECT short_id, original_url, user_id FROM urls_batch
+		ON CONFLICT (short_id) DO NOTHING`
+
+	InsertProtectedURL = `
+		INSERT INTO urls (short_id, original_url, user_id, password_hash)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (short_id) DO NOTHING
+		RETURNING short_id`
+
+	SelectPasswordHash = `
+		SELECT COALESCE(password_hash, '')
+		FROM urls
+		WHERE short_id = $1`
+
+	SelectIsDeleted = `
+		SELECT is_deleted
+		FROM urls
+		WHERE short_id = $1`
+
 	SelectByShortID = `
 		SELECT original_url
 		FROM urls
 		WHERE short_id = $1 AND is_deleted = FALSE`
 
+	SelectByShortIDs = `
+		SELECT short_id, original_url
+		FROM urls
+		WHERE short_id = ANY($1) AND is_deleted = FALSE`
+
 	SelectByUserID = `
-		SELECT short_id, original_url, user_id, is_deleted
+		SELECT short_id, original_url, user_id, is_deleted, hits, created_at, tags
+		FROM urls
+		WHERE user_id = $1 AND is_deleted = FALSE
+		ORDER BY created_at DESC, short_id`
+
+	NextShortIDValue = `SELECT nextval('short_id_seq')`
+
+	SelectRecentURLs = `
+		SELECT short_id, original_url
+		FROM urls
+		WHERE is_deleted = FALSE
+		ORDER BY created_at DESC, short_id
+		LIMIT $1`
+
+	CountByUserID = `
+		SELECT COUNT(*)
 		FROM urls
 		WHERE user_id = $1 AND is_deleted = FALSE`
 
@@ -39,4 +145,13 @@ const (
 		UPDATE urls
 		SET is_deleted = TRUE
 		WHERE short_id = ANY($1) AND user_id = $2`
+
+	DeleteURLsByShortIDs = `
+		DELETE FROM urls
+		WHERE short_id = ANY($1) AND user_id = $2`
+
+	UpdateRestoreURLs = `
+		UPDATE urls
+		SET is_deleted = FALSE
+		WHERE short_id = ANY($1) AND user_id = $2`
 )
\ No newline at end of file
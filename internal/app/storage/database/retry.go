@@ -0,0 +1,83 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/AlenaMolokova/http/internal/app/logging"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	DefaultRetries = 3
+	DefaultBackoff = 50 * time.Millisecond
+	maxBackoff     = 2 * time.Second
+)
+
+type retryPolicy struct {
+	retries int
+	backoff time.Duration
+}
+
+// do runs fn, retrying transient failures with exponential backoff. Errors
+// that are not safe to retry, such as unique violations, return at once.
+func (p retryPolicy) do(ctx context.Context, fn func() error) error {
+	delay := p.backoff
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= p.retries || !isTransient(err) {
+			return err
+		}
+
+		logging.FromContext(ctx).WithError(err).WithField("attempt", attempt+1).Warn("Transient database error, retrying")
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		delay = min(delay*2, maxBackoff)
+	}
+}
+
+func isTransient(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "40001", "40P01":
+			return true
+		}
+		return strings.HasPrefix(pgErr.Code, "08")
+	}
+	return pgconn.SafeToRetry(err)
+}
+
+func (db *DatabaseStorage) exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	var tag pgconn.CommandTag
+	err := db.retry.do(ctx, func() error {
+		var err error
+		tag, err = db.pool.Exec(ctx, sql, args...)
+		return err
+	})
+	return tag, err
+}
+
+func (db *DatabaseStorage) query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	var rows pgx.Rows
+	err := db.retry.do(ctx, func() error {
+		var err error
+		rows, err = db.pool.Query(ctx, sql, args...)
+		return err
+	})
+	return rows, err
+}
+
+func (db *DatabaseStorage) queryRow(ctx context.Context, sql string, args []any, dest ...any) error {
+	return db.retry.do(ctx, func() error {
+		return db.pool.QueryRow(ctx, sql, args...).Scan(dest...)
+	})
+}
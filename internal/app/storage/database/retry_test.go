@@ -0,0 +1,101 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestRetryPolicyRetriesTransientErrorOnce(t *testing.T) {
+	p := retryPolicy{retries: 3, backoff: time.Millisecond}
+
+	calls := 0
+	err := p.do(context.Background(), func() error {
+		calls++
+		if calls == 1 {
+			return &pgconn.PgError{Code: "40P01", Message: "deadlock detected"}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected success after retry, got %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("Expected 2 calls, got %d", calls)
+	}
+}
+
+func TestRetryPolicyDoesNotRetryUniqueViolation(t *testing.T) {
+	p := retryPolicy{retries: 3, backoff: time.Millisecond}
+
+	calls := 0
+	err := p.do(context.Background(), func() error {
+		calls++
+		return &pgconn.PgError{Code: "23505", Message: "duplicate key value"}
+	})
+	if err == nil {
+		t.Fatal("Expected the unique violation to be returned")
+	}
+	if calls != 1 {
+		t.Errorf("Expected 1 call, got %d", calls)
+	}
+}
+
+func TestRetryPolicyGivesUpAfterRetries(t *testing.T) {
+	p := retryPolicy{retries: 2, backoff: time.Millisecond}
+
+	calls := 0
+	err := p.do(context.Background(), func() error {
+		calls++
+		return &pgconn.PgError{Code: "08006", Message: "connection failure"}
+	})
+	if err == nil {
+		t.Fatal("Expected an error after exhausting retries")
+	}
+	if calls != 3 {
+		t.Errorf("Expected 3 calls, got %d", calls)
+	}
+}
+
+func TestRetryPolicyStopsOnContextCancel(t *testing.T) {
+	p := retryPolicy{retries: 5, backoff: time.Hour}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	calls := 0
+	err := p.do(ctx, func() error {
+		calls++
+		cancel()
+		return &pgconn.PgError{Code: "40001", Message: "could not serialize access"}
+	})
+	if err == nil {
+		t.Fatal("Expected an error once the context is cancelled")
+	}
+	if calls != 1 {
+		t.Errorf("Expected 1 call, got %d", calls)
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"serialization failure", &pgconn.PgError{Code: "40001"}, true},
+		{"deadlock", &pgconn.PgError{Code: "40P01"}, true},
+		{"connection exception", &pgconn.PgError{Code: "08003"}, true},
+		{"unique violation", &pgconn.PgError{Code: "23505"}, false},
+		{"syntax error", &pgconn.PgError{Code: "42601"}, false},
+		{"plain error", errors.New("boom"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isTransient(tt.err); got != tt.want {
+				t.Errorf("isTransient(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
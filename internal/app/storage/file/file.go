@@ -3,28 +3,80 @@ package file
 import (
 	"context"
 	"encoding/json"
-	"errors"
+	"fmt"
 	"os"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/AlenaMolokova/http/internal/app/models"
+	"github.com/AlenaMolokova/http/internal/app/storage/hitbatch"
+	"github.com/AlenaMolokova/http/internal/app/tenant"
 	"github.com/sirupsen/logrus"
 )
 
 type FileStorage struct {
-	filePath string
-	urls     map[string]models.UserURL
-	mu       sync.RWMutex
+	filePath   string
+	urls       map[string]models.UserURL
+	byOriginal map[string]map[string]struct{}
+	mu         sync.RWMutex
+	opts       options
+	hits       *hitbatch.Batcher
+
+	lastSaveErr   error
+	pendingWrites int
+
+	seqMu    sync.Mutex
+	seqNext  uint64
+	seqLimit uint64
+}
+
+type options struct {
+	compactOnLoad    bool
+	batchChunkSize   int
+	hitFlushInterval time.Duration
 }
 
-func NewFileStorage(filePath string) (*FileStorage, error) {
+const DefaultHitFlushInterval = time.Second
+
+type Option func(*options)
+
+func WithCompactOnLoad(compact bool) Option {
+	return func(o *options) {
+		o.compactOnLoad = compact
+	}
+}
+
+func WithBatchChunkSize(size int) Option {
+	return func(o *options) {
+		o.batchChunkSize = size
+	}
+}
+
+// WithHitFlushInterval sets how long hit increments are buffered before the
+// file is rewritten with them. Non-positive values keep the default.
+func WithHitFlushInterval(d time.Duration) Option {
+	return func(o *options) {
+		if d > 0 {
+			o.hitFlushInterval = d
+		}
+	}
+}
+
+func NewFileStorage(filePath string, opts ...Option) (*FileStorage, error) {
 	fs := &FileStorage{
-		filePath: filePath,
-		urls:     make(map[string]models.UserURL),
+		filePath:   filePath,
+		urls:       make(map[string]models.UserURL),
+		byOriginal: make(map[string]map[string]struct{}),
+		opts:       options{hitFlushInterval: DefaultHitFlushInterval},
+	}
+	for _, opt := range opts {
+		opt(&fs.opts)
 	}
 
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
 		logrus.Info("File does not exist, starting with empty storage")
+		fs.hits = hitbatch.New(fs.opts.hitFlushInterval, fs.flushHits)
 		return fs, nil
 	}
 
@@ -40,10 +92,23 @@ func NewFileStorage(filePath string) (*FileStorage, error) {
 		return nil, err
 	}
 
+	compacted := 0
 	for _, entry := range entries {
-		fs.urls[entry.ShortURL] = entry
+		if fs.opts.compactOnLoad && entry.IsDeleted {
+			compacted++
+			continue
+		}
+		fs.put(entry)
+	}
+
+	if compacted > 0 {
+		if err := fs.saveToFile(); err != nil {
+			return nil, err
+		}
+		logrus.WithField("dropped", compacted).Info("Compacted deleted entries from file storage")
 	}
 
+	fs.hits = hitbatch.New(fs.opts.hitFlushInterval, fs.flushHits)
 	logrus.Info("File storage initialized successfully")
 	return fs, nil
 }
@@ -52,22 +117,78 @@ func (fs *FileStorage) Save(ctx context.Context, shortID, originalURL, userID st
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
-	fs.urls[shortID] = models.UserURL{
+	fs.put(models.UserURL{
 		ShortURL:    shortID,
 		OriginalURL: originalURL,
 		UserID:      userID,
-		IsDeleted:   false,
-	}
+		CreatedAt:   time.Now(),
+	})
 
 	return fs.saveToFile()
 }
 
-func (fs *FileStorage) FindByOriginalURL(ctx context.Context, originalURL string) (string, error) {
+func (fs *FileStorage) ClaimAlias(ctx context.Context, alias, originalURL, userID string) (bool, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if _, exists := fs.urls[alias]; exists {
+		return false, nil
+	}
+	fs.put(models.UserURL{
+		ShortURL:    alias,
+		OriginalURL: originalURL,
+		UserID:      userID,
+		CreatedAt:   time.Now(),
+	})
+
+	if err := fs.saveToFile(); err != nil {
+		fs.remove(alias)
+		return false, err
+	}
+	return true, nil
+}
+
+func (fs *FileStorage) SaveWithPassword(ctx context.Context, shortID, originalURL, userID, passwordHash string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if _, exists := fs.urls[shortID]; exists {
+		return models.ErrShortIDTaken
+	}
+	fs.put(models.UserURL{
+		ShortURL:     shortID,
+		OriginalURL:  originalURL,
+		UserID:       userID,
+		PasswordHash: passwordHash,
+		CreatedAt:    time.Now(),
+	})
+	if err := fs.saveToFile(); err != nil {
+		fs.remove(shortID)
+		return err
+	}
+	return nil
+}
+
+func (fs *FileStorage) GetPasswordHash(ctx context.Context, shortID string) (string, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	return fs.urls[shortID].PasswordHash, nil
+}
+
+func (fs *FileStorage) IsDeleted(ctx context.Context, shortID string) (bool, error) {
 	fs.mu.RLock()
 	defer fs.mu.RUnlock()
 
-	for shortID, url := range fs.urls {
-		if url.OriginalURL == originalURL && !url.IsDeleted {
+	return fs.urls[shortID].IsDeleted, nil
+}
+
+func (fs *FileStorage) FindByOriginalURL(ctx context.Context, originalURL, tenantName string) (string, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	for shortID := range fs.byOriginal[originalURL] {
+		if owner, _ := tenant.Split(shortID); owner == tenantName {
 			return shortID, nil
 		}
 	}
@@ -78,13 +199,20 @@ func (fs *FileStorage) SaveBatch(ctx context.Context, items map[string]string, u
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
+	now := time.Now()
+	written := 0
 	for shortID, originalURL := range items {
-		fs.urls[shortID] = models.UserURL{
+		if fs.opts.batchChunkSize > 0 && written > 0 && written%fs.opts.batchChunkSize == 0 {
+			fs.mu.Unlock()
+			fs.mu.Lock()
+		}
+		written++
+		fs.put(models.UserURL{
 			ShortURL:    shortID,
 			OriginalURL: originalURL,
 			UserID:      userID,
-			IsDeleted:   false,
-		}
+			CreatedAt:   now,
+		})
 	}
 
 	return fs.saveToFile()
@@ -101,6 +229,59 @@ func (fs *FileStorage) Get(ctx context.Context, shortID string) (string, bool) {
 	return url.OriginalURL, true
 }
 
+func (fs *FileStorage) GetBatch(ctx context.Context, shortIDs []string) (map[string]string, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	result := make(map[string]string, len(shortIDs))
+	for _, shortID := range shortIDs {
+		if url, exists := fs.urls[shortID]; exists && !url.IsDeleted {
+			result[shortID] = url.OriginalURL
+		}
+	}
+	return result, nil
+}
+
+// RecordHit buffers the hit; the file is rewritten with the counts on the
+// next flush rather than on every redirect.
+func (fs *FileStorage) RecordHit(ctx context.Context, shortID string) error {
+	fs.hits.Add(shortID)
+	return nil
+}
+
+func (fs *FileStorage) flushHits(ctx context.Context, hits map[string]int64) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	fs.addHits(hits, 1)
+	if err := fs.saveToFile(); err != nil {
+		// The batcher keeps the counts for the next attempt.
+		fs.addHits(hits, -1)
+		return err
+	}
+	return nil
+}
+
+func (fs *FileStorage) addHits(hits map[string]int64, sign int64) {
+	for shortID, n := range hits {
+		if url, exists := fs.urls[shortID]; exists {
+			url.Hits += sign * n
+			fs.urls[shortID] = url
+		}
+	}
+}
+
+// Flush writes any buffered hit counts to the file now instead of waiting
+// for the next flush interval.
+func (fs *FileStorage) Flush(ctx context.Context) error {
+	return fs.hits.Flush(ctx)
+}
+
+// Close stops the hit flusher and writes the remaining counts.
+func (fs *FileStorage) Close() error {
+	return fs.hits.Close(context.Background())
+}
+
 func (fs *FileStorage) GetURLsByUserID(ctx context.Context, userID string) ([]models.UserURL, error) {
 	fs.mu.RLock()
 	defer fs.mu.RUnlock()
@@ -111,9 +292,28 @@ func (fs *FileStorage) GetURLsByUserID(ctx context.Context, userID string) ([]mo
 			result = append(result, url)
 		}
 	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].CreatedAt.Equal(result[j].CreatedAt) {
+			return result[i].CreatedAt.After(result[j].CreatedAt)
+		}
+		return result[i].ShortURL < result[j].ShortURL
+	})
 	return result, nil
 }
 
+func (fs *FileStorage) CountByUserID(ctx context.Context, userID string) (int, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	count := 0
+	for _, url := range fs.urls {
+		if url.UserID == userID && !url.IsDeleted {
+			count++
+		}
+	}
+	return count, nil
+}
+
 func (fs *FileStorage) DeleteURLs(ctx context.Context, shortIDs []string, userID string) error {
 	fs.mu.Lock()
     defer fs.mu.Unlock()
@@ -121,14 +321,170 @@ func (fs *FileStorage) DeleteURLs(ctx context.Context, shortIDs []string, userID
     for _, shortID := range shortIDs {
         if url, exists := fs.urls[shortID]; exists && url.UserID == userID {
             url.IsDeleted = true
-            fs.urls[shortID] = url
+            fs.put(url)
         }
     }
     return fs.saveToFile()
 }
 
+func (fs *FileStorage) RestoreURLs(ctx context.Context, shortIDs []string, userID string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	for _, shortID := range shortIDs {
+		if url, exists := fs.urls[shortID]; exists && url.UserID == userID {
+			url.IsDeleted = false
+			fs.put(url)
+		}
+	}
+	return fs.saveToFile()
+}
+
+func (fs *FileStorage) OwnerOf(ctx context.Context, shortID string) (string, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	url, exists := fs.urls[shortID]
+	if !exists {
+		return "", models.ErrURLNotFound
+	}
+	return url.UserID, nil
+}
+
+func (fs *FileStorage) SetTags(ctx context.Context, shortID string, tags []string, userID string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	url, exists := fs.urls[shortID]
+	if !exists || url.IsDeleted {
+		return models.ErrURLNotFound
+	}
+	if url.UserID != userID {
+		return models.ErrURLNotOwned
+	}
+	previous := url
+	url.Tags = append([]string(nil), tags...)
+	fs.put(url)
+
+	if err := fs.saveToFile(); err != nil {
+		fs.put(previous)
+		return err
+	}
+	return nil
+}
+
+func (fs *FileStorage) UpdateURL(ctx context.Context, shortID, newURL, userID string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	url, exists := fs.urls[shortID]
+	if !exists || url.IsDeleted {
+		return models.ErrURLNotFound
+	}
+	if url.UserID != userID {
+		return models.ErrURLNotOwned
+	}
+	previous := url
+	url.OriginalURL = newURL
+	fs.put(url)
+
+	if err := fs.saveToFile(); err != nil {
+		fs.put(previous)
+		return err
+	}
+	return nil
+}
+
+func (fs *FileStorage) PurgeURLs(ctx context.Context, shortIDs []string, userID string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	for _, shortID := range shortIDs {
+		if url, exists := fs.urls[shortID]; exists && url.UserID == userID {
+			fs.remove(shortID)
+		}
+	}
+	return fs.saveToFile()
+}
+
+func (fs *FileStorage) Export(ctx context.Context) ([]models.UserURL, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	result := make([]models.UserURL, 0, len(fs.urls))
+	for _, url := range fs.urls {
+		result = append(result, url)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ShortURL < result[j].ShortURL
+	})
+	return result, nil
+}
+
+func (fs *FileStorage) RecentURLs(ctx context.Context, limit int) ([]models.UserURL, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	result := make([]models.UserURL, 0, len(fs.urls))
+	for _, url := range fs.urls {
+		if !url.IsDeleted {
+			result = append(result, url)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.After(result[j].CreatedAt)
+	})
+	if limit >= 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
+func (fs *FileStorage) Import(ctx context.Context, urls []models.UserURL) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	previous := make(map[string]models.UserURL, len(urls))
+	for _, url := range urls {
+		if old, exists := fs.urls[url.ShortURL]; exists {
+			previous[url.ShortURL] = old
+		}
+		fs.put(url)
+	}
+	if err := fs.saveToFile(); err != nil {
+		for _, url := range urls {
+			if old, existed := previous[url.ShortURL]; existed {
+				fs.put(old)
+			} else {
+				fs.remove(url.ShortURL)
+			}
+		}
+		return err
+	}
+	return nil
+}
+
 func (fs *FileStorage) Ping(ctx context.Context) error {
-	return errors.New("file storage does not support database connection check")
+	if err := fs.LastSaveError(); err != nil {
+		return fmt.Errorf("last save to %s failed: %w", fs.filePath, err)
+	}
+	return fmt.Errorf("file %w", models.ErrNoDatabase)
+}
+
+func (fs *FileStorage) LastSaveError() error {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	return fs.lastSaveErr
+}
+
+// PendingWrites reports how many writes have failed to reach the file since
+// the last successful save. Their changes exist only in memory until then.
+func (fs *FileStorage) PendingWrites() int {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	return fs.pendingWrites
 }
 
 func (fs *FileStorage) saveToFile() error {
@@ -140,12 +496,51 @@ func (fs *FileStorage) saveToFile() error {
 	data, err := json.MarshalIndent(entries, "", "  ")
 	if err != nil {
 		logrus.WithError(err).Error("Failed to marshal URLs to JSON")
+		fs.lastSaveErr = err
+		fs.pendingWrites++
 		return err
 	}
 
 	if err := os.WriteFile(fs.filePath, data, 0644); err != nil {
-		logrus.WithError(err).Error("Failed to write URLs to file")
+		logrus.WithError(err).WithField("file", fs.filePath).Error("Failed to write URLs to file")
+		fs.lastSaveErr = err
+		fs.pendingWrites++
 		return err
 	}
+	if fs.lastSaveErr != nil {
+		logrus.WithField("file", fs.filePath).Info("File storage writes recovered")
+		fs.lastSaveErr = nil
+	}
+	fs.pendingWrites = 0
 	return nil
-}
\ No newline at end of file
+}
+
+func (fs *FileStorage) put(url models.UserURL) {
+	if old, exists := fs.urls[url.ShortURL]; exists {
+		fs.unindex(old)
+	}
+	fs.urls[url.ShortURL] = url
+	if !url.IsDeleted && url.PasswordHash == "" {
+		ids := fs.byOriginal[url.OriginalURL]
+		if ids == nil {
+			ids = make(map[string]struct{})
+			fs.byOriginal[url.OriginalURL] = ids
+		}
+		ids[url.ShortURL] = struct{}{}
+	}
+}
+
+func (fs *FileStorage) remove(shortID string) {
+	if old, exists := fs.urls[shortID]; exists {
+		fs.unindex(old)
+		delete(fs.urls, shortID)
+	}
+}
+
+func (fs *FileStorage) unindex(url models.UserURL) {
+	ids := fs.byOriginal[url.OriginalURL]
+	delete(ids, url.ShortURL)
+	if len(ids) == 0 {
+		delete(fs.byOriginal, url.OriginalURL)
+	}
+}
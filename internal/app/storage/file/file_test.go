@@ -0,0 +1,289 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/AlenaMolokova/http/internal/app/models"
+)
+
+func writeEntries(t *testing.T, entries []models.UserURL) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "urls.json")
+	data, err := json.Marshal(entries)
+	if err != nil {
+		t.Fatalf("Failed to marshal entries: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	return path
+}
+
+func readEntries(t *testing.T, path string) []models.UserURL {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	var entries []models.UserURL
+	if err := json.Unmarshal(data, &entries); err != nil {
+		t.Fatalf("Failed to unmarshal file: %v", err)
+	}
+	return entries
+}
+
+var entriesWithDeleted = []models.UserURL{
+	{ShortURL: "keep1", OriginalURL: "https://a.example", UserID: "u"},
+	{ShortURL: "gone1", OriginalURL: "https://b.example", UserID: "u", IsDeleted: true},
+	{ShortURL: "keep2", OriginalURL: "https://c.example", UserID: "u"},
+	{ShortURL: "gone2", OriginalURL: "https://d.example", UserID: "u", IsDeleted: true},
+}
+
+func TestNewFileStorageCompactOnLoad(t *testing.T) {
+	path := writeEntries(t, entriesWithDeleted)
+
+	fs, err := NewFileStorage(path, WithCompactOnLoad(true))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	if len(fs.urls) != 2 {
+		t.Errorf("Expected 2 entries in memory, got %d", len(fs.urls))
+	}
+	if entries := readEntries(t, path); len(entries) != 2 {
+		t.Errorf("Expected file to be rewritten with 2 entries, got %d", len(entries))
+	}
+}
+
+func TestNewFileStorageWithoutCompaction(t *testing.T) {
+	path := writeEntries(t, entriesWithDeleted)
+
+	fs, err := NewFileStorage(path)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	if len(fs.urls) != 4 {
+		t.Errorf("Expected all 4 entries in memory, got %d", len(fs.urls))
+	}
+	if entries := readEntries(t, path); len(entries) != 4 {
+		t.Errorf("Expected file to be untouched, got %d entries", len(entries))
+	}
+}
+
+func TestClaimAliasConcurrentExactlyOneWins(t *testing.T) {
+	fs, err := NewFileStorage(filepath.Join(t.TempDir(), "urls.json"))
+	if err != nil {
+		t.Fatalf("NewFileStorage failed: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	var claimed atomic.Int64
+	start := make(chan struct{})
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			ok, err := fs.ClaimAlias(context.Background(), "promo", fmt.Sprintf("https://example.com/%d", i), "user")
+			if err != nil {
+				t.Errorf("ClaimAlias failed: %v", err)
+			}
+			if ok {
+				claimed.Add(1)
+			}
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	if got := claimed.Load(); got != 1 {
+		t.Fatalf("Expected exactly one successful claim, got %d", got)
+	}
+}
+
+func TestSaveFailureIsRecordedAndReportedByPing(t *testing.T) {
+	ctx := context.Background()
+	dir := filepath.Join(t.TempDir(), "gone")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatalf("Mkdir failed: %v", err)
+	}
+	fs, err := NewFileStorage(filepath.Join(dir, "urls.json"))
+	if err != nil {
+		t.Fatalf("NewFileStorage failed: %v", err)
+	}
+	if err := fs.Save(ctx, "ok1", "https://example.com/1", "user"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if err := fs.LastSaveError(); err != nil {
+		t.Fatalf("Expected no save error, got %v", err)
+	}
+
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatalf("RemoveAll failed: %v", err)
+	}
+	if err := fs.Save(ctx, "bad1", "https://example.com/2", "user"); err == nil {
+		t.Fatal("Expected Save to fail when the directory is gone")
+	}
+	if fs.LastSaveError() == nil {
+		t.Fatal("Expected LastSaveError to record the failure")
+	}
+	if got := fs.PendingWrites(); got != 1 {
+		t.Errorf("Expected 1 pending write, got %d", got)
+	}
+	if err := fs.Ping(ctx); err == nil || err.Error() == "file storage does not support database connection check" {
+		t.Errorf("Expected Ping to report the save failure, got %v", err)
+	}
+
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatalf("Mkdir failed: %v", err)
+	}
+	if err := fs.Save(ctx, "ok2", "https://example.com/3", "user"); err != nil {
+		t.Fatalf("Save failed after recovery: %v", err)
+	}
+	if err := fs.LastSaveError(); err != nil {
+		t.Errorf("Expected save error to clear after a successful write, got %v", err)
+	}
+	if got := fs.PendingWrites(); got != 0 {
+		t.Errorf("Expected pending writes to clear after a successful write, got %d", got)
+	}
+}
+
+func TestCreatedAtPersistsAcrossReload(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "urls.json")
+	fs, err := NewFileStorage(path)
+	if err != nil {
+		t.Fatalf("NewFileStorage failed: %v", err)
+	}
+
+	if err := fs.Save(ctx, "older", "https://example.com/a", "user"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	time.Sleep(time.Millisecond)
+	if err := fs.SaveBatch(ctx, map[string]string{"newer": "https://example.com/b"}, "user"); err != nil {
+		t.Fatalf("SaveBatch failed: %v", err)
+	}
+
+	reloaded, err := NewFileStorage(path)
+	if err != nil {
+		t.Fatalf("NewFileStorage (reload) failed: %v", err)
+	}
+	urls, err := reloaded.GetURLsByUserID(ctx, "user")
+	if err != nil {
+		t.Fatalf("GetURLsByUserID failed: %v", err)
+	}
+	if len(urls) != 2 || urls[0].ShortURL != "newer" || urls[1].ShortURL != "older" {
+		t.Fatalf("urls = %+v, want newer before older", urls)
+	}
+	if !urls[0].CreatedAt.After(urls[1].CreatedAt) {
+		t.Fatalf("CreatedAt not preserved: %v vs %v", urls[0].CreatedAt, urls[1].CreatedAt)
+	}
+}
+
+func TestRecentURLsNewestFirstAndBounded(t *testing.T) {
+	now := time.Now()
+	path := writeEntries(t, []models.UserURL{
+		{ShortURL: "old", OriginalURL: "https://example.com/old", UserID: "u", CreatedAt: now.Add(-2 * time.Hour)},
+		{ShortURL: "new", OriginalURL: "https://example.com/new", UserID: "u", CreatedAt: now},
+		{ShortURL: "mid", OriginalURL: "https://example.com/mid", UserID: "u", CreatedAt: now.Add(-time.Hour)},
+		{ShortURL: "gone", OriginalURL: "https://example.com/gone", UserID: "u", IsDeleted: true, CreatedAt: now.Add(time.Hour)},
+	})
+	fs, err := NewFileStorage(path)
+	if err != nil {
+		t.Fatalf("NewFileStorage failed: %v", err)
+	}
+
+	urls, err := fs.RecentURLs(context.Background(), 2)
+	if err != nil {
+		t.Fatalf("RecentURLs failed: %v", err)
+	}
+	if len(urls) != 2 || urls[0].ShortURL != "new" || urls[1].ShortURL != "mid" {
+		t.Fatalf("urls = %+v, want new and mid", urls)
+	}
+}
+
+func TestNextIDSurvivesRestart(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "urls.json")
+
+	fs, err := NewFileStorage(path)
+	if err != nil {
+		t.Fatalf("NewFileStorage failed: %v", err)
+	}
+	var last uint64
+	for i := 1; i <= 3; i++ {
+		n, err := fs.NextID(ctx)
+		if err != nil {
+			t.Fatalf("NextID failed: %v", err)
+		}
+		if n != uint64(i) {
+			t.Fatalf("Expected %d, got %d", i, n)
+		}
+		last = n
+	}
+
+	restarted, err := NewFileStorage(path)
+	if err != nil {
+		t.Fatalf("NewFileStorage failed: %v", err)
+	}
+	n, err := restarted.NextID(ctx)
+	if err != nil {
+		t.Fatalf("NextID after restart failed: %v", err)
+	}
+	if n <= last {
+		t.Errorf("Expected a value past %d after restart, got %d", last, n)
+	}
+}
+
+func TestRecordHitBuffersUntilFlush(t *testing.T) {
+	path := writeEntries(t, []models.UserURL{{ShortURL: "abc", OriginalURL: "https://a.example", UserID: "u"}})
+	fs, err := NewFileStorage(path, WithHitFlushInterval(time.Hour))
+	if err != nil {
+		t.Fatalf("NewFileStorage failed: %v", err)
+	}
+	defer fs.Close()
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		if err := fs.RecordHit(ctx, "abc"); err != nil {
+			t.Fatalf("RecordHit failed: %v", err)
+		}
+	}
+	if hits := readEntries(t, path)[0].Hits; hits != 0 {
+		t.Fatalf("Expected hits to stay buffered before Flush, file has %d", hits)
+	}
+
+	if err := fs.Flush(ctx); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if hits := readEntries(t, path)[0].Hits; hits != 3 {
+		t.Errorf("Expected 3 hits in the file after Flush, got %d", hits)
+	}
+}
+
+func TestCloseWritesBufferedHits(t *testing.T) {
+	path := writeEntries(t, []models.UserURL{{ShortURL: "abc", OriginalURL: "https://a.example", UserID: "u"}})
+	fs, err := NewFileStorage(path, WithHitFlushInterval(time.Hour))
+	if err != nil {
+		t.Fatalf("NewFileStorage failed: %v", err)
+	}
+
+	if err := fs.RecordHit(context.Background(), "abc"); err != nil {
+		t.Fatalf("RecordHit failed: %v", err)
+	}
+	if err := fs.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if hits := readEntries(t, path)[0].Hits; hits != 1 {
+		t.Errorf("Expected the buffered hit to be written on Close, got %d", hits)
+	}
+}
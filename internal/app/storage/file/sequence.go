@@ -0,0 +1,75 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// sequenceBlock is how many short ID counter values one write of the
+// sequence file reserves.
+const sequenceBlock = 100
+
+func (fs *FileStorage) sequencePath() string {
+	return fs.filePath + ".seq"
+}
+
+// NextID hands out counter values from blocks whose upper bound is written
+// next to the storage file before any value in them is used. After a restart
+// counting resumes at that bound, skipping the unused rest of the block
+// rather than risking a value that was already handed out.
+func (fs *FileStorage) NextID(ctx context.Context) (uint64, error) {
+	fs.seqMu.Lock()
+	defer fs.seqMu.Unlock()
+
+	if fs.seqNext == 0 {
+		start, err := fs.loadSequence()
+		if err != nil {
+			return 0, err
+		}
+		fs.seqNext, fs.seqLimit = start, start
+	}
+
+	if fs.seqNext >= fs.seqLimit {
+		limit := fs.seqNext + sequenceBlock
+		if err := fs.saveSequence(limit); err != nil {
+			return 0, err
+		}
+		fs.seqLimit = limit
+	}
+
+	n := fs.seqNext
+	fs.seqNext++
+	return n, nil
+}
+
+func (fs *FileStorage) loadSequence() (uint64, error) {
+	data, err := os.ReadFile(fs.sequencePath())
+	if errors.Is(err, os.ErrNotExist) {
+		return 1, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read short ID sequence: %w", err)
+	}
+	n, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil || n == 0 {
+		return 0, fmt.Errorf("corrupt short ID sequence file %s", fs.sequencePath())
+	}
+	return n, nil
+}
+
+// saveSequence replaces the sequence file atomically, so a crash mid-write
+// cannot leave a truncated bound behind.
+func (fs *FileStorage) saveSequence(limit uint64) error {
+	tmp := fs.sequencePath() + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.FormatUint(limit, 10)+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write short ID sequence: %w", err)
+	}
+	if err := os.Rename(tmp, fs.sequencePath()); err != nil {
+		return fmt.Errorf("failed to write short ID sequence: %w", err)
+	}
+	return nil
+}
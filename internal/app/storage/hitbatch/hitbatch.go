@@ -0,0 +1,85 @@
+// Package hitbatch buffers redirect hit counters in memory so storages can
+// write them in one go instead of once per redirect.
+package hitbatch
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Batcher sums hit increments and hands them to a flush function on an
+// interval, on Flush and on Close. Increments whose flush fails are kept for
+// the next attempt.
+type Batcher struct {
+	mu      sync.Mutex
+	pending map[string]int64
+	flushFn func(ctx context.Context, hits map[string]int64) error
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// New starts a Batcher that flushes every interval.
+func New(interval time.Duration, flushFn func(ctx context.Context, hits map[string]int64) error) *Batcher {
+	b := &Batcher{
+		pending: make(map[string]int64),
+		flushFn: flushFn,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go b.run(interval)
+	return b
+}
+
+func (b *Batcher) Add(shortID string) {
+	b.mu.Lock()
+	b.pending[shortID]++
+	b.mu.Unlock()
+}
+
+func (b *Batcher) Flush(ctx context.Context) error {
+	b.mu.Lock()
+	hits := b.pending
+	b.pending = make(map[string]int64)
+	b.mu.Unlock()
+
+	if len(hits) == 0 {
+		return nil
+	}
+	if err := b.flushFn(ctx, hits); err != nil {
+		b.mu.Lock()
+		for shortID, n := range hits {
+			b.pending[shortID] += n
+		}
+		b.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (b *Batcher) Close(ctx context.Context) error {
+	close(b.stop)
+	<-b.done
+	return b.Flush(ctx)
+}
+
+func (b *Batcher) run(interval time.Duration) {
+	defer close(b.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := b.Flush(context.Background()); err != nil {
+				logrus.WithError(err).Error("Failed to flush hit counters")
+			}
+		case <-b.stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package hitbatch
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingFlusher struct {
+	mu      sync.Mutex
+	flushed map[string]int64
+	calls   int
+	fail    bool
+}
+
+func (f *recordingFlusher) flush(ctx context.Context, hits map[string]int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	if f.fail {
+		return errors.New("database unavailable")
+	}
+	for shortID, n := range hits {
+		f.flushed[shortID] += n
+	}
+	return nil
+}
+
+func TestBatcherAggregatesIncrements(t *testing.T) {
+	f := &recordingFlusher{flushed: make(map[string]int64)}
+	b := New(time.Hour, f.flush)
+
+	for i := 0; i < 5; i++ {
+		b.Add("abc")
+	}
+	b.Add("xyz")
+
+	if err := b.Close(context.Background()); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	if f.calls != 1 {
+		t.Errorf("Expected increments to be written in a single flush, got %d", f.calls)
+	}
+	if f.flushed["abc"] != 5 || f.flushed["xyz"] != 1 {
+		t.Errorf("Unexpected flushed counts: %v", f.flushed)
+	}
+}
+
+func TestBatcherKeepsIncrementsOnFailure(t *testing.T) {
+	f := &recordingFlusher{flushed: make(map[string]int64), fail: true}
+	b := New(time.Hour, f.flush)
+
+	b.Add("abc")
+	b.Add("abc")
+	if err := b.Flush(context.Background()); err == nil {
+		t.Fatal("Expected flush error")
+	}
+
+	f.fail = false
+	b.Add("abc")
+	if err := b.Close(context.Background()); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if f.flushed["abc"] != 3 {
+		t.Errorf("Expected 3 hits after retry, got %d", f.flushed["abc"])
+	}
+}
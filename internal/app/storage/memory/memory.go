@@ -2,42 +2,113 @@ package memory
 
 import (
 	"context"
-	"errors"
+	"fmt"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/AlenaMolokova/http/internal/app/models"
+	"github.com/AlenaMolokova/http/internal/app/tenant"
 )
 
 type MemoryStorage struct {
-	urls map[string]models.UserURL
-	mu   sync.RWMutex
+	urls       map[string]models.UserURL
+	byOriginal map[string]map[string]struct{}
+	mu         sync.RWMutex
+	opts       options
+	seq        atomic.Uint64
 }
 
-func NewMemoryStorage() *MemoryStorage {
-	return &MemoryStorage{
-		urls: make(map[string]models.UserURL),
+type options struct {
+	batchChunkSize int
+}
+
+type Option func(*options)
+
+func WithBatchChunkSize(size int) Option {
+	return func(o *options) {
+		o.batchChunkSize = size
 	}
 }
 
+func NewMemoryStorage(opts ...Option) *MemoryStorage {
+	s := &MemoryStorage{
+		urls:       make(map[string]models.UserURL),
+		byOriginal: make(map[string]map[string]struct{}),
+	}
+	for _, opt := range opts {
+		opt(&s.opts)
+	}
+	return s
+}
+
 func (s *MemoryStorage) Save(ctx context.Context, shortID, originalURL, userID string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	s.urls[shortID] = models.UserURL{
+	s.put(models.UserURL{
 		ShortURL:    shortID,
 		OriginalURL: originalURL,
 		UserID:      userID,
-		IsDeleted:   false,
+		CreatedAt:   time.Now(),
+	})
+	return nil
+}
+
+func (s *MemoryStorage) ClaimAlias(ctx context.Context, alias, originalURL, userID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.urls[alias]; exists {
+		return false, nil
 	}
+	s.put(models.UserURL{
+		ShortURL:    alias,
+		OriginalURL: originalURL,
+		UserID:      userID,
+		CreatedAt:   time.Now(),
+	})
+	return true, nil
+}
+
+func (s *MemoryStorage) SaveWithPassword(ctx context.Context, shortID, originalURL, userID, passwordHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.urls[shortID]; exists {
+		return models.ErrShortIDTaken
+	}
+	s.put(models.UserURL{
+		ShortURL:     shortID,
+		OriginalURL:  originalURL,
+		UserID:       userID,
+		PasswordHash: passwordHash,
+		CreatedAt:    time.Now(),
+	})
 	return nil
 }
 
-func (s *MemoryStorage) FindByOriginalURL(ctx context.Context, originalURL string) (string, error) {
+func (s *MemoryStorage) GetPasswordHash(ctx context.Context, shortID string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.urls[shortID].PasswordHash, nil
+}
+
+func (s *MemoryStorage) IsDeleted(ctx context.Context, shortID string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.urls[shortID].IsDeleted, nil
+}
+
+func (s *MemoryStorage) FindByOriginalURL(ctx context.Context, originalURL, tenantName string) (string, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	for shortID, url := range s.urls {
-		if url.OriginalURL == originalURL && !url.IsDeleted {
+	for shortID := range s.byOriginal[originalURL] {
+		if owner, _ := tenant.Split(shortID); owner == tenantName {
 			return shortID, nil
 		}
 	}
@@ -48,13 +119,20 @@ func (s *MemoryStorage) SaveBatch(ctx context.Context, items map[string]string,
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	now := time.Now()
+	written := 0
 	for shortID, originalURL := range items {
-		s.urls[shortID] = models.UserURL{
+		if s.opts.batchChunkSize > 0 && written > 0 && written%s.opts.batchChunkSize == 0 {
+			s.mu.Unlock()
+			s.mu.Lock()
+		}
+		written++
+		s.put(models.UserURL{
 			ShortURL:    shortID,
 			OriginalURL: originalURL,
 			UserID:      userID,
-			IsDeleted:   false,
-		}
+			CreatedAt:   now,
+		})
 	}
 	return nil
 }
@@ -70,6 +148,32 @@ func (s *MemoryStorage) Get(ctx context.Context, shortID string) (string, bool)
 	return url.OriginalURL, true
 }
 
+func (s *MemoryStorage) GetBatch(ctx context.Context, shortIDs []string) (map[string]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make(map[string]string, len(shortIDs))
+	for _, shortID := range shortIDs {
+		if url, exists := s.urls[shortID]; exists && !url.IsDeleted {
+			result[shortID] = url.OriginalURL
+		}
+	}
+	return result, nil
+}
+
+func (s *MemoryStorage) RecordHit(ctx context.Context, shortID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	url, exists := s.urls[shortID]
+	if !exists {
+		return nil
+	}
+	url.Hits++
+	s.put(url)
+	return nil
+}
+
 func (s *MemoryStorage) GetURLsByUserID(ctx context.Context, userID string) ([]models.UserURL, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -80,9 +184,34 @@ func (s *MemoryStorage) GetURLsByUserID(ctx context.Context, userID string) ([]m
 			result = append(result, url)
 		}
 	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].CreatedAt.Equal(result[j].CreatedAt) {
+			return result[i].CreatedAt.After(result[j].CreatedAt)
+		}
+		return result[i].ShortURL < result[j].ShortURL
+	})
 	return result, nil
 }
 
+// NextID advances an in-process counter; like the URLs themselves it starts
+// over on restart.
+func (s *MemoryStorage) NextID(ctx context.Context) (uint64, error) {
+	return s.seq.Add(1), nil
+}
+
+func (s *MemoryStorage) CountByUserID(ctx context.Context, userID string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	count := 0
+	for _, url := range s.urls {
+		if url.UserID == userID && !url.IsDeleted {
+			count++
+		}
+	}
+	return count, nil
+}
+
 func (s *MemoryStorage) DeleteURLs(ctx context.Context, shortIDs []string, userID string) error {
 	s.mu.Lock()
     defer s.mu.Unlock()
@@ -90,12 +219,134 @@ func (s *MemoryStorage) DeleteURLs(ctx context.Context, shortIDs []string, userI
     for _, shortID := range shortIDs {
         if url, exists := s.urls[shortID]; exists && url.UserID == userID {
             url.IsDeleted = true
-            s.urls[shortID] = url
+            s.put(url)
         }
     }
     return nil
 }
 
+func (s *MemoryStorage) RestoreURLs(ctx context.Context, shortIDs []string, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, shortID := range shortIDs {
+		if url, exists := s.urls[shortID]; exists && url.UserID == userID {
+			url.IsDeleted = false
+			s.put(url)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStorage) OwnerOf(ctx context.Context, shortID string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	url, exists := s.urls[shortID]
+	if !exists {
+		return "", models.ErrURLNotFound
+	}
+	return url.UserID, nil
+}
+
+func (s *MemoryStorage) SetTags(ctx context.Context, shortID string, tags []string, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	url, exists := s.urls[shortID]
+	if !exists || url.IsDeleted {
+		return models.ErrURLNotFound
+	}
+	if url.UserID != userID {
+		return models.ErrURLNotOwned
+	}
+	url.Tags = append([]string(nil), tags...)
+	s.put(url)
+	return nil
+}
+
+func (s *MemoryStorage) UpdateURL(ctx context.Context, shortID, newURL, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	url, exists := s.urls[shortID]
+	if !exists || url.IsDeleted {
+		return models.ErrURLNotFound
+	}
+	if url.UserID != userID {
+		return models.ErrURLNotOwned
+	}
+	url.OriginalURL = newURL
+	s.put(url)
+	return nil
+}
+
+func (s *MemoryStorage) PurgeURLs(ctx context.Context, shortIDs []string, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, shortID := range shortIDs {
+		if url, exists := s.urls[shortID]; exists && url.UserID == userID {
+			s.remove(shortID)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStorage) Export(ctx context.Context) ([]models.UserURL, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]models.UserURL, 0, len(s.urls))
+	for _, url := range s.urls {
+		result = append(result, url)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ShortURL < result[j].ShortURL
+	})
+	return result, nil
+}
+
+func (s *MemoryStorage) Import(ctx context.Context, urls []models.UserURL) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, url := range urls {
+		s.put(url)
+	}
+	return nil
+}
+
 func (s *MemoryStorage) Ping(ctx context.Context) error {
-	return errors.New("memory storage does not support database connection check")
-}
\ No newline at end of file
+	return fmt.Errorf("memory %w", models.ErrNoDatabase)
+}
+
+func (s *MemoryStorage) put(url models.UserURL) {
+	if old, exists := s.urls[url.ShortURL]; exists {
+		s.unindex(old)
+	}
+	s.urls[url.ShortURL] = url
+	if !url.IsDeleted && url.PasswordHash == "" {
+		ids := s.byOriginal[url.OriginalURL]
+		if ids == nil {
+			ids = make(map[string]struct{})
+			s.byOriginal[url.OriginalURL] = ids
+		}
+		ids[url.ShortURL] = struct{}{}
+	}
+}
+
+func (s *MemoryStorage) remove(shortID string) {
+	if old, exists := s.urls[shortID]; exists {
+		s.unindex(old)
+		delete(s.urls, shortID)
+	}
+}
+
+func (s *MemoryStorage) unindex(url models.UserURL) {
+	ids := s.byOriginal[url.OriginalURL]
+	delete(ids, url.ShortURL)
+	if len(ids) == 0 {
+		delete(s.byOriginal, url.OriginalURL)
+	}
+}
@@ -0,0 +1,194 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/AlenaMolokova/http/internal/app/models"
+)
+
+func TestSaveBatchChunkedAllowsConcurrentReads(t *testing.T) {
+	const total = 200000
+	s := NewMemoryStorage(WithBatchChunkSize(100))
+
+	items := make(map[string]string, total)
+	for i := 0; i < total; i++ {
+		items[fmt.Sprintf("id%d", i)] = fmt.Sprintf("https://example.com/%d", i)
+	}
+
+	var done atomic.Bool
+	var partialReads atomic.Int64
+	readerDone := make(chan struct{})
+	go func() {
+		defer close(readerDone)
+		for !done.Load() {
+			s.mu.RLock()
+			n := len(s.urls)
+			s.mu.RUnlock()
+			if n > 0 && n < total {
+				partialReads.Add(1)
+			}
+		}
+	}()
+
+	if err := s.SaveBatch(context.Background(), items, "user"); err != nil {
+		t.Fatalf("SaveBatch failed: %v", err)
+	}
+	done.Store(true)
+	<-readerDone
+
+	if partialReads.Load() == 0 {
+		t.Error("Expected reads to make progress while the batch was being saved")
+	}
+
+	urls, err := s.GetBatch(context.Background(), []string{"id0", fmt.Sprintf("id%d", total-1)})
+	if err != nil {
+		t.Fatalf("GetBatch failed: %v", err)
+	}
+	if len(urls) != 2 {
+		t.Errorf("Expected batch entries to be stored, got %v", urls)
+	}
+	if len(s.urls) != total {
+		t.Errorf("Expected %d entries, got %d", total, len(s.urls))
+	}
+}
+
+func TestClaimAliasConcurrentExactlyOneWins(t *testing.T) {
+	s := NewMemoryStorage()
+	const claimers = 2
+
+	var wg sync.WaitGroup
+	var claimed atomic.Int64
+	start := make(chan struct{})
+	for i := 0; i < claimers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			ok, err := s.ClaimAlias(context.Background(), "promo", fmt.Sprintf("https://example.com/%d", i), fmt.Sprintf("user%d", i))
+			if err != nil {
+				t.Errorf("ClaimAlias failed: %v", err)
+			}
+			if ok {
+				claimed.Add(1)
+			}
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	if got := claimed.Load(); got != 1 {
+		t.Fatalf("Expected exactly one successful claim, got %d", got)
+	}
+	if _, ok := s.Get(context.Background(), "promo"); !ok {
+		t.Error("Expected the claimed alias to resolve")
+	}
+}
+
+func TestFindByOriginalURLTracksIndex(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStorage()
+	const original = "https://reverse.example.com"
+
+	if err := s.Save(ctx, "r1", original, "user1"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if got, _ := s.FindByOriginalURL(ctx, original, ""); got != "r1" {
+		t.Fatalf("expected r1, got %q", got)
+	}
+
+	if err := s.DeleteURLs(ctx, []string{"r1"}, "user1"); err != nil {
+		t.Fatalf("DeleteURLs failed: %v", err)
+	}
+	if got, _ := s.FindByOriginalURL(ctx, original, ""); got != "" {
+		t.Fatalf("expected deleted URL to be skipped, got %q", got)
+	}
+
+	if err := s.RestoreURLs(ctx, []string{"r1"}, "user1"); err != nil {
+		t.Fatalf("RestoreURLs failed: %v", err)
+	}
+	if got, _ := s.FindByOriginalURL(ctx, original, ""); got != "r1" {
+		t.Fatalf("expected restored r1, got %q", got)
+	}
+
+	if err := s.SaveWithPassword(ctx, "r1", original, "user1", "hash"); !errors.Is(err, models.ErrShortIDTaken) {
+		t.Fatalf("expected ErrShortIDTaken overwriting r1, got %v", err)
+	}
+	if got, _ := s.FindByOriginalURL(ctx, original, ""); got != "r1" {
+		t.Fatalf("expected r1 to survive, got %q", got)
+	}
+
+	if err := s.PurgeURLs(ctx, []string{"r1"}, "user1"); err != nil {
+		t.Fatalf("PurgeURLs failed: %v", err)
+	}
+	if err := s.SaveWithPassword(ctx, "p1", original, "user1", "hash"); err != nil {
+		t.Fatalf("SaveWithPassword failed: %v", err)
+	}
+	if got, _ := s.FindByOriginalURL(ctx, original, ""); got != "" {
+		t.Fatalf("expected protected URL to be skipped, got %q", got)
+	}
+
+	if err := s.Save(ctx, "r2", original, "user2"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if err := s.PurgeURLs(ctx, []string{"r2"}, "user2"); err != nil {
+		t.Fatalf("PurgeURLs failed: %v", err)
+	}
+	if got, _ := s.FindByOriginalURL(ctx, original, ""); got != "" {
+		t.Fatalf("expected purged URL to be gone, got %q", got)
+	}
+}
+
+func BenchmarkFindByOriginalURL(b *testing.B) {
+	ctx := context.Background()
+	for _, size := range []int{1000, 100000} {
+		b.Run(fmt.Sprintf("urls=%d", size), func(b *testing.B) {
+			s := NewMemoryStorage()
+			for i := 0; i < size; i++ {
+				if err := s.Save(ctx, fmt.Sprintf("id%d", i), fmt.Sprintf("https://example.com/%d", i), "user"); err != nil {
+					b.Fatalf("Save failed: %v", err)
+				}
+			}
+			target := fmt.Sprintf("https://example.com/%d", size-1)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := s.FindByOriginalURL(ctx, target, ""); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestGetURLsByUserIDNewestFirst(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStorage()
+
+	for _, id := range []string{"first", "second", "third"} {
+		if err := s.Save(ctx, id, "https://example.com/"+id, "user"); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	urls, err := s.GetURLsByUserID(ctx, "user")
+	if err != nil {
+		t.Fatalf("GetURLsByUserID failed: %v", err)
+	}
+	var got []string
+	for _, u := range urls {
+		if u.CreatedAt.IsZero() {
+			t.Errorf("%s has no creation time", u.ShortURL)
+		}
+		got = append(got, u.ShortURL)
+	}
+	if fmt.Sprint(got) != "[third second first]" {
+		t.Fatalf("order = %v, want [third second first]", got)
+	}
+}
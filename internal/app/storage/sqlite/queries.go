@@ -0,0 +1,159 @@
+package sqlite
+
+const (
+	CreateURLsTable = `
+		CREATE TABLE IF NOT EXISTS urls (
+			short_id TEXT PRIMARY KEY,
+			original_url TEXT NOT NULL,
+			user_id TEXT,
+			is_deleted INTEGER NOT NULL DEFAULT 0,
+			password_hash TEXT,
+			hits INTEGER NOT NULL DEFAULT 0,
+			created_at TIMESTAMP,
+			tags TEXT
+		)`
+
+	SelectCreatedAtColumn = `
+		SELECT COUNT(*)
+		FROM pragma_table_info('urls')
+		WHERE name = 'created_at'`
+
+	AddCreatedAtColumn = `
+		ALTER TABLE urls ADD COLUMN created_at TIMESTAMP`
+
+	SelectTagsColumn = `
+		SELECT COUNT(*)
+		FROM pragma_table_info('urls')
+		WHERE name = 'tags'`
+
+	AddTagsColumn = `
+		ALTER TABLE urls ADD COLUMN tags TEXT`
+
+	CreateSequencesTable = `
+		CREATE TABLE IF NOT EXISTS sequences (
+			name TEXT PRIMARY KEY,
+			value INTEGER NOT NULL
+		)`
+
+	NextShortIDValue = `
+		INSERT INTO sequences (name, value) VALUES ('short_id', 1)
+		ON CONFLICT (name) DO UPDATE SET value = value + 1
+		RETURNING value`
+
+	CreateOriginalURLIndex = `
+		CREATE INDEX IF NOT EXISTS idx_urls_original_url ON urls (original_url)`
+
+	CreateUserIDIndex = `
+		CREATE INDEX IF NOT EXISTS idx_urls_user_id ON urls (user_id)`
+
+	InsertURL = `
+		INSERT INTO urls (short_id, original_url, user_id, created_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (short_id) DO NOTHING`
+
+	InsertProtectedURL = `
+		INSERT INTO urls (short_id, original_url, user_id, password_hash, created_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (short_id) DO NOTHING`
+
+	SelectByOriginalURL = `
+		SELECT short_id
+		FROM urls
+		WHERE original_url = ? AND is_deleted = 0 AND password_hash IS NULL
+			AND CASE WHEN ? = '' THEN instr(short_id, '_') = 0 ELSE substr(short_id, 1, length(?)) = ? END
+		LIMIT 1`
+
+	SelectPasswordHash = `
+		SELECT COALESCE(password_hash, '')
+		FROM urls
+		WHERE short_id = ?`
+
+	SelectIsDeleted = `
+		SELECT is_deleted
+		FROM urls
+		WHERE short_id = ?`
+
+	SelectByShortID = `
+		SELECT original_url
+		FROM urls
+		WHERE short_id = ? AND is_deleted = 0`
+
+	SelectByShortIDs = `
+		SELECT short_id, original_url
+		FROM urls
+		WHERE short_id IN (%s) AND is_deleted = 0`
+
+	SelectByUserID = `
+		SELECT short_id, original_url, user_id, hits, created_at, COALESCE(tags, '')
+		FROM urls
+		WHERE user_id = ? AND is_deleted = 0
+		ORDER BY created_at DESC, short_id`
+
+	SelectRecentURLs = `
+		SELECT short_id, original_url
+		FROM urls
+		WHERE is_deleted = 0
+		ORDER BY created_at DESC, short_id
+		LIMIT ?`
+
+	CountByUserID = `
+		SELECT COUNT(*)
+		FROM urls
+		WHERE user_id = ? AND is_deleted = 0`
+
+	SelectAllURLs = `
+		SELECT short_id, original_url, COALESCE(user_id, ''), is_deleted, COALESCE(password_hash, ''), hits, created_at, COALESCE(tags, '')
+		FROM urls
+		ORDER BY short_id`
+
+	UpsertURL = `
+		INSERT INTO urls (short_id, original_url, user_id, is_deleted, password_hash, hits, created_at, tags)
+		VALUES (?, ?, ?, ?, NULLIF(?, ''), ?, ?, ?)
+		ON CONFLICT (short_id) DO UPDATE SET
+			original_url = excluded.original_url,
+			user_id = excluded.user_id,
+			is_deleted = excluded.is_deleted,
+			password_hash = excluded.password_hash,
+			hits = excluded.hits,
+			created_at = excluded.created_at,
+			tags = excluded.tags`
+
+	IncrementHits = `
+		UPDATE urls
+		SET hits = hits + 1
+		WHERE short_id = ?`
+
+	UpdateOriginalURL = `
+		UPDATE urls
+		SET original_url = ?
+		WHERE short_id = ? AND user_id = ? AND is_deleted = 0`
+
+	UpdateTags = `
+		UPDATE urls
+		SET tags = ?
+		WHERE short_id = ? AND user_id = ? AND is_deleted = 0`
+
+	SelectAnyOwner = `
+		SELECT COALESCE(user_id, '')
+		FROM urls
+		WHERE short_id = ?`
+
+	SelectOwner = `
+		SELECT COALESCE(user_id, '')
+		FROM urls
+		WHERE short_id = ? AND is_deleted = 0`
+
+	UpdateDeleteURLs = `
+		UPDATE urls
+		SET is_deleted = 1
+		WHERE short_id IN (%s) AND user_id = ?`
+
+	DeleteURLsByShortIDs = `
+		DELETE FROM urls
+		WHERE short_id IN (%s) AND user_id = ?`
+
+	UpdateRestoreURLs = `
+		UPDATE urls
+		SET is_deleted = 0
+		WHERE short_id IN (%s) AND user_id = ?`
+)
@@ -0,0 +1,438 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/AlenaMolokova/http/internal/app/models"
+	"github.com/AlenaMolokova/http/internal/app/tenant"
+	"github.com/sirupsen/logrus"
+	_ "modernc.org/sqlite"
+)
+
+type SQLiteStorage struct {
+	db *sql.DB
+}
+
+func NewSQLiteStorage(path string) (*SQLiteStorage, error) {
+	dsn := fmt.Sprintf("file:%s?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)", path)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite database: %w", err)
+	}
+	db.SetMaxOpenConns(1)
+
+	for _, stmt := range []string{CreateURLsTable, CreateOriginalURLIndex, CreateUserIDIndex, CreateSequencesTable} {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to create urls table: %w", err)
+		}
+	}
+	if err := addColumn(db, "created_at", SelectCreatedAtColumn, AddCreatedAtColumn); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := addColumn(db, "tags", SelectTagsColumn, AddTagsColumn); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	logrus.WithField("path", path).Info("SQLite storage initialized successfully")
+	return &SQLiteStorage{db: db}, nil
+}
+
+func (s *SQLiteStorage) Save(ctx context.Context, shortID, originalURL, userID string) error {
+	_, err := s.db.ExecContext(ctx, InsertURL, shortID, originalURL, userID, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to save URL: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteStorage) ClaimAlias(ctx context.Context, alias, originalURL, userID string) (bool, error) {
+	result, err := s.db.ExecContext(ctx, InsertURL, alias, originalURL, userID, time.Now().UTC())
+	if err != nil {
+		return false, fmt.Errorf("failed to claim alias: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to claim alias: %w", err)
+	}
+	return affected == 1, nil
+}
+
+func (s *SQLiteStorage) SaveWithPassword(ctx context.Context, shortID, originalURL, userID, passwordHash string) error {
+	result, err := s.db.ExecContext(ctx, InsertProtectedURL, shortID, originalURL, userID, passwordHash, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to save protected URL: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to save protected URL: %w", err)
+	}
+	if affected == 0 {
+		return models.ErrShortIDTaken
+	}
+	return nil
+}
+
+func (s *SQLiteStorage) GetPasswordHash(ctx context.Context, shortID string) (string, error) {
+	var passwordHash string
+	err := s.db.QueryRowContext(ctx, SelectPasswordHash, shortID).Scan(&passwordHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get password hash: %w", err)
+	}
+	return passwordHash, nil
+}
+
+func (s *SQLiteStorage) IsDeleted(ctx context.Context, shortID string) (bool, error) {
+	var deleted bool
+	err := s.db.QueryRowContext(ctx, SelectIsDeleted, shortID).Scan(&deleted)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to check deletion: %w", err)
+	}
+	return deleted, nil
+}
+
+func (s *SQLiteStorage) FindByOriginalURL(ctx context.Context, originalURL, tenantName string) (string, error) {
+	var shortID string
+	prefix := tenant.Prefix(tenantName)
+	err := s.db.QueryRowContext(ctx, SelectByOriginalURL, originalURL, prefix, prefix, prefix).Scan(&shortID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to find URL: %w", err)
+	}
+	return shortID, nil
+}
+
+func (s *SQLiteStorage) SaveBatch(ctx context.Context, batch map[string]string, userID string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, InsertURL)
+	if err != nil {
+		return fmt.Errorf("failed to prepare batch insert: %w", err)
+	}
+	defer stmt.Close()
+
+	now := time.Now().UTC()
+	for shortID, originalURL := range batch {
+		if _, err := stmt.ExecContext(ctx, shortID, originalURL, userID, now); err != nil {
+			return fmt.Errorf("failed to save batch URL: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteStorage) Get(ctx context.Context, shortID string) (string, bool) {
+	var originalURL string
+	err := s.db.QueryRowContext(ctx, SelectByShortID, shortID).Scan(&originalURL)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			logrus.WithError(err).Error("Failed to get URL")
+		}
+		return "", false
+	}
+	return originalURL, true
+}
+
+func (s *SQLiteStorage) GetBatch(ctx context.Context, shortIDs []string) (map[string]string, error) {
+	result := make(map[string]string, len(shortIDs))
+	if len(shortIDs) == 0 {
+		return result, nil
+	}
+
+	query, args := inQuery(SelectByShortIDs, shortIDs)
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query URLs: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var shortID, originalURL string
+		if err := rows.Scan(&shortID, &originalURL); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		result[shortID] = originalURL
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	return result, nil
+}
+
+func (s *SQLiteStorage) GetURLsByUserID(ctx context.Context, userID string) ([]models.UserURL, error) {
+	rows, err := s.db.QueryContext(ctx, SelectByUserID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query URLs: %w", err)
+	}
+	defer rows.Close()
+
+	var urls []models.UserURL
+	for rows.Next() {
+		var url models.UserURL
+		var createdAt sql.NullTime
+		var tags string
+		if err := rows.Scan(&url.ShortURL, &url.OriginalURL, &url.UserID, &url.Hits, &createdAt, &tags); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		url.CreatedAt = createdAt.Time
+		url.Tags = splitTags(tags)
+		urls = append(urls, url)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	return urls, nil
+}
+
+// NextID advances the short ID counter kept in the sequences table.
+func (s *SQLiteStorage) NextID(ctx context.Context) (uint64, error) {
+	var n uint64
+	if err := s.db.QueryRowContext(ctx, NextShortIDValue).Scan(&n); err != nil {
+		return 0, fmt.Errorf("failed to advance short ID sequence: %w", err)
+	}
+	return n, nil
+}
+
+func (s *SQLiteStorage) CountByUserID(ctx context.Context, userID string) (int, error) {
+	var count int
+	if err := s.db.QueryRowContext(ctx, CountByUserID, userID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count URLs: %w", err)
+	}
+	return count, nil
+}
+
+func (s *SQLiteStorage) Export(ctx context.Context) ([]models.UserURL, error) {
+	rows, err := s.db.QueryContext(ctx, SelectAllURLs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query URLs: %w", err)
+	}
+	defer rows.Close()
+
+	var urls []models.UserURL
+	for rows.Next() {
+		var url models.UserURL
+		var createdAt sql.NullTime
+		var tags string
+		if err := rows.Scan(&url.ShortURL, &url.OriginalURL, &url.UserID, &url.IsDeleted, &url.PasswordHash, &url.Hits, &createdAt, &tags); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		url.CreatedAt = createdAt.Time
+		url.Tags = splitTags(tags)
+		urls = append(urls, url)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	return urls, nil
+}
+
+func (s *SQLiteStorage) RecentURLs(ctx context.Context, limit int) ([]models.UserURL, error) {
+	rows, err := s.db.QueryContext(ctx, SelectRecentURLs, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query recent URLs: %w", err)
+	}
+	defer rows.Close()
+
+	var urls []models.UserURL
+	for rows.Next() {
+		var url models.UserURL
+		if err := rows.Scan(&url.ShortURL, &url.OriginalURL); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		urls = append(urls, url)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	return urls, nil
+}
+
+func (s *SQLiteStorage) Import(ctx context.Context, urls []models.UserURL) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, UpsertURL)
+	if err != nil {
+		return fmt.Errorf("failed to prepare import: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, url := range urls {
+		if _, err := stmt.ExecContext(ctx, url.ShortURL, url.OriginalURL, url.UserID, url.IsDeleted, url.PasswordHash, url.Hits, importTime(url.CreatedAt), joinTags(url.Tags)); err != nil {
+			return fmt.Errorf("failed to import URL: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteStorage) RecordHit(ctx context.Context, shortID string) error {
+	if _, err := s.db.ExecContext(ctx, IncrementHits, shortID); err != nil {
+		return fmt.Errorf("failed to record hit: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteStorage) DeleteURLs(ctx context.Context, shortIDs []string, userID string) error {
+	if len(shortIDs) == 0 {
+		return nil
+	}
+	query, args := inQuery(UpdateDeleteURLs, shortIDs, userID)
+	if _, err := s.db.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to delete URLs: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteStorage) RestoreURLs(ctx context.Context, shortIDs []string, userID string) error {
+	if len(shortIDs) == 0 {
+		return nil
+	}
+	query, args := inQuery(UpdateRestoreURLs, shortIDs, userID)
+	if _, err := s.db.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to restore URLs: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteStorage) OwnerOf(ctx context.Context, shortID string) (string, error) {
+	var owner string
+	err := s.db.QueryRowContext(ctx, SelectAnyOwner, shortID).Scan(&owner)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", models.ErrURLNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to look up URL owner: %w", err)
+	}
+	return owner, nil
+}
+
+func (s *SQLiteStorage) SetTags(ctx context.Context, shortID string, tags []string, userID string) error {
+	res, err := s.db.ExecContext(ctx, UpdateTags, joinTags(tags), shortID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to set tags: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to set tags: %w", err)
+	} else if n > 0 {
+		return nil
+	}
+	return s.missingOrNotOwned(ctx, shortID)
+}
+
+// missingOrNotOwned explains why an owner-scoped update matched no row.
+func (s *SQLiteStorage) missingOrNotOwned(ctx context.Context, shortID string) error {
+	var owner string
+	err := s.db.QueryRowContext(ctx, SelectOwner, shortID).Scan(&owner)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.ErrURLNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to look up URL owner: %w", err)
+	}
+	return models.ErrURLNotOwned
+}
+
+func (s *SQLiteStorage) UpdateURL(ctx context.Context, shortID, newURL, userID string) error {
+	res, err := s.db.ExecContext(ctx, UpdateOriginalURL, newURL, shortID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update URL: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to update URL: %w", err)
+	} else if n > 0 {
+		return nil
+	}
+	return s.missingOrNotOwned(ctx, shortID)
+}
+
+func (s *SQLiteStorage) PurgeURLs(ctx context.Context, shortIDs []string, userID string) error {
+	if len(shortIDs) == 0 {
+		return nil
+	}
+	query, args := inQuery(DeleteURLsByShortIDs, shortIDs, userID)
+	if _, err := s.db.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to purge URLs: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteStorage) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+func (s *SQLiteStorage) Close() error {
+	return s.db.Close()
+}
+
+// addColumn runs alter unless inspect reports the column already exists.
+func addColumn(db *sql.DB, name, inspect, alter string) error {
+	var n int
+	if err := db.QueryRow(inspect).Scan(&n); err != nil {
+		return fmt.Errorf("failed to inspect urls table: %w", err)
+	}
+	if n > 0 {
+		return nil
+	}
+	if _, err := db.Exec(alter); err != nil {
+		return fmt.Errorf("failed to add %s column: %w", name, err)
+	}
+	return nil
+}
+
+// Tags are stored comma-separated; tag characters never include a comma.
+func joinTags(tags []string) string {
+	return strings.Join(tags, ",")
+}
+
+func splitTags(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+func importTime(t time.Time) time.Time {
+	if t.IsZero() {
+		return time.Now().UTC()
+	}
+	return t.UTC()
+}
+
+func inQuery(query string, ids []string, extra ...interface{}) (string, []interface{}) {
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	args := make([]interface{}, 0, len(ids)+len(extra))
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	args = append(args, extra...)
+	return fmt.Sprintf(query, placeholders), args
+}
@@ -0,0 +1,402 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/AlenaMolokova/http/internal/app/models"
+)
+
+func newTestStorage(t *testing.T) *SQLiteStorage {
+	t.Helper()
+	s, err := NewSQLiteStorage(filepath.Join(t.TempDir(), "urls.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteStorage failed: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestSaveAndGet(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t)
+
+	if err := s.Save(ctx, "abc", "https://example.com", "user1"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	got, ok := s.Get(ctx, "abc")
+	if !ok || got != "https://example.com" {
+		t.Fatalf("Get = %q, %v; want https://example.com, true", got, ok)
+	}
+	if _, ok := s.Get(ctx, "missing"); ok {
+		t.Fatal("expected missing short ID to be absent")
+	}
+
+	shortID, err := s.FindByOriginalURL(ctx, "https://example.com", "")
+	if err != nil || shortID != "abc" {
+		t.Fatalf("FindByOriginalURL = %q, %v; want abc", shortID, err)
+	}
+}
+
+func TestFindByOriginalURLIsTenantScoped(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t)
+
+	for _, shortID := range []string{"acme_a1", "plain", "globex_g1"} {
+		if err := s.Save(ctx, shortID, "https://shared.example.com", "user1"); err != nil {
+			t.Fatalf("Save %s failed: %v", shortID, err)
+		}
+	}
+
+	for tenantName, want := range map[string]string{"": "plain", "acme": "acme_a1", "globex": "globex_g1", "initech": ""} {
+		got, err := s.FindByOriginalURL(ctx, "https://shared.example.com", tenantName)
+		if err != nil || got != want {
+			t.Errorf("FindByOriginalURL for tenant %q = %q, %v; want %q", tenantName, got, err, want)
+		}
+	}
+}
+
+func TestSaveBatchAndGetBatch(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t)
+
+	batch := map[string]string{
+		"a1": "https://a.example.com",
+		"b2": "https://b.example.com",
+	}
+	if err := s.SaveBatch(ctx, batch, "user1"); err != nil {
+		t.Fatalf("SaveBatch failed: %v", err)
+	}
+
+	got, err := s.GetBatch(ctx, []string{"a1", "b2", "missing"})
+	if err != nil {
+		t.Fatalf("GetBatch failed: %v", err)
+	}
+	if len(got) != 2 || got["a1"] != batch["a1"] || got["b2"] != batch["b2"] {
+		t.Fatalf("GetBatch = %v, want %v", got, batch)
+	}
+}
+
+func TestUserURLsDeleteAndRestore(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t)
+
+	s.Save(ctx, "u1", "https://one.example.com", "user1")
+	s.Save(ctx, "u2", "https://two.example.com", "user1")
+	s.Save(ctx, "o1", "https://other.example.com", "user2")
+
+	if err := s.RecordHit(ctx, "u1"); err != nil {
+		t.Fatalf("RecordHit failed: %v", err)
+	}
+
+	urls, err := s.GetURLsByUserID(ctx, "user1")
+	if err != nil {
+		t.Fatalf("GetURLsByUserID failed: %v", err)
+	}
+	if len(urls) != 2 {
+		t.Fatalf("expected 2 URLs, got %d", len(urls))
+	}
+	for _, u := range urls {
+		if u.ShortURL == "u1" && u.Hits != 1 {
+			t.Errorf("expected 1 hit for u1, got %d", u.Hits)
+		}
+	}
+
+	if err := s.DeleteURLs(ctx, []string{"u1", "o1"}, "user1"); err != nil {
+		t.Fatalf("DeleteURLs failed: %v", err)
+	}
+	if _, ok := s.Get(ctx, "u1"); ok {
+		t.Error("expected u1 to be deleted")
+	}
+	if _, ok := s.Get(ctx, "o1"); !ok {
+		t.Error("another user's URL must not be deleted")
+	}
+
+	if err := s.RestoreURLs(ctx, []string{"u1"}, "user1"); err != nil {
+		t.Fatalf("RestoreURLs failed: %v", err)
+	}
+	if _, ok := s.Get(ctx, "u1"); !ok {
+		t.Error("expected u1 to be restored")
+	}
+}
+
+func TestPasswordProtectedURL(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t)
+
+	if err := s.SaveWithPassword(ctx, "p1", "https://secret.example.com", "user1", "hash"); err != nil {
+		t.Fatalf("SaveWithPassword failed: %v", err)
+	}
+
+	hash, err := s.GetPasswordHash(ctx, "p1")
+	if err != nil || hash != "hash" {
+		t.Fatalf("GetPasswordHash = %q, %v; want hash", hash, err)
+	}
+
+	shortID, err := s.FindByOriginalURL(ctx, "https://secret.example.com", "")
+	if err != nil || shortID != "" {
+		t.Fatalf("protected URL must not be deduplicated, got %q, %v", shortID, err)
+	}
+}
+
+func TestDataSurvivesReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "urls.db")
+
+	s, err := NewSQLiteStorage(path)
+	if err != nil {
+		t.Fatalf("NewSQLiteStorage failed: %v", err)
+	}
+	s.Save(ctx, "abc", "https://example.com", "user1")
+	s.Close()
+
+	s, err = NewSQLiteStorage(path)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer s.Close()
+
+	if got, ok := s.Get(ctx, "abc"); !ok || got != "https://example.com" {
+		t.Fatalf("Get after reopen = %q, %v", got, ok)
+	}
+}
+
+func TestClaimAliasConcurrentExactlyOneWins(t *testing.T) {
+	s := newTestStorage(t)
+
+	var wg sync.WaitGroup
+	var claimed atomic.Int64
+	start := make(chan struct{})
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			ok, err := s.ClaimAlias(context.Background(), "promo", fmt.Sprintf("https://example.com/%d", i), "user")
+			if err != nil {
+				t.Errorf("ClaimAlias failed: %v", err)
+			}
+			if ok {
+				claimed.Add(1)
+			}
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	if got := claimed.Load(); got != 1 {
+		t.Fatalf("Expected exactly one successful claim, got %d", got)
+	}
+}
+
+func TestSaveWithPasswordRefusesTakenID(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	if err := s.Save(ctx, "docs", "https://docs.example.com", "user1"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	err := s.SaveWithPassword(ctx, "docs", "https://secret.example.com", "user2", "hash")
+	if !errors.Is(err, models.ErrShortIDTaken) {
+		t.Fatalf("Expected ErrShortIDTaken, got %v", err)
+	}
+	if got, _ := s.Get(ctx, "docs"); got != "https://docs.example.com" {
+		t.Errorf("Expected docs to keep its URL, got %q", got)
+	}
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	src := newTestStorage(t)
+
+	if err := src.Save(ctx, "a1", "https://a.example.com", "user1"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if err := src.SaveWithPassword(ctx, "p1", "https://p.example.com", "user2", "hash"); err != nil {
+		t.Fatalf("SaveWithPassword failed: %v", err)
+	}
+	if err := src.DeleteURLs(ctx, []string{"a1"}, "user1"); err != nil {
+		t.Fatalf("DeleteURLs failed: %v", err)
+	}
+	if err := src.RecordHit(ctx, "p1"); err != nil {
+		t.Fatalf("RecordHit failed: %v", err)
+	}
+	if err := src.SetTags(ctx, "p1", []string{"docs", "team"}, "user2"); err != nil {
+		t.Fatalf("SetTags failed: %v", err)
+	}
+
+	dump, err := src.Export(ctx)
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if len(dump) != 2 {
+		t.Fatalf("expected 2 exported URLs, got %d", len(dump))
+	}
+	if got := fmt.Sprint(dump[1].Tags); got != "[docs team]" {
+		t.Fatalf("expected p1 to export its tags, got %s", got)
+	}
+
+	dst := newTestStorage(t)
+	if err := dst.Import(ctx, dump); err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if err := dst.Import(ctx, dump); err != nil {
+		t.Fatalf("repeated Import failed: %v", err)
+	}
+
+	again, err := dst.Export(ctx)
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if fmt.Sprint(again) != fmt.Sprint(dump) {
+		t.Errorf("round trip mismatch:\nwant %+v\ngot  %+v", dump, again)
+	}
+}
+
+func TestUpdateURL(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t)
+
+	if err := s.Save(ctx, "u1", "https://old.example.com", "owner"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if err := s.UpdateURL(ctx, "u1", "https://new.example.com", "owner"); err != nil {
+		t.Fatalf("UpdateURL failed: %v", err)
+	}
+	if got, _ := s.Get(ctx, "u1"); got != "https://new.example.com" {
+		t.Errorf("expected updated URL, got %q", got)
+	}
+	if err := s.UpdateURL(ctx, "u1", "https://evil.example.com", "other"); !errors.Is(err, models.ErrURLNotOwned) {
+		t.Errorf("expected ErrURLNotOwned, got %v", err)
+	}
+	if err := s.UpdateURL(ctx, "missing", "https://x.example.com", "owner"); !errors.Is(err, models.ErrURLNotFound) {
+		t.Errorf("expected ErrURLNotFound, got %v", err)
+	}
+}
+
+func TestGetURLsByUserIDNewestFirst(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t)
+
+	for _, id := range []string{"first", "second", "third"} {
+		if err := s.Save(ctx, id, "https://example.com/"+id, "user1"); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	urls, err := s.GetURLsByUserID(ctx, "user1")
+	if err != nil {
+		t.Fatalf("GetURLsByUserID failed: %v", err)
+	}
+	var got []string
+	for _, u := range urls {
+		if u.CreatedAt.IsZero() {
+			t.Errorf("%s has no creation time", u.ShortURL)
+		}
+		got = append(got, u.ShortURL)
+	}
+	if fmt.Sprint(got) != "[third second first]" {
+		t.Fatalf("order = %v, want [third second first]", got)
+	}
+}
+
+func TestCreatedAtColumnAddedToExistingTable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "urls.db")
+	db, err := sql.Open("sqlite", "file:"+path)
+	if err != nil {
+		t.Fatalf("sql.Open failed: %v", err)
+	}
+	_, err = db.Exec(`CREATE TABLE urls (
+		short_id TEXT PRIMARY KEY,
+		original_url TEXT NOT NULL,
+		user_id TEXT,
+		is_deleted INTEGER NOT NULL DEFAULT 0,
+		password_hash TEXT,
+		hits INTEGER NOT NULL DEFAULT 0
+	)`)
+	if err == nil {
+		_, err = db.Exec(`INSERT INTO urls (short_id, original_url, user_id) VALUES ('old', 'https://example.com', 'user1')`)
+	}
+	db.Close()
+	if err != nil {
+		t.Fatalf("failed to prepare legacy table: %v", err)
+	}
+
+	s, err := NewSQLiteStorage(path)
+	if err != nil {
+		t.Fatalf("NewSQLiteStorage failed: %v", err)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+	if err := s.Save(ctx, "new", "https://example.com/new", "user1"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	urls, err := s.GetURLsByUserID(ctx, "user1")
+	if err != nil {
+		t.Fatalf("GetURLsByUserID failed: %v", err)
+	}
+	if len(urls) != 2 || urls[0].ShortURL != "new" || !urls[1].CreatedAt.IsZero() {
+		t.Fatalf("urls = %+v, want new first and legacy row without timestamp", urls)
+	}
+}
+
+func TestSetTags(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t)
+
+	if err := s.Save(ctx, "t1", "https://example.com", "owner"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if err := s.SetTags(ctx, "t1", []string{"work", "docs"}, "owner"); err != nil {
+		t.Fatalf("SetTags failed: %v", err)
+	}
+	urls, err := s.GetURLsByUserID(ctx, "owner")
+	if err != nil {
+		t.Fatalf("GetURLsByUserID failed: %v", err)
+	}
+	if len(urls) != 1 || len(urls[0].Tags) != 2 || urls[0].Tags[0] != "work" || urls[0].Tags[1] != "docs" {
+		t.Fatalf("expected tags [work docs], got %+v", urls)
+	}
+	if err := s.SetTags(ctx, "t1", []string{"x"}, "other"); !errors.Is(err, models.ErrURLNotOwned) {
+		t.Errorf("expected ErrURLNotOwned, got %v", err)
+	}
+	if err := s.SetTags(ctx, "missing", []string{"x"}, "owner"); !errors.Is(err, models.ErrURLNotFound) {
+		t.Errorf("expected ErrURLNotFound, got %v", err)
+	}
+}
+
+func TestNextIDSurvivesReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "urls.db")
+
+	s, err := NewSQLiteStorage(path)
+	if err != nil {
+		t.Fatalf("NewSQLiteStorage failed: %v", err)
+	}
+	for want := uint64(1); want <= 3; want++ {
+		if n, err := s.NextID(ctx); err != nil || n != want {
+			t.Fatalf("NextID = %d, %v; want %d", n, err, want)
+		}
+	}
+	s.Close()
+
+	s, err = NewSQLiteStorage(path)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer s.Close()
+
+	if n, err := s.NextID(ctx); err != nil || n != 4 {
+		t.Fatalf("NextID after reopen = %d, %v; want 4", n, err)
+	}
+}
@@ -1,46 +1,183 @@
 package storage
 
 import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
 	"github.com/AlenaMolokova/http/internal/app/models"
 	"github.com/AlenaMolokova/http/internal/app/storage/database"
 	"github.com/AlenaMolokova/http/internal/app/storage/file"
 	"github.com/AlenaMolokova/http/internal/app/storage/memory"
+	"github.com/AlenaMolokova/http/internal/app/storage/sqlite"
 	"github.com/sirupsen/logrus"
 )
 
+const (
+	BackendPostgres = "postgres"
+	BackendSQLite   = "sqlite"
+	BackendFile     = "file"
+	BackendMemory   = "memory"
+)
+
 type Storage struct {
 	impl interface{}
+	kind string
+}
+
+type options struct {
+	compactOnLoad    bool
+	batchChunkSize   int
+	sqlitePath       string
+	strict           bool
+	hitFlushInterval time.Duration
+	dbOpts           []database.Option
+}
+
+type Option func(*options)
+
+func WithCompactOnLoad(compact bool) Option {
+	return func(o *options) {
+		o.compactOnLoad = compact
+	}
+}
+
+func WithBatchChunkSize(size int) Option {
+	return func(o *options) {
+		o.batchChunkSize = size
+	}
+}
+
+func WithSQLitePath(path string) Option {
+	return func(o *options) {
+		o.sqlitePath = path
+	}
 }
 
-func NewStorage(databaseDSN, fileStoragePath string) (*Storage, error) {
+// WithDBBatchChunkSize sets how many URLs a PostgreSQL batch commits per
+// transaction.
+func WithDBBatchChunkSize(size int) Option {
+	return func(o *options) {
+		o.dbOpts = append(o.dbOpts, database.WithBatchChunkSize(size))
+	}
+}
+
+// WithDBRetry configures retries of transient PostgreSQL errors.
+func WithDBRetry(retries int, backoff time.Duration) Option {
+	return func(o *options) {
+		o.dbOpts = append(o.dbOpts, database.WithRetry(retries, backoff))
+	}
+}
+
+// WithHitFlushInterval sets the PostgreSQL and file hit counter buffering
+// window.
+func WithHitFlushInterval(d time.Duration) Option {
+	return func(o *options) {
+		o.hitFlushInterval = d
+		o.dbOpts = append(o.dbOpts, database.WithHitFlushInterval(d))
+	}
+}
+
+// WithStrict makes NewStorage return the error of a configured backend that
+// fails to open instead of falling back to the next one.
+func WithStrict(strict bool) Option {
+	return func(o *options) {
+		o.strict = strict
+	}
+}
+
+func NewStorage(databaseDSN, fileStoragePath string, opts ...Option) (*Storage, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	var impl interface{}
+	var selected, requested, reason string
+
+	if path, ok := strings.CutPrefix(databaseDSN, "sqlite:"); ok {
+		o.sqlitePath = path
+		databaseDSN = ""
+	}
+
+	recordFailure := func(backend string, err error) {
+		if requested == "" {
+			requested = backend
+			reason = err.Error()
+		}
+	}
 
 	if databaseDSN != "" {
-		dbStorage, err := database.NewPostgresStorage(databaseDSN)
+		dbStorage, err := database.NewPostgresStorage(databaseDSN, o.dbOpts...)
 		if err == nil {
 			logrus.Info("Используется хранилище PostgreSQL")
-			impl = dbStorage
+			impl, selected = dbStorage, BackendPostgres
 		} else {
+			if o.strict {
+				return nil, fmt.Errorf("open %s storage: %w", BackendPostgres, err)
+			}
 			logrus.WithError(err).Warn("Не удалось использовать PostgreSQL, переходим к следующему варианту")
+			recordFailure(BackendPostgres, err)
+		}
+	}
+
+	if impl == nil && o.sqlitePath != "" {
+		sqliteStorage, err := sqlite.NewSQLiteStorage(o.sqlitePath)
+		if err == nil {
+			logrus.WithField("file", o.sqlitePath).Info("Используется хранилище SQLite")
+			impl, selected = sqliteStorage, BackendSQLite
+		} else {
+			if o.strict {
+				return nil, fmt.Errorf("open %s storage: %w", BackendSQLite, err)
+			}
+			logrus.WithError(err).Warn("Не удалось использовать SQLite, переходим к следующему варианту")
+			recordFailure(BackendSQLite, err)
 		}
 	}
 
 	if impl == nil && fileStoragePath != "" {
-		fileStorage, err := file.NewFileStorage(fileStoragePath)
+		fileStorage, err := file.NewFileStorage(fileStoragePath,
+			file.WithCompactOnLoad(o.compactOnLoad),
+			file.WithBatchChunkSize(o.batchChunkSize),
+			file.WithHitFlushInterval(o.hitFlushInterval),
+		)
 		if err == nil {
 			logrus.WithField("file", fileStoragePath).Info("Используется файловое хранилище")
-			impl = fileStorage
+			impl, selected = fileStorage, BackendFile
 		} else {
+			if o.strict {
+				return nil, fmt.Errorf("open %s storage: %w", BackendFile, err)
+			}
 			logrus.WithError(err).Warn("Не удалось использовать файловое хранилище, переходим к памяти")
+			recordFailure(BackendFile, err)
 		}
 	}
 
 	if impl == nil {
 		logrus.Info("Используется хранилище в памяти")
-		impl = memory.NewMemoryStorage()
+		impl, selected = memory.NewMemoryStorage(memory.WithBatchChunkSize(o.batchChunkSize)), BackendMemory
 	}
 
-	return &Storage{impl: impl}, nil
+	if requested != "" {
+		logrus.WithFields(logrus.Fields{
+			"requested_backend": requested,
+			"fallback_backend":  selected,
+			"reason":            reason,
+		}).Warn("Storage backend downgraded")
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"backend": selected,
+		"strict":  o.strict,
+	}).Info("Storage backend selected")
+
+	return &Storage{impl: impl, kind: selected}, nil
+}
+
+func (s *Storage) Kind() string {
+	return s.kind
 }
 
 func (s *Storage) AsURLSaver() models.URLSaver {
@@ -65,4 +202,20 @@ func (s *Storage) AsURLDeleter() models.URLDeleter {
 
 func (s *Storage) AsPinger() models.Pinger {
 	return s.impl.(models.Pinger)
-}
\ No newline at end of file
+}
+
+// Flush forces buffered writes to the backend. Backends that write
+// synchronously have nothing to flush.
+func (s *Storage) Flush(ctx context.Context) error {
+	if flusher, ok := s.impl.(models.Flusher); ok {
+		return flusher.Flush(ctx)
+	}
+	return nil
+}
+
+func (s *Storage) Close() error {
+	if closer, ok := s.impl.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package tenant
+
+import (
+	"context"
+	"strings"
+)
+
+// Separator joins a tenant to a generated short ID. Generator alphabets never
+// contain it, so a namespaced ID always splits unambiguously.
+const Separator = "_"
+
+const maxLength = 32
+
+type tenantKey struct{}
+
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+func FromContext(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantKey{}).(string)
+	return tenant
+}
+
+// Valid reports whether name can be used as a tenant: 1-32 lowercase
+// letters, digits or dashes.
+func Valid(name string) bool {
+	if name == "" || len(name) > maxLength {
+		return false
+	}
+	for _, c := range name {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// Join returns the stored form of a short ID for tenant. An empty tenant
+// leaves the ID unchanged.
+func Join(tenant, id string) string {
+	if tenant == "" {
+		return id
+	}
+	return tenant + Separator + id
+}
+
+// Prefix returns what every stored short ID of tenant starts with; the IDs
+// of the default tenant have no prefix and contain no Separator.
+func Prefix(tenant string) string {
+	return Join(tenant, "")
+}
+
+// Split separates a stored short ID into its tenant and bare ID. IDs without
+// a tenant prefix are returned with an empty tenant.
+func Split(shortID string) (string, string) {
+	tenant, id, ok := strings.Cut(shortID, Separator)
+	if !ok || !Valid(tenant) {
+		return "", shortID
+	}
+	return tenant, id
+}
@@ -0,0 +1,129 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultRetries = 3
+	DefaultBackoff = 500 * time.Millisecond
+	DefaultTimeout = 5 * time.Second
+
+	EventDelete = "delete"
+)
+
+type DeletePayload struct {
+	Event    string    `json:"event"`
+	UserID   string    `json:"user_id"`
+	ShortIDs []string  `json:"short_ids"`
+	Time     time.Time `json:"time"`
+}
+
+// DeleteNotifier posts deleted short IDs to a webhook. It implements the
+// service hooks, which already run off the request path.
+type DeleteNotifier struct {
+	url     string
+	client  *http.Client
+	retries int
+	backoff time.Duration
+	now     func() time.Time
+}
+
+type Option func(*DeleteNotifier)
+
+func WithRetry(retries int, backoff time.Duration) Option {
+	return func(n *DeleteNotifier) {
+		if retries >= 0 {
+			n.retries = retries
+		}
+		if backoff > 0 {
+			n.backoff = backoff
+		}
+	}
+}
+
+func NewDeleteNotifier(url string, client *http.Client, opts ...Option) *DeleteNotifier {
+	n := &DeleteNotifier{
+		url:     url,
+		client:  client,
+		retries: DefaultRetries,
+		backoff: DefaultBackoff,
+		now:     time.Now,
+	}
+	for _, opt := range opts {
+		opt(n)
+	}
+	return n
+}
+
+func (n *DeleteNotifier) OnShorten(ctx context.Context, userID, shortID, originalURL string) {}
+
+func (n *DeleteNotifier) OnRedirect(ctx context.Context, shortID string) {}
+
+func (n *DeleteNotifier) OnDelete(ctx context.Context, userID string, shortIDs []string) {
+	body, err := json.Marshal(DeletePayload{
+		Event:    EventDelete,
+		UserID:   userID,
+		ShortIDs: shortIDs,
+		Time:     n.now(),
+	})
+	if err != nil {
+		logrus.WithError(err).Error("Failed to encode delete webhook payload")
+		return
+	}
+
+	backoff := n.backoff
+	for attempt := 0; attempt <= n.retries; attempt++ {
+		if attempt > 0 {
+			if err = sleep(ctx, backoff); err != nil {
+				break
+			}
+			backoff *= 2
+		}
+		if err = n.post(ctx, body); err == nil {
+			return
+		}
+		logrus.WithError(err).WithField("attempt", attempt+1).Warn("Delete webhook attempt failed")
+	}
+
+	logrus.WithError(err).WithFields(logrus.Fields{
+		"user_id":   userID,
+		"short_ids": shortIDs,
+	}).Error("Delete webhook failed")
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (n *DeleteNotifier) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook answered %d", resp.StatusCode)
+	}
+	return nil
+}
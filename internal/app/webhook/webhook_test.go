@@ -0,0 +1,108 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AlenaMolokova/http/internal/app/safehttp"
+)
+
+type receiver struct {
+	mu       sync.Mutex
+	payloads []DeletePayload
+	failures int
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if ct := req.Header.Get("Content-Type"); ct != "application/json" {
+		http.Error(w, "bad content type "+ct, http.StatusBadRequest)
+		return
+	}
+	var payload DeletePayload
+	if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	r.payloads = append(r.payloads, payload)
+	if r.failures > 0 {
+		r.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// testClient allows loopback so the notifier can reach httptest servers.
+func testClient() *http.Client {
+	return safehttp.NewDialer(safehttp.WithBlockedNetworks()).Client(time.Second)
+}
+
+func TestDeleteNotifierPostsPayload(t *testing.T) {
+	recv := &receiver{}
+	server := httptest.NewServer(recv)
+	defer server.Close()
+
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	notifier := NewDeleteNotifier(server.URL, testClient())
+	notifier.now = func() time.Time { return now }
+
+	notifier.OnDelete(context.Background(), "user", []string{"abc", "def"})
+
+	want := []DeletePayload{{Event: EventDelete, UserID: "user", ShortIDs: []string{"abc", "def"}, Time: now}}
+	if !reflect.DeepEqual(recv.payloads, want) {
+		t.Errorf("Expected payloads %+v, got %+v", want, recv.payloads)
+	}
+}
+
+func TestDeleteNotifierRetriesOnFailure(t *testing.T) {
+	recv := &receiver{failures: 2}
+	server := httptest.NewServer(recv)
+	defer server.Close()
+
+	notifier := NewDeleteNotifier(server.URL, testClient(), WithRetry(3, time.Millisecond))
+	notifier.OnDelete(context.Background(), "user", []string{"abc"})
+
+	if len(recv.payloads) != 3 {
+		t.Fatalf("Expected 2 failed attempts and 1 success, got %d attempts", len(recv.payloads))
+	}
+	for _, payload := range recv.payloads {
+		if payload.UserID != "user" || !reflect.DeepEqual(payload.ShortIDs, []string{"abc"}) {
+			t.Errorf("Unexpected payload %+v", payload)
+		}
+	}
+}
+
+func TestDeleteNotifierGivesUpAfterRetries(t *testing.T) {
+	recv := &receiver{failures: 10}
+	server := httptest.NewServer(recv)
+	defer server.Close()
+
+	notifier := NewDeleteNotifier(server.URL, testClient(), WithRetry(2, time.Millisecond))
+	notifier.OnDelete(context.Background(), "user", []string{"abc"})
+
+	if len(recv.payloads) != 3 {
+		t.Errorf("Expected 1 attempt plus 2 retries, got %d", len(recv.payloads))
+	}
+}
+
+func TestDeleteNotifierRefusesBlockedTarget(t *testing.T) {
+	recv := &receiver{}
+	server := httptest.NewServer(recv)
+	defer server.Close()
+
+	notifier := NewDeleteNotifier(server.URL, safehttp.NewDialer().Client(time.Second), WithRetry(0, 0))
+	notifier.OnDelete(context.Background(), "user", []string{"abc"})
+
+	if len(recv.payloads) != 0 {
+		t.Errorf("Expected loopback webhook to be refused by the default dialer, got %d requests", len(recv.payloads))
+	}
+}